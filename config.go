@@ -33,8 +33,10 @@ type RetinaFaceDetectionConfig struct {
 	// ModelName defines the name of the model to use
 	ModelName string
 	// Timeout defines duration in seconds
-	Timeout             int64
-	ImageSize           [2]int
+	Timeout   int64
+	ImageSize [2]int
+	// MaxBatchSize > 1 batches concurrent detections into requests of up to MaxBatchSize images,
+	// for models with a max_batch_size
 	MaxBatchSize        int32
 	ConfidenceThreshold float32
 	IOUThreshold        float32
@@ -1,14 +1,17 @@
 package gotritron
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	grpc_client "github.com/okieraised/gotritron/grpc-client"
 	"github.com/okieraised/gotritron/opencv"
 	"github.com/okieraised/gotritron/triton_client"
-	"github.com/okieraised/gotritron/utils"
+	"github.com/okieraised/gotritron/triton_client/model_config"
 	"gorgonia.org/tensor"
 	"image"
-	"strings"
+	"math"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -30,14 +33,47 @@ type Anchor struct {
 	AllowedBorder int
 }
 
+// FaceDetection holds a single face detected by RetinaFace, in the coordinates of the input image.
+type FaceDetection struct {
+	// BBox is the bounding box as [x1, y1, x2, y2]
+	BBox [4]float32
+	// Score is the face confidence
+	Score float32
+	// Landmarks are the five facial points (eyes, nose, mouth corners) as [x, y]
+	Landmarks [5][2]float32
+}
+
+// rescale maps the detection from the resized model input back to the original image.
+func (fd *FaceDetection) rescale(detScale float32) {
+	for i := range fd.BBox {
+		fd.BBox[i] /= detScale
+	}
+	for i := range fd.Landmarks {
+		fd.Landmarks[i][0] /= detScale
+		fd.Landmarks[i][1] /= detScale
+	}
+}
+
 type RetinaFaceDetection struct {
 	Config       *RetinaFaceDetectionConfig
-	TritonClient *triton_client.TritonGRPCClient
+	TritonClient triton_client.InferenceClient
 	numAnchor    map[string]int
 	anchorsFPN   map[string][][]float64
+
+	// batcher coalesces concurrent detections when Config.MaxBatchSize > 1, it is recreated
+	// when the model name or the batch size change.
+	batcherMu     sync.Mutex
+	batcher       *triton_client.Batcher
+	batcherConfig triton_client.BatcherConfig
 }
 
-func NewRetinaFaceDetection() (*RetinaFaceDetection, error) {
+// NewRetinaFaceDetection creates a RetinaFace detector that runs inference through tritonClient,
+// either a gRPC or an HTTP client.
+func NewRetinaFaceDetection(tritonClient triton_client.InferenceClient) (*RetinaFaceDetection, error) {
+	if tritonClient == nil {
+		return nil, errors.New("triton client is required")
+	}
+
 	anchorConfig := map[string]Anchor{
 		"32": {
 			Scale:         []float64{32, 16},
@@ -76,7 +112,7 @@ func NewRetinaFaceDetection() (*RetinaFaceDetection, error) {
 	}
 	return &RetinaFaceDetection{
 		Config:       DefaultRetinaFaceDetectionConfig(),
-		TritonClient: triton_client.GetGRPCInstance(),
+		TritonClient: tritonClient,
 		numAnchor:    numAnchors,
 		anchorsFPN:   anchorsFPN,
 	}, nil
@@ -165,206 +201,300 @@ func (rfd *RetinaFaceDetection) Forward(src *tensor.Dense) ([]byte, error) {
 	return imTensor.Raw, nil
 }
 
-func (rfd *RetinaFaceDetection) infer(rawInput []byte) error {
-	modelConf, err := triton_client.GetGRPCInstance().GetModelConfiguration(
-		rfd.Config.ModelName,
-		"",
-		10*time.Second)
+// Detect runs the full RetinaFace pipeline on img and returns the detected faces in
+// the coordinates of img. The Mat is released by Preprocess.
+func (rfd *RetinaFaceDetection) Detect(img *opencv.Mat) ([]FaceDetection, error) {
+	return rfd.DetectContext(context.Background(), img)
+}
+
+// DetectContext is Detect with a context, the inference is also bounded by Config.Timeout.
+func (rfd *RetinaFaceDetection) DetectContext(ctx context.Context, img *opencv.Mat) ([]FaceDetection, error) {
+	preprocessed, detScale, err := rfd.Preprocess(img)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dataType := ""
-	dataTypes := strings.Split(modelConf.Config.Input[0].DataType.String(), "_")
-	if len(dataTypes) == 2 {
-		dataType = dataTypes[1]
+	rawInput, err := rfd.Forward(preprocessed)
+	if err != nil {
+		return nil, err
 	}
 
-	inferInputs := []*grpc_client.ModelInferRequest_InferInputTensor{
-		{
-			Name:     modelConf.Config.Input[0].Name,
-			Datatype: dataType,
-			Shape:    modelConf.Config.Input[0].Dims,
-		},
+	netOuts, err := rfd.infer(ctx, rawInput)
+	if err != nil {
+		return nil, err
 	}
 
-	// run the inference models
-	infer, err := triton_client.GetGRPCInstance().ModelGRPCInfer(inferInputs, nil,
-		[][]byte{rawInput}, rfd.Config.ModelName, "", 10*time.Second)
+	return rfd.postprocess(netOuts, detScale)
+}
+
+func (rfd *RetinaFaceDetection) infer(ctx context.Context, rawInput []byte) ([]*tensor.Dense, error) {
+	if rfd.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(rfd.Config.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	modelConf, err := rfd.TritonClient.CachedModelConfigurationContext(ctx, rfd.Config.ModelName, "")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	netOuts := make([]*tensor.Dense, len(modelConf.Config.Output))
-	for idx, out := range modelConf.Config.Output {
-		dims := make([]int, len(out.Dims))
-		for j, val := range out.Dims {
-			dims[j] = int(val)
-		}
-		inferLens := utils.MultiplyAll(dims)
-		res := make([]float32, inferLens)
-		for i := 0; i < inferLens; i++ {
-			res[i] = utils.BytesToFloat32(infer.RawOutputContents[idx][i*4 : i*4+4])
-		}
-		outTensor := tensor.New(tensor.WithBacking(res), tensor.WithShape(dims...))
-		netOuts[idx] = outTensor
+	// rawInput is a single NCHW image, the batch dimension is added for batching models
+	input := modelConf.Config.Input[0]
+	shape, err := triton_client.ResolveInputShape(modelConf.Config, input.Name, []int64{1, 3, int64(rfd.Config.ImageSize[0]), int64(rfd.Config.ImageSize[1])})
+	if err != nil {
+		return nil, err
 	}
 
-	symIdx := 0
-	for idx, s := range featStrideFPN {
-		scores := netOuts[symIdx]
-		subScores, err := scores.Slice(nil, tensor.S(rfd.numAnchor[fmt.Sprintf("stride%d", s)], scores.Shape()[1]), nil, nil)
-		if err != nil {
-			return err
-		}
-		if subScores == nil {
-			return err
-		}
+	inferInput := triton_client.NewInferInput(input.Name, shape, model_config.DataTypeToProtocolString(input.DataType))
+	err = inferInput.SetRawData(rawInput)
+	if err != nil {
+		return nil, err
+	}
 
-		bboxDeltas := netOuts[symIdx+1]
-		height := bboxDeltas.Shape()[2]
-		width := bboxDeltas.Shape()[3]
-		A := rfd.numAnchor[fmt.Sprintf("stride%d", s)]
-		K := height * width
-		anchorFPN := rfd.anchorsFPN[fmt.Sprintf("stride%d", s)]
+	// run the inference models
+	result, err := rfd.run(ctx, modelConf.Config.MaxBatchSize, inferInput)
+	if err != nil {
+		return nil, err
+	}
 
-		anchors, err := AnchorPlane(height, width, s, anchorFPN)
+	// outputs are looked up by name in config order, their shapes come from the response and
+	// are checked against the config
+	netOuts := make([]*tensor.Dense, len(modelConf.Config.Output))
+	for idx, out := range modelConf.Config.Output {
+		outShape, err := result.Shape(out.Name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = anchors.Reshape(K*A, 4)
+		outShape, err = triton_client.ResolveOutputShape(modelConf.Config, out.Name, outShape)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		newT, err := subScores.(*tensor.Dense).SafeT(0, 2, 3, 1)
+		netOuts[idx], err = result.AsTensor(out.Name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		//if idx == 0 {
-		//	fmt.Println(newT.Shape())
-		//}
-
-		err = newT.Reshape(newT.Shape()[1]*newT.Shape()[2]*newT.Shape()[3], 1)
-		if err != nil {
-			return err
+		if len(outShape) != netOuts[idx].Dims() {
+			dims := make([]int, len(outShape))
+			for i, dim := range outShape {
+				dims[i] = int(dim)
+			}
+			err = netOuts[idx].Reshape(dims...)
+			if err != nil {
+				return nil, err
+			}
 		}
+	}
+
+	return netOuts, nil
+}
 
-		//if idx == 0 {
-		//	fmt.Println(newT.Shape())
-		//	fmt.Println(newT)
-		//}
+// run sends a single image to the model, batched with concurrent detections when both the
+// model and Config.MaxBatchSize allow more than one image per request.
+func (rfd *RetinaFaceDetection) run(ctx context.Context, modelMaxBatchSize int32, input *triton_client.InferInput) (*triton_client.InferResult, error) {
+	if modelMaxBatchSize <= 0 || rfd.Config.MaxBatchSize <= 1 {
+		return rfd.TritonClient.Infer(ctx, rfd.Config.ModelName, "", []*triton_client.InferInput{input}, nil)
+	}
 
-		tBboxDeltas, err := bboxDeltas.SafeT(0, 2, 3, 1)
-		if err != nil {
-			return err
-		}
+	config := triton_client.BatcherConfig{ModelName: rfd.Config.ModelName, MaxBatchSize: int(rfd.Config.MaxBatchSize)}
+	rfd.batcherMu.Lock()
+	if rfd.batcher == nil || rfd.batcherConfig.ModelName != config.ModelName || rfd.batcherConfig.MaxBatchSize != config.MaxBatchSize {
+		rfd.batcher = triton_client.NewBatcher(rfd.TritonClient, config)
+		rfd.batcherConfig = config
+	}
+	batcher := rfd.batcher
+	rfd.batcherMu.Unlock()
+
+	return batcher.Infer(ctx, []*triton_client.InferInput{input})
+}
 
-		//if idx == 0 {
-		//	fmt.Println(tBboxDeltas.Shape())
-		//	fmt.Println(tBboxDeltas)
-		//}
+// postprocess decodes the network outputs into face detections. netOuts holds, for every
+// stride in featStrideFPN, the class scores, the bbox deltas and the landmark deltas.
+func (rfd *RetinaFaceDetection) postprocess(netOuts []*tensor.Dense, detScale float64) ([]FaceDetection, error) {
+	if len(netOuts) < len(featStrideFPN)*3 {
+		return nil, fmt.Errorf("expected %d network outputs, got %d", len(featStrideFPN)*3, len(netOuts))
+	}
 
-		bboxPredLen := tBboxDeltas.Shape()[3] / A
-		//if idx == 0 {
-		//	fmt.Println(bboxPredLen)
-		//}
+	var proposals []FaceDetection
+	for idx, s := range featStrideFPN {
+		symIdx := idx * 3
+		key := fmt.Sprintf("stride%d", s)
+		A := rfd.numAnchor[key]
 
-		err = tBboxDeltas.Reshape(tBboxDeltas.Shape()[1]*tBboxDeltas.Shape()[2]*tBboxDeltas.Shape()[3]/bboxPredLen, bboxPredLen)
+		bboxDeltas := netOuts[symIdx+1]
+		boxes, err := transposeNCHW(bboxDeltas)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		//if idx == 0 {
-		//	fmt.Println(tBboxDeltas)
-		//	fmt.Println(tBboxDeltas.Shape())
-		//}
-		//---------------------------
-		tBboxDeltas04, err := tBboxDeltas.Slice(nil, tensor.S(0, tBboxDeltas.Shape()[1]+1, 4))
-		mulTensor04, err := tBboxDeltas04.(*tensor.Dense).MulScalar(bBoxSTD[0], true)
+		height := bboxDeltas.Shape()[2]
+		width := bboxDeltas.Shape()[3]
+		K := height * width
+
+		anchors, err := AnchorPlane(height, width, s, rfd.anchorsFPN[key])
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = tensor.Copy(tBboxDeltas04, mulTensor04)
+		err = anchors.Reshape(K*A, 4)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		//---------------------------
-		tBboxDeltas14, err := tBboxDeltas.Slice(nil, tensor.S(1, tBboxDeltas.Shape()[1], 4))
-		mulTensor14, err := tBboxDeltas14.(*tensor.Dense).MulScalar(bBoxSTD[1], true)
+		// the first A channels of the scores are the background probabilities
+		scores, err := transposeNCHW(netOuts[symIdx])
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = tensor.Copy(tBboxDeltas14, mulTensor14)
+		landmarks, err := transposeNCHW(netOuts[symIdx+2])
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		//---------------------------
-		tBboxDeltas24, err := tBboxDeltas.Slice(nil, tensor.S(2, tBboxDeltas.Shape()[1], 4))
-		mulTensor24, err := tBboxDeltas24.(*tensor.Dense).MulScalar(bBoxSTD[2], true)
-		if err != nil {
-			return err
+		if scores.Shape()[0] != K || landmarks.Shape()[0] != K || boxes.Shape()[0] != K {
+			return nil, fmt.Errorf("%s: expected %dx%d locations in every output, got scores %v, bbox %v, landmarks %v",
+				key, height, width, netOuts[symIdx].Shape(), bboxDeltas.Shape(), netOuts[symIdx+2].Shape())
 		}
-		err = tensor.Copy(tBboxDeltas24, mulTensor24)
-		if err != nil {
-			return err
+		scoreLen := scores.Shape()[1]
+		if scoreLen != 2*A {
+			return nil, fmt.Errorf("%s: expected %d score channels for %d anchors, got %d", key, 2*A, A, scoreLen)
 		}
-
-		//---------------------------
-		tBboxDeltas34, err := tBboxDeltas.Slice(nil, tensor.S(3, tBboxDeltas.Shape()[1], 4))
-		mulTensor34, err := tBboxDeltas34.(*tensor.Dense).MulScalar(bBoxSTD[3], true)
-		if err != nil {
-			return err
+		if boxes.Shape()[1]%A != 0 || boxes.Shape()[1]/A < 4 {
+			return nil, fmt.Errorf("%s: expected at least 4 bbox channels per anchor for %d anchors, got %d", key, A, boxes.Shape()[1])
 		}
-		err = tensor.Copy(tBboxDeltas34, mulTensor34)
-		if err != nil {
-			return err
+		// landmarkPred decodes exactly five (x, y) pairs per anchor
+		if landmarks.Shape()[1] != 10*A {
+			return nil, fmt.Errorf("%s: expected 10 landmark channels per anchor for %d anchors, got %d", key, A, landmarks.Shape()[1])
 		}
 
-		if idx == 0 {
-			fmt.Println(tBboxDeltas)
-			fmt.Println(tBboxDeltas.Shape())
+		err = boxes.Reshape(K*A, boxes.Shape()[1]/A)
+		if err != nil {
+			return nil, err
 		}
-
-		_, err = rfd.bboxPred(anchors, tBboxDeltas)
+		err = landmarks.Reshape(K*A, 10)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if idx == -1 {
-			return nil
+		anchorData := anchors.Data().([]float32)
+		scoreData := scores.Data().([]float32)
+		boxData := boxes.Data().([]float32)
+		boxPredLen := boxes.Shape()[1]
+		landmarkData := landmarks.Data().([]float32)
+
+		for i := 0; i < K*A; i++ {
+			score := scoreData[(i/A)*scoreLen+A+i%A]
+			if score < rfd.Config.ConfidenceThreshold {
+				continue
+			}
+			anchor := anchorData[i*4 : i*4+4]
+
+			det := FaceDetection{
+				BBox:      bboxPred(anchor, boxData[i*boxPredLen:i*boxPredLen+4]),
+				Score:     score,
+				Landmarks: landmarkPred(anchor, landmarkData[i*10:i*10+10]),
+			}
+			clipBox(&det.BBox, float32(rfd.Config.ImageSize[0]), float32(rfd.Config.ImageSize[1]))
+			det.rescale(float32(detScale))
+			proposals = append(proposals, det)
 		}
 	}
 
-	//fmt.Println("netOuts", netOuts)
-
-	return nil
+	return nms(proposals, rfd.Config.IOUThreshold), nil
 }
 
-func (rfd *RetinaFaceDetection) bboxPred(boxes, boxDelta *tensor.Dense) (*tensor.Dense, error) {
-	if boxes.Shape()[0] == 0 {
-		return tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(0, boxDelta.Shape()[1])), nil
+// transposeNCHW converts a [1, C, H, W] tensor into a contiguous [H*W, C] tensor.
+func transposeNCHW(t *tensor.Dense) (*tensor.Dense, error) {
+	shape := t.Shape()
+	if len(shape) != 4 {
+		return nil, fmt.Errorf("expected 4-dimensional output, got shape %v", shape)
 	}
-
-	bRows2, err := boxes.Slice(nil, tensor.S(2))
+	transposed, err := t.SafeT(0, 2, 3, 1)
 	if err != nil {
 		return nil, err
 	}
-	bRows0, err := boxes.Slice(nil, tensor.S(0))
+	err = transposed.Reshape(shape[0]*shape[2]*shape[3], shape[1])
 	if err != nil {
 		return nil, err
 	}
-	bSubRow, err := tensor.Sub(bRows2, bRows0)
-	if err != nil {
-		return nil, err
+	return transposed, nil
+}
+
+// bboxPred applies the regression deltas [dx, dy, dw, dh] to an anchor box [x1, y1, x2, y2].
+func bboxPred(box, delta []float32) [4]float32 {
+	width, height, ctrX, ctrY := boxCenter(box)
+
+	predCtrX := delta[0]*bBoxSTD[0]*width + ctrX
+	predCtrY := delta[1]*bBoxSTD[1]*height + ctrY
+	predW := float32(math.Exp(float64(delta[2]*bBoxSTD[2]))) * width
+	predH := float32(math.Exp(float64(delta[3]*bBoxSTD[3]))) * height
+
+	return [4]float32{
+		predCtrX - 0.5*(predW-1.0),
+		predCtrY - 0.5*(predH-1.0),
+		predCtrX + 0.5*(predW-1.0),
+		predCtrY + 0.5*(predH-1.0),
 	}
-	widths, err := tensor.Add(bSubRow, float32(1))
-	if err != nil {
-		return nil, err
+}
+
+// landmarkPred applies the five-point landmark deltas to an anchor box [x1, y1, x2, y2].
+func landmarkPred(box, delta []float32) [5][2]float32 {
+	width, height, ctrX, ctrY := boxCenter(box)
+
+	var landmarks [5][2]float32
+	for i := range landmarks {
+		landmarks[i][0] = delta[i*2]*landmarkSTD*width + ctrX
+		landmarks[i][1] = delta[i*2+1]*landmarkSTD*height + ctrY
 	}
+	return landmarks
+}
 
-	fmt.Println(widths, widths.Shape())
+// boxCenter returns width, height, x center, and y center for a box.
+func boxCenter(box []float32) (float32, float32, float32, float32) {
+	width := box[2] - box[0] + 1.0
+	height := box[3] - box[1] + 1.0
+	return width, height, box[0] + 0.5*(width-1.0), box[1] + 0.5*(height-1.0)
+}
+
+// clipBox clips the box to the image boundaries.
+func clipBox(box *[4]float32, width, height float32) {
+	clip := func(v, hi float32) float32 {
+		return float32(math.Max(math.Min(float64(v), float64(hi-1)), 0))
+	}
+	box[0] = clip(box[0], width)
+	box[1] = clip(box[1], height)
+	box[2] = clip(box[2], width)
+	box[3] = clip(box[3], height)
+}
 
-	return nil, nil
+// nms performs greedy non-maximum suppression and returns the kept detections
+// sorted by descending score.
+func nms(dets []FaceDetection, threshold float32) []FaceDetection {
+	sort.SliceStable(dets, func(i, j int) bool {
+		return dets[i].Score > dets[j].Score
+	})
+
+	area := func(b [4]float32) float32 {
+		return (b[2] - b[0] + 1) * (b[3] - b[1] + 1)
+	}
+
+	suppressed := make([]bool, len(dets))
+	keep := make([]FaceDetection, 0, len(dets))
+	for i := range dets {
+		if suppressed[i] {
+			continue
+		}
+		keep = append(keep, dets[i])
+		for j := i + 1; j < len(dets); j++ {
+			if suppressed[j] {
+				continue
+			}
+			a, b := dets[i].BBox, dets[j].BBox
+			w := float32(math.Max(0, float64(min(a[2], b[2])-max(a[0], b[0])+1)))
+			h := float32(math.Max(0, float64(min(a[3], b[3])-max(a[1], b[1])+1)))
+			inter := w * h
+			if inter/(area(a)+area(b)-inter) > threshold {
+				suppressed[j] = true
+			}
+		}
+	}
+	return keep
 }
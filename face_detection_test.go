@@ -1,26 +1,67 @@
 package gotritron
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
 	"github.com/okieraised/gotritron/triton_client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
 	"github.com/okieraised/gotritron/utils"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"gorgonia.org/tensor"
 	"io"
+	"math"
 	"os"
 	"testing"
+	"time"
 )
 
-func TestNewRetinaFaceDetection(t *testing.T) {
-	err := triton_client.NewTritonGRPCClient(
-		"210.211.99.18:8301",
-		[]grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+// fakeRetinaFaceModel is a RetinaFace model whose only detection is a confident second
+// anchor on the stride 32 plane, at location (10, 12).
+func fakeRetinaFaceModel() *tritontest.Model {
+	config := &grpc_client.ModelConfig{
+		Name:  DefaultRetinaFaceDetectionConfig().ModelName,
+		Input: []*grpc_client.ModelInput{{Name: "data", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 3, 640, 640}}},
+	}
+	var outputs []*grpc_client.ModelInferResponse_InferOutputTensor
+	for _, s := range featStrideFPN {
+		for _, kind := range []struct {
+			name     string
+			channels int64
+		}{{"face_rpn_cls_prob_reshape", 4}, {"face_rpn_bbox_pred", 8}, {"face_rpn_landmark_pred", 20}} {
+			name := fmt.Sprintf("%s_stride%d", kind.name, s)
+			shape := []int64{1, kind.channels, int64(640 / s), int64(640 / s)}
+			config.Output = append(config.Output, &grpc_client.ModelOutput{Name: name, DataType: grpc_client.DataType_TYPE_FP32, Dims: shape})
+			outputs = append(outputs, &grpc_client.ModelInferResponse_InferOutputTensor{Name: name, Datatype: triton_client.DataTypeFP32, Shape: shape})
+		}
+	}
+
+	return &tritontest.Model{
+		Config: config,
+		Infer: func(_ context.Context, _ *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			response := &grpc_client.ModelInferResponse{Outputs: outputs}
+			for _, output := range outputs {
+				count := output.Shape[1] * output.Shape[2] * output.Shape[3]
+				response.RawOutputContents = append(response.RawOutputContents, make([]byte, 4*count))
+			}
+			// score channel 3 of stride 32 at (10, 12), channels are 20x20 planes
+			binary.LittleEndian.PutUint32(response.RawOutputContents[0][4*(3*400+10*20+12):], math.Float32bits(0.99))
+			return response, nil
 		},
-	)
+	}
+}
+
+func TestNewRetinaFaceDetection(t *testing.T) {
+	server := tritontest.NewServer()
+	defer server.Close()
+	server.AddModel(fakeRetinaFaceModel())
+
+	tritonClient, err := triton_client.NewTritonGRPCClient(tritontest.Target, append(server.DialOptions(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	))
 	assert.NoError(t, err)
 
 	f, err := os.Open("./test_data/harrison.jpeg")
@@ -33,19 +74,181 @@ func TestNewRetinaFaceDetection(t *testing.T) {
 	res, err := utils.ConvertImageToOpenCV(content)
 	assert.NoError(t, err)
 
-	rfd, err := NewRetinaFaceDetection()
+	rfd, err := NewRetinaFaceDetection(tritonClient)
 	assert.NoError(t, err)
 
-	preprocessed, _, err := rfd.Preprocess(res)
+	faces, err := rfd.Detect(res)
 	assert.NoError(t, err)
-	assert.NotNil(t, preprocessed)
+	assert.Len(t, faces, 1)
+	assert.Equal(t, float32(0.99), faces[0].Score)
+	assert.Len(t, server.InferRequests(), 1)
+}
+
+func TestBBoxPred(t *testing.T) {
+	anchor := []float32{0, 0, 15, 15}
+
+	box := bboxPred(anchor, []float32{0, 0, 0, 0})
+	assert.Equal(t, [4]float32{0, 0, 15, 15}, box)
 
-	img, err := rfd.Forward(preprocessed)
+	box = bboxPred(anchor, []float32{0.5, -0.5, 0, 0})
+	assert.Equal(t, [4]float32{8, -8, 23, 7}, box)
+
+	landmarks := landmarkPred(anchor, []float32{0, 0, 0.5, 0, 0, 0.5, -0.5, 0, 0, -0.5})
+	assert.Equal(t, [5][2]float32{{7.5, 7.5}, {15.5, 7.5}, {7.5, 15.5}, {-0.5, 7.5}, {7.5, -0.5}}, landmarks)
+}
+
+func TestNMS(t *testing.T) {
+	dets := []FaceDetection{
+		{BBox: [4]float32{0, 0, 9, 9}, Score: 0.8},
+		{BBox: [4]float32{1, 1, 10, 10}, Score: 0.9},
+		{BBox: [4]float32{50, 50, 59, 59}, Score: 0.75},
+	}
+
+	keep := nms(dets, 0.45)
+	assert.Len(t, keep, 2)
+	assert.Equal(t, float32(0.9), keep[0].Score)
+	assert.Equal(t, float32(0.75), keep[1].Score)
+}
+
+// fakeInferenceClient serves a fixed model config and answers inference with a fixed response.
+type fakeInferenceClient struct {
+	triton_client.InferenceClient
+	config   *grpc_client.ModelConfig
+	response *grpc_client.ModelInferResponse
+	inputs   []*triton_client.InferInput
+	deadline time.Time
+}
+
+func (c *fakeInferenceClient) CachedModelConfigurationContext(_ context.Context, modelName, _ string) (*grpc_client.ModelConfigResponse, error) {
+	if modelName != c.config.Name {
+		return nil, fmt.Errorf("unknown model %s", modelName)
+	}
+	return &grpc_client.ModelConfigResponse{Config: c.config}, nil
+}
+
+func (c *fakeInferenceClient) Infer(ctx context.Context, _, _ string, inputs []*triton_client.InferInput, _ []*triton_client.InferRequestedOutput, _ ...triton_client.InferOption) (*triton_client.InferResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.inputs = inputs
+	c.deadline, _ = ctx.Deadline()
+	return triton_client.NewInferResult(c.response), nil
+}
+
+func TestRetinaFaceDetection_infer(t *testing.T) {
+	tritonClient := &fakeInferenceClient{
+		config: &grpc_client.ModelConfig{
+			Name:         DefaultRetinaFaceDetectionConfig().ModelName,
+			MaxBatchSize: 1,
+			Input:        []*grpc_client.ModelInput{{Name: "data", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{3, -1, -1}}},
+			Output:       []*grpc_client.ModelOutput{{Name: "score", Dims: []int64{2}}, {Name: "bbox", Dims: []int64{-1}}},
+		},
+		response: &grpc_client.ModelInferResponse{
+			Outputs: []*grpc_client.ModelInferResponse_InferOutputTensor{
+				{Name: "bbox", Datatype: triton_client.DataTypeFP32, Shape: []int64{1, 1}},
+				{Name: "score", Datatype: triton_client.DataTypeFP32, Shape: []int64{1, 2}},
+			},
+			RawOutputContents: [][]byte{make([]byte, 4), make([]byte, 8)},
+		},
+	}
+
+	rfd, err := NewRetinaFaceDetection(tritonClient)
+	assert.NoError(t, err)
+	rfd.Config.ImageSize = [2]int{2, 2}
+	ctx := context.Background()
+
+	netOuts, err := rfd.infer(ctx, make([]byte, 48))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Duration(rfd.Config.Timeout)*time.Second), tritonClient.deadline, time.Second)
+	assert.Len(t, netOuts, 2)
+	assert.Equal(t, tensor.Shape{1, 2}, netOuts[0].Shape())
+	assert.Equal(t, tensor.Shape{1, 1}, netOuts[1].Shape())
+	assert.Equal(t, "data", tritonClient.inputs[0].Name())
+	assert.Equal(t, triton_client.DataTypeFP32, tritonClient.inputs[0].Datatype())
+	assert.Equal(t, []int64{1, 3, 2, 2}, tritonClient.inputs[0].Shape())
+
+	_, err = rfd.infer(ctx, make([]byte, 12))
+	assert.Error(t, err)
+
+	// the caller's context bounds the inference
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = rfd.infer(cancelled, make([]byte, 48))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// detections go through a batcher for batching models
+	tritonClient.config.MaxBatchSize = 4
+	rfd.Config.MaxBatchSize = 4
+	netOuts, err = rfd.infer(ctx, make([]byte, 48))
 	assert.NoError(t, err)
-	assert.NotNil(t, img)
+	assert.Equal(t, tensor.Shape{1, 2}, netOuts[0].Shape())
+	assert.Equal(t, []int64{1, 3, 2, 2}, tritonClient.inputs[0].Shape())
+	assert.NotNil(t, rfd.batcher)
+	rfd.Config.MaxBatchSize = 1
 
-	err = rfd.infer(img)
+	// outputs missing the batch dimension get it back
+	tritonClient.response.Outputs[0].Shape = []int64{1}
+	netOuts, err = rfd.infer(ctx, make([]byte, 48))
 	assert.NoError(t, err)
+	assert.Equal(t, tensor.Shape{1, 1}, netOuts[1].Shape())
+
+	// outputs that do not match the config are rejected
+	tritonClient.response.Outputs[1].Shape = []int64{2, 1}
+	_, err = rfd.infer(ctx, make([]byte, 48))
+	assert.Error(t, err)
+
+	tritonClient.config.Input[0].Dims = []int64{1, 2, 2}
+	_, err = rfd.infer(ctx, make([]byte, 48))
+	assert.Error(t, err)
+}
+
+func TestRetinaFaceDetection_postprocess(t *testing.T) {
+	rfd, err := NewRetinaFaceDetection(&fakeInferenceClient{})
+	assert.NoError(t, err)
+
+	// a single confident second anchor on the stride 32 plane, at location (10, 12)
+	var netOuts []*tensor.Dense
+	for _, s := range featStrideFPN {
+		A := rfd.numAnchor[fmt.Sprintf("stride%d", s)]
+		h, w := 640/s, 640/s
+		netOuts = append(netOuts,
+			tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 2*A, h, w)),
+			tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 4*A, h, w)),
+			tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 10*A, h, w)),
+		)
+	}
+	err = netOuts[0].SetAt(float32(0.99), 0, 3, 10, 12)
+	assert.NoError(t, err)
+
+	faces, err := rfd.postprocess(netOuts, 0.5)
+	assert.NoError(t, err)
+	assert.Len(t, faces, 1)
+
+	anchor := rfd.anchorsFPN["stride32"][1]
+	assert.Equal(t, float32(0.99), faces[0].Score)
+	assert.InDelta(t, (anchor[0]+12*32)/0.5, faces[0].BBox[0], 1e-3)
+	assert.InDelta(t, (anchor[1]+10*32)/0.5, faces[0].BBox[1], 1e-3)
+	assert.InDelta(t, (anchor[2]+12*32)/0.5, faces[0].BBox[2], 1e-3)
+	assert.InDelta(t, (anchor[3]+10*32)/0.5, faces[0].BBox[3], 1e-3)
+
+	// outputs with too few channels or mismatched planes are rejected
+	landmarks := netOuts[2]
+	netOuts[2] = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 4*rfd.numAnchor["stride32"], 20, 20))
+	_, err = rfd.postprocess(netOuts, 0.5)
+	assert.ErrorContains(t, err, "landmark channels")
+	netOuts[2] = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 12*rfd.numAnchor["stride32"], 20, 20))
+	_, err = rfd.postprocess(netOuts, 0.5)
+	assert.ErrorContains(t, err, "landmark channels")
+	netOuts[2] = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 10*rfd.numAnchor["stride32"], 10, 10))
+	_, err = rfd.postprocess(netOuts, 0.5)
+	assert.ErrorContains(t, err, "locations")
+	netOuts[2] = landmarks
+	netOuts[1] = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 2*rfd.numAnchor["stride32"], 20, 20))
+	_, err = rfd.postprocess(netOuts, 0.5)
+	assert.ErrorContains(t, err, "bbox channels")
+	netOuts[1] = tensor.New(tensor.Of(tensor.Float32), tensor.WithShape(1, 4*rfd.numAnchor["stride32"], 20))
+	_, err = rfd.postprocess(netOuts, 0.5)
+	assert.Error(t, err)
 }
 
 func TestUtil(t *testing.T) {
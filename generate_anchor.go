@@ -111,14 +111,12 @@ func GenerateAnchorsFPN2(denseAnchor bool, cfgs map[string]Anchor) ([][][]float6
 		return RPNFeatStride[i] > RPNFeatStride[j]
 	})
 
-	for key, value := range cfgs {
+	// iterate in descending stride order so the result lines up with featStrideFPN
+	for _, stride := range RPNFeatStride {
+		value := cfgs[strconv.Itoa(stride)]
 		baseSize := value.BaseSize
 		ratios := value.Ratio
 		scales := value.Scale
-		stride, err := strconv.Atoi(key)
-		if err != nil {
-			return nil, err
-		}
 		anchor, err := generateAnchors2(float64(baseSize), ratios, scales, stride, denseAnchor)
 		if err != nil {
 			return nil, err
@@ -1,43 +1,66 @@
 package gotritron
 
 import (
+	"github.com/okieraised/gotritron/triton_client"
 	"google.golang.org/grpc"
 	"net/http"
-	"time"
+	"sync"
 )
 
+// HTTP client defaults and routes, see the constants of triton_client.
+//
+// Deprecated: use the triton_client constants.
 const (
-	DefaultHTTPClientReadTimeout                = 5 * time.Second
-	DefaultHTTPClientWriteTimeout               = 5 * time.Second
-	DefaultHTTPClientMaxConnPerHost      int    = 16384
-	HTTPPrefix                           string = "http://"
-	JSONContentType                      string = "application/json"
-	TritonAPIForModelVersionPrefix       string = "/versions/"
-	TritonAPIPrefix                      string = "/v2"
-	TritonAPIForServerIsLive                    = TritonAPIPrefix + "/health/live"
-	TritonAPIForServerIsReady                   = TritonAPIPrefix + "/health/ready"
-	TritonAPIForRepoIndex                       = TritonAPIPrefix + "/repository/index"
-	TritonAPIForRepoModelPrefix                 = TritonAPIPrefix + "/repository/models/"
-	TritonAPIForModelPrefix                     = TritonAPIPrefix + "/models/"
-	TritonAPIForCudaMemoryRegionPrefix          = TritonAPIPrefix + "/cudasharememory/region/"
-	TritonAPIForSystemMemoryRegionPrefix        = TritonAPIPrefix + "/systemsharememory/region/"
+	DefaultHTTPClientReadTimeout         = triton_client.DefaultHTTPClientReadTimeout
+	DefaultHTTPClientWriteTimeout        = triton_client.DefaultHTTPClientWriteTimeout
+	DefaultHTTPClientMaxConnPerHost      = triton_client.DefaultHTTPClientMaxConnPerHost
+	HTTPPrefix                           = triton_client.HTTPPrefix
+	JSONContentType                      = triton_client.JSONContentType
+	TritonAPIForModelVersionPrefix       = triton_client.TritonAPIForModelVersionPrefix
+	TritonAPIPrefix                      = triton_client.TritonAPIPrefix
+	TritonAPIForServerIsLive             = triton_client.TritonAPIForServerIsLive
+	TritonAPIForServerIsReady            = triton_client.TritonAPIForServerIsReady
+	TritonAPIForRepoIndex                = triton_client.TritonAPIForRepoIndex
+	TritonAPIForRepoModelPrefix          = triton_client.TritonAPIForRepoModelPrefix
+	TritonAPIForModelPrefix              = triton_client.TritonAPIForModelPrefix
+	TritonAPIForCudaMemoryRegionPrefix   = triton_client.TritonAPIForCudaMemoryRegionPrefix
+	TritonAPIForSystemMemoryRegionPrefix = triton_client.TritonAPIForSystemMemoryRegionPrefix
 )
 
-var tritonHTTPClient *TritonGRPCClient
+// TritonHTTPClientService is the Triton HTTP client, see triton_client.TritonHTTPClientService.
+type TritonHTTPClientService = triton_client.TritonHTTPClientService
 
-type TritonHTTPClientService struct {
-	serverURL   string
-	httpConn    *grpc.ClientConn
-	httpClient  *http.Client
-	modelConfig interface{}
-	modelName   interface{}
+// NewTritonHTTPClientService inits a new HTTP client, with the package default timeouts when
+// httpClient is nil.
+func NewTritonHTTPClientService(serverURL string, httpClient *http.Client) (*TritonHTTPClientService, error) {
+	return triton_client.NewTritonHTTPClient(serverURL, httpClient)
 }
 
-func GetHTTPInstance() *TritonGRPCClient {
+var (
+	tritonHTTPClientMu sync.Mutex
+	tritonHTTPClient   *TritonHTTPClientService
+)
+
+// GetHTTPInstance returns the HTTP client initialized by NewTritonHTTPClient.
+//
+// Deprecated: use the client returned by NewTritonHTTPClientService.
+func GetHTTPInstance() *TritonHTTPClientService {
+	tritonHTTPClientMu.Lock()
+	defer tritonHTTPClientMu.Unlock()
 	return tritonHTTPClient
 }
 
-func NewTritonHTTPClient(serverURL string, opts []grpc.DialOption) error {
-
+// NewTritonHTTPClient inits the HTTP client returned by GetHTTPInstance. The gRPC options do
+// not apply to HTTP and are ignored.
+//
+// Deprecated: use NewTritonHTTPClientService, which returns an owned client.
+func NewTritonHTTPClient(serverURL string, _ []grpc.DialOption) error {
+	client, err := NewTritonHTTPClientService(serverURL, nil)
+	if err != nil {
+		return err
+	}
+	tritonHTTPClientMu.Lock()
+	defer tritonHTTPClientMu.Unlock()
+	tritonHTTPClient = client
 	return nil
 }
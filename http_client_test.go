@@ -0,0 +1,31 @@
+package gotritron
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTritonHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != TritonAPIForServerIsLive {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewTritonHTTPClientService(server.URL, nil)
+	assert.NoError(t, err)
+	alive, err := client.ServerAliveContext(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, alive)
+
+	// the deprecated constructor keeps initializing the shared instance
+	assert.NoError(t, NewTritonHTTPClient(server.URL, nil))
+	assert.NotNil(t, GetHTTPInstance())
+	ready, err := GetHTTPInstance().ServerReadyContext(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ready)
+}
@@ -0,0 +1,354 @@
+package triton_client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBatcherMaxQueueDelay is how long the first request of a batch waits for more requests
+// by default.
+const DefaultBatcherMaxQueueDelay = 5 * time.Millisecond
+
+// BatcherConfig configures a Batcher.
+type BatcherConfig struct {
+	ModelName    string
+	ModelVersion string
+	// MaxBatchSize caps the batches below the max_batch_size of the model, 0 uses the model's.
+	MaxBatchSize int
+	// MaxQueueDelay is how long the first request of a batch waits for more requests before the
+	// batch is sent, it defaults to DefaultBatcherMaxQueueDelay.
+	MaxQueueDelay time.Duration
+	// Outputs are requested for every batch, all outputs are returned when empty.
+	Outputs []*InferRequestedOutput
+}
+
+// Batcher coalesces concurrent inference requests for a batching model (max_batch_size > 0)
+// into batches, for models without server-side dynamic batching. Requests are concatenated
+// along the batch dimension, sent as one request once the batch is full or the first request
+// waited MaxQueueDelay, and the outputs are split back to every request. A failed batch fails
+// every request in it. A Batcher runs no goroutine while idle and needs no closing.
+type Batcher struct {
+	client InferenceClient
+	config BatcherConfig
+
+	mu      sync.Mutex
+	current *pendingBatch
+}
+
+type pendingBatch struct {
+	members    []*batchMember
+	size       int64
+	timer      *time.Timer
+	dispatched bool
+}
+
+type batchMember struct {
+	ctx    context.Context
+	inputs []*InferInput
+	size   int64
+	result chan batchResult
+}
+
+type batchResult struct {
+	result *InferResult
+	err    error
+}
+
+// NewBatcher returns a batcher sending batches through client.
+func NewBatcher(client InferenceClient, config BatcherConfig) *Batcher {
+	if config.MaxQueueDelay <= 0 {
+		config.MaxQueueDelay = DefaultBatcherMaxQueueDelay
+	}
+	return &Batcher{client: client, config: config}
+}
+
+// Infer runs inference on inputs as part of a batch and returns the outputs of these inputs.
+// Every input must have its data set, with the batch dimension first and the same batch size.
+// Requests with different input names, datatypes or non-batch dimensions go in different
+// batches.
+func (b *Batcher) Infer(ctx context.Context, inputs []*InferInput) (*InferResult, error) {
+	size, err := batchSize(inputs)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := b.maxBatchSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("batch size %d exceeds the max batch size %d of model %s", size, maxSize, b.config.ModelName)
+	}
+
+	member := &batchMember{ctx: ctx, inputs: inputs, size: size, result: make(chan batchResult, 1)}
+	b.mu.Lock()
+	if b.current != nil && (!b.current.compatible(member) || b.current.size+size > maxSize) {
+		go b.dispatch(b.current)
+		b.current = nil
+	}
+	if b.current == nil {
+		batch := &pendingBatch{}
+		batch.timer = time.AfterFunc(b.config.MaxQueueDelay, func() { b.dispatch(batch) })
+		b.current = batch
+	}
+	b.current.members = append(b.current.members, member)
+	b.current.size += size
+	if b.current.size == maxSize {
+		go b.dispatch(b.current)
+		b.current = nil
+	}
+	b.mu.Unlock()
+
+	select {
+	case result := <-member.result:
+		return result.result, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// batchSize checks the inputs of a request and returns their batch size.
+func batchSize(inputs []*InferInput) (int64, error) {
+	if len(inputs) == 0 {
+		return 0, errors.New("batched request has no inputs")
+	}
+	var size int64
+	for _, input := range inputs {
+		if input.raw == nil {
+			return 0, fmt.Errorf("input %s: data is not set", input.name)
+		}
+		if len(input.shape) == 0 || input.shape[0] < 1 {
+			return 0, fmt.Errorf("input %s: shape %s has no batch dimension", input.name, model_config.DimsListToString(input.shape))
+		}
+		if size != 0 && input.shape[0] != size {
+			return 0, fmt.Errorf("input %s: batch size %d differs from %d", input.name, input.shape[0], size)
+		}
+		size = input.shape[0]
+	}
+	return size, nil
+}
+
+// maxBatchSize returns the size batches are filled up to.
+func (b *Batcher) maxBatchSize(ctx context.Context) (int64, error) {
+	modelConf, err := b.client.CachedModelConfigurationContext(ctx, b.config.ModelName, b.config.ModelVersion)
+	if err != nil {
+		return 0, err
+	}
+	maxSize := int64(modelConf.Config.MaxBatchSize)
+	if maxSize <= 0 {
+		return 0, fmt.Errorf("model %s does not support batching", b.config.ModelName)
+	}
+	if b.config.MaxBatchSize > 0 && int64(b.config.MaxBatchSize) < maxSize {
+		maxSize = int64(b.config.MaxBatchSize)
+	}
+	return maxSize, nil
+}
+
+// compatible reports whether member can be concatenated with the members of the batch.
+func (p *pendingBatch) compatible(member *batchMember) bool {
+	first := p.members[0].inputs
+	if len(first) != len(member.inputs) {
+		return false
+	}
+	for idx, input := range member.inputs {
+		if input.name != first[idx].name || input.datatype != first[idx].datatype || !model_config.CompareDims(input.shape[1:], first[idx].shape[1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// dispatch sends the batch unless it has already been sent.
+func (b *Batcher) dispatch(batch *pendingBatch) {
+	b.mu.Lock()
+	if batch.dispatched {
+		b.mu.Unlock()
+		return
+	}
+	batch.dispatched = true
+	if b.current == batch {
+		b.current = nil
+	}
+	b.mu.Unlock()
+
+	batch.timer.Stop()
+	b.execute(batch.members)
+}
+
+// execute sends the members still waiting as one request and splits the outputs between them.
+func (b *Batcher) execute(members []*batchMember) {
+	waiting := members[:0:0]
+	for _, member := range members {
+		if err := member.ctx.Err(); err != nil {
+			member.result <- batchResult{err: err}
+			continue
+		}
+		waiting = append(waiting, member)
+	}
+	if len(waiting) == 0 {
+		return
+	}
+
+	ctx, cancel := batchContext(waiting)
+	defer cancel()
+	results, err := b.infer(ctx, waiting)
+	for idx, member := range waiting {
+		if err != nil {
+			member.result <- batchResult{err: err}
+			continue
+		}
+		member.result <- batchResult{result: results[idx]}
+	}
+}
+
+// batchContext returns a context that is cancelled once the contexts of all the members are
+// done, so a member that gives up early does not cut the batch short for the others. It has
+// the latest deadline of the members when all of them have one.
+func batchContext(members []*batchMember) (context.Context, context.CancelFunc) {
+	var latest time.Time
+	for _, member := range members {
+		deadline, ok := member.ctx.Deadline()
+		if !ok {
+			latest = time.Time{}
+			break
+		}
+		if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if latest.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), latest)
+	}
+
+	var remaining atomic.Int32
+	remaining.Store(int32(len(members)))
+	stops := make([]func() bool, len(members))
+	for idx, member := range members {
+		stops[idx] = context.AfterFunc(member.ctx, func() {
+			if remaining.Add(-1) == 0 {
+				cancel()
+			}
+		})
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
+
+// infer concatenates the inputs of the members, runs inference and splits the outputs.
+func (b *Batcher) infer(ctx context.Context, members []*batchMember) ([]*InferResult, error) {
+	var total int64
+	for _, member := range members {
+		total += member.size
+	}
+
+	inputs := make([]*InferInput, len(members[0].inputs))
+	for idx, first := range members[0].inputs {
+		shape := append([]int64{total}, first.shape[1:]...)
+		var raw []byte
+		for _, member := range members {
+			raw = append(raw, member.inputs[idx].raw...)
+		}
+		inputs[idx] = NewInferInput(first.name, shape, first.datatype)
+		if err := inputs[idx].SetRawData(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := b.client.Infer(ctx, b.config.ModelName, b.config.ModelVersion, inputs, b.config.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := result.Response()
+	responses := make([]*grpc_client.ModelInferResponse, len(members))
+	for idx := range members {
+		responses[idx] = &grpc_client.ModelInferResponse{
+			ModelName:    response.ModelName,
+			ModelVersion: response.ModelVersion,
+			Parameters:   response.Parameters,
+		}
+	}
+	for _, output := range response.Outputs {
+		raw, err := result.RawData(output.Name)
+		if err != nil {
+			return nil, err
+		}
+		offsets, err := batchOffsets(output, raw, total)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", output.Name, err)
+		}
+
+		var item int64
+		for idx, member := range members {
+			responses[idx].Outputs = append(responses[idx].Outputs, &grpc_client.ModelInferResponse_InferOutputTensor{
+				Name:       output.Name,
+				Datatype:   output.Datatype,
+				Shape:      append([]int64{member.size}, output.Shape[1:]...),
+				Parameters: output.Parameters,
+			})
+			responses[idx].RawOutputContents = append(responses[idx].RawOutputContents, raw[offsets[item]:offsets[item+member.size]])
+			item += member.size
+		}
+	}
+
+	results := make([]*InferResult, len(members))
+	for idx, memberResponse := range responses {
+		results[idx] = NewInferResult(memberResponse)
+	}
+	return results, nil
+}
+
+// batchOffsets returns the byte offset of every item of a batched output, followed by the size
+// of the output.
+func batchOffsets(output *grpc_client.ModelInferResponse_InferOutputTensor, raw []byte, total int64) ([]int, error) {
+	if len(output.Shape) == 0 || output.Shape[0] != total {
+		return nil, fmt.Errorf("shape %s does not have the batch size %d first", model_config.DimsListToString(output.Shape), total)
+	}
+
+	offsets := make([]int, total+1)
+	if output.Datatype != DataTypeBytes {
+		if int64(len(raw))%total != 0 {
+			return nil, fmt.Errorf("%d bytes cannot be split into %d items", len(raw), total)
+		}
+		itemSize := len(raw) / int(total)
+		for item := range offsets {
+			offsets[item] = item * itemSize
+		}
+		return offsets, nil
+	}
+
+	// BYTES elements are length prefixed, every item holds the same number of them
+	elements := int64(1)
+	if len(output.Shape) > 1 {
+		elements = model_config.GetElementCount(output.Shape[1:])
+	}
+	var offset int
+	for item := int64(0); item < total; item++ {
+		offsets[item] = offset
+		for element := int64(0); element < elements; element++ {
+			if offset+4 > len(raw) {
+				return nil, errors.New("truncated BYTES data")
+			}
+			offset += 4 + int(binary.LittleEndian.Uint32(raw[offset:]))
+		}
+	}
+	if offset != len(raw) {
+		return nil, fmt.Errorf("BYTES data does not match shape %s", model_config.DimsListToString(output.Shape))
+	}
+	offsets[total] = offset
+	return offsets, nil
+}
@@ -0,0 +1,221 @@
+package triton_client
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchingModel doubles its FP32 input "x" into output "y" and names every batch item in the
+// BYTES output "name", e.g. "item0".
+var batchingModel = &tritontest.Model{
+	Config: &grpc_client.ModelConfig{
+		Name:         "batching",
+		MaxBatchSize: 4,
+		Input:        []*grpc_client.ModelInput{{Name: "x", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{-1}}},
+		Output: []*grpc_client.ModelOutput{
+			{Name: "y", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{-1}},
+			{Name: "name", DataType: grpc_client.DataType_TYPE_STRING, Dims: []int64{1}},
+		},
+	},
+	Infer: func(_ context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+		raw := append([]byte(nil), req.RawInputContents[0]...)
+		for idx := 0; idx < len(raw); idx += 4 {
+			value := math.Float32frombits(binary.LittleEndian.Uint32(raw[idx:]))
+			binary.LittleEndian.PutUint32(raw[idx:], math.Float32bits(2*value))
+		}
+		var names [][]byte
+		for item := int64(0); item < req.Inputs[0].Shape[0]; item++ {
+			names = append(names, []byte(fmt.Sprintf("item%d", item)))
+		}
+		return &grpc_client.ModelInferResponse{
+			Outputs: []*grpc_client.ModelInferResponse_InferOutputTensor{
+				{Name: "y", Datatype: DataTypeFP32, Shape: req.Inputs[0].Shape},
+				{Name: "name", Datatype: DataTypeBytes, Shape: []int64{req.Inputs[0].Shape[0], 1}},
+			},
+			RawOutputContents: [][]byte{raw, encodeBytes(names)},
+		}, nil
+	},
+}
+
+func batchInput(t *testing.T, values ...float32) *InferInput {
+	input := NewInferInput("x", []int64{1, int64(len(values))}, DataTypeFP32)
+	assert.NoError(t, input.SetData(values))
+	return input
+}
+
+func TestBatcher(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(batchingModel)
+
+	// a full batch is sent at once
+	batcher := NewBatcher(client, BatcherConfig{ModelName: "batching", MaxQueueDelay: time.Hour})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := batcher.Infer(ctx, []*InferInput{batchInput(t, float32(i), float32(10*i))})
+			assert.NoError(t, err)
+			y, err := result.AsFloat32("y")
+			assert.NoError(t, err)
+			assert.Equal(t, []float32{float32(2 * i), float32(20 * i)}, y)
+			shape, err := result.Shape("y")
+			assert.NoError(t, err)
+			assert.Equal(t, []int64{1, 2}, shape)
+			names, err := result.AsStrings("name")
+			assert.NoError(t, err)
+			assert.Len(t, names, 1)
+		}(i)
+	}
+	wg.Wait()
+	requests := server.InferRequests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, []int64{4, 2}, requests[0].Inputs[0].Shape)
+	server.ResetRequests()
+
+	// a partial batch is sent after the queue delay, requests may hold several items
+	batcher = NewBatcher(client, BatcherConfig{ModelName: "batching", MaxQueueDelay: 20 * time.Millisecond})
+	results := make([]*InferResult, 2)
+	for i, input := range []*InferInput{batchInput(t, 1), NewInferInput("x", []int64{2, 1}, DataTypeFP32)} {
+		if i == 1 {
+			assert.NoError(t, input.SetData([]float32{2, 3}))
+		}
+		wg.Add(1)
+		go func(i int, input *InferInput) {
+			defer wg.Done()
+			var err error
+			results[i], err = batcher.Infer(ctx, []*InferInput{input})
+			assert.NoError(t, err)
+		}(i, input)
+	}
+	wg.Wait()
+	assert.Len(t, server.InferRequests(), 1)
+	y, err := results[1].AsFloat32("y")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{4, 6}, y)
+	names, err := results[1].AsStrings("name")
+	assert.NoError(t, err)
+	assert.Len(t, names, 2)
+	names0, err := results[0].AsStrings("name")
+	assert.NoError(t, err)
+	assert.NotEqual(t, names0[0], names[0])
+	assert.NotEqual(t, names0[0], names[1])
+	server.ResetRequests()
+
+	// requests with other non-batch dimensions go in another batch
+	for _, values := range [][]float32{{1}, {1, 2}} {
+		wg.Add(1)
+		go func(values []float32) {
+			defer wg.Done()
+			result, err := batcher.Infer(ctx, []*InferInput{batchInput(t, values...)})
+			assert.NoError(t, err)
+			y, err := result.AsFloat32("y")
+			assert.NoError(t, err)
+			assert.Len(t, y, len(values))
+		}(values)
+	}
+	wg.Wait()
+	assert.Len(t, server.InferRequests(), 2)
+}
+
+func TestBatcher_Errors(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(batchingModel)
+	server.AddModel(&tritontest.Model{Config: &grpc_client.ModelConfig{Name: "single"}})
+	batcher := NewBatcher(client, BatcherConfig{ModelName: "batching", MaxBatchSize: 2, MaxQueueDelay: time.Hour})
+
+	// a failed batch fails every member
+	server.InjectError("ModelInfer", status.Error(codes.Internal, "out of memory"))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := batcher.Infer(ctx, []*InferInput{batchInput(t, 1)})
+			assert.Equal(t, codes.Internal, status.Code(err))
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, server.InferRequests(), 1)
+	server.InjectError("ModelInfer", nil)
+
+	// members whose context ends stop waiting
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := batcher.Infer(shortCtx, []*InferInput{batchInput(t, 1)})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	input := NewInferInput("x", []int64{3, 1}, DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{1, 2, 3}))
+	_, err = batcher.Infer(ctx, []*InferInput{input})
+	assert.ErrorContains(t, err, "exceeds the max batch size 2")
+	_, err = batcher.Infer(ctx, nil)
+	assert.Error(t, err)
+	_, err = batcher.Infer(ctx, []*InferInput{NewInferInput("x", []int64{1, 1}, DataTypeFP32)})
+	assert.ErrorContains(t, err, "data is not set")
+	_, err = NewBatcher(client, BatcherConfig{ModelName: "single"}).Infer(ctx, []*InferInput{batchInput(t, 1)})
+	assert.ErrorContains(t, err, "does not support batching")
+}
+
+func TestBatchContext(t *testing.T) {
+	// the batch is cancelled once every member is done, with or without deadlines
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithTimeout(context.Background(), time.Hour)
+	ctx, cancel := batchContext([]*batchMember{{ctx: first}, {ctx: second}})
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	cancelFirst()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ctx.Err())
+	cancelSecond()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("batch context is not cancelled once every member is done")
+	}
+
+	// the latest deadline is used when every member has one
+	short, cancelShort := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelShort()
+	long, cancelLong := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLong()
+	ctx, cancel = batchContext([]*batchMember{{ctx: short}, {ctx: long}})
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	longDeadline, _ := long.Deadline()
+	assert.Equal(t, longDeadline, deadline)
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestBatchOffsets(t *testing.T) {
+	offsets, err := batchOffsets(&grpc_client.ModelInferResponse_InferOutputTensor{Datatype: DataTypeFP32, Shape: []int64{3, 2}}, make([]byte, 24), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 8, 16, 24}, offsets)
+
+	raw := encodeBytes([][]byte{[]byte("a"), []byte("bc"), []byte("def"), {}})
+	offsets, err = batchOffsets(&grpc_client.ModelInferResponse_InferOutputTensor{Datatype: DataTypeBytes, Shape: []int64{2, 2}}, raw, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 11, 22}, offsets)
+	offsets, err = batchOffsets(&grpc_client.ModelInferResponse_InferOutputTensor{Datatype: DataTypeBytes, Shape: []int64{4}}, raw, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 5, 11, 18, 22}, offsets)
+
+	_, err = batchOffsets(&grpc_client.ModelInferResponse_InferOutputTensor{Datatype: DataTypeBytes, Shape: []int64{2, 3}}, raw, 2)
+	assert.Error(t, err)
+	_, err = batchOffsets(&grpc_client.ModelInferResponse_InferOutputTensor{Datatype: DataTypeFP32, Shape: []int64{2}}, make([]byte, 8), 3)
+	assert.Error(t, err)
+}
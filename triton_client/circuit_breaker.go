@@ -0,0 +1,375 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker of a model.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through while the model is healthy.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests fast after the model degraded.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to decide whether the model recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitOpenError is returned without contacting Triton while the circuit of the model is open.
+type CircuitOpenError struct {
+	Model string
+	// RetryAfter is how long until the circuit lets a probe request through.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit of model %s is open, retry after %s", e.Model, e.RetryAfter)
+}
+
+// OverloadedError is returned when the model already has the maximum number of requests in
+// flight and excess requests are not queued.
+type OverloadedError struct {
+	Model       string
+	MaxInFlight int
+}
+
+func (e *OverloadedError) Error() string {
+	return fmt.Sprintf("model %s has %d requests in flight", e.Model, e.MaxInFlight)
+}
+
+// Circuit breaker defaults.
+const (
+	DefaultCircuitWindow       = 10 * time.Second
+	DefaultCircuitMinRequests  = 20
+	DefaultCircuitErrorRate    = 0.5
+	DefaultCircuitOpenDuration = 30 * time.Second
+)
+
+// circuitBuckets is the number of buckets the rolling window is divided into.
+const circuitBuckets = 10
+
+// CircuitBreakerConfig configures the circuit breaker and in-flight limit every model gets,
+// zero values take the defaults. The circuit opens when, over the rolling Window, at least
+// MinRequests completed and the rate of failures reaches ErrorRate or the rate of calls slower
+// than SlowCallDuration reaches SlowCallRate. After OpenDuration it lets HalfOpenRequests probe
+// requests through, and closes again when they all succeed.
+type CircuitBreakerConfig struct {
+	Window      time.Duration
+	MinRequests int
+	ErrorRate   float64
+	// SlowCallDuration and SlowCallRate open the circuit on latency, 0 disables it.
+	SlowCallDuration time.Duration
+	SlowCallRate     float64
+	OpenDuration     time.Duration
+	// HalfOpenRequests defaults to 1.
+	HalfOpenRequests int
+	// MaxInFlight limits the requests in flight per model, 0 is unlimited. Excess requests are
+	// rejected with an OverloadedError, or wait for a slot until their context ends when
+	// QueueExcess is set.
+	MaxInFlight int
+	QueueExcess bool
+	// IsFailure reports whether an error counts as a failure of the model. By default errors
+	// caused by the request itself, e.g. InvalidArgument, and cancellations do not. Errors
+	// reported in-band on a stream are a *StreamError, classified by their code.
+	IsFailure func(err error) bool
+	// OnStateChange is called when the circuit of a model changes state, it must not block.
+	OnStateChange func(model string, from, to CircuitState)
+}
+
+// isCircuitFailure is the default CircuitBreakerConfig.IsFailure.
+func isCircuitFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented:
+		return false
+	}
+	return true
+}
+
+// isCancellation reports whether the request was abandoned by the caller, or left without a
+// response by a stream that was closed, which says nothing about the health of the model.
+func isCancellation(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, ErrStreamClosed) || status.Code(err) == codes.Canceled
+}
+
+// circuitBreakers holds the circuit breaker of every model a client sent requests to.
+type circuitBreakers struct {
+	config CircuitBreakerConfig
+
+	mu     sync.Mutex
+	models map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(config CircuitBreakerConfig) *circuitBreakers {
+	if config.Window <= 0 {
+		config.Window = DefaultCircuitWindow
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = DefaultCircuitMinRequests
+	}
+	if config.ErrorRate <= 0 {
+		config.ErrorRate = DefaultCircuitErrorRate
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = DefaultCircuitOpenDuration
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isCircuitFailure
+	}
+	return &circuitBreakers{config: config, models: make(map[string]*circuitBreaker)}
+}
+
+func (b *circuitBreakers) get(model string) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.models[model]
+	if !ok {
+		breaker = &circuitBreaker{model: model, config: &b.config}
+		if b.config.MaxInFlight > 0 {
+			breaker.slots = make(chan struct{}, b.config.MaxInFlight)
+		}
+		b.models[model] = breaker
+	}
+	return breaker
+}
+
+// acquire lets a request to the model through or fails it fast. The returned function must be
+// called with the result of the request once it completes.
+func (b *circuitBreakers) acquire(ctx context.Context, model string) (func(err error), error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+	return b.get(model).acquire(ctx)
+}
+
+type circuitBucket struct {
+	epoch    int64
+	total    int
+	failures int
+	slow     int
+}
+
+type circuitBreaker struct {
+	model  string
+	config *CircuitBreakerConfig
+	slots  chan struct{}
+
+	mu        sync.Mutex
+	state     CircuitState
+	openedAt  time.Time
+	probes    int
+	succeeded int
+	buckets   [circuitBuckets]circuitBucket
+}
+
+func (c *circuitBreaker) acquire(ctx context.Context) (func(err error), error) {
+	probe, err := c.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			if !c.config.QueueExcess {
+				c.abandon(probe)
+				return nil, &OverloadedError{Model: c.model, MaxInFlight: c.config.MaxInFlight}
+			}
+			select {
+			case c.slots <- struct{}{}:
+			case <-ctx.Done():
+				c.abandon(probe)
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	start := time.Now()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			if c.slots != nil {
+				<-c.slots
+			}
+			c.record(err, time.Since(start), probe)
+		})
+	}, nil
+}
+
+// allow checks the state of the circuit and reports whether the request is a half-open probe.
+func (c *circuitBreaker) allow() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitOpen {
+		retryAfter := c.config.OpenDuration - time.Since(c.openedAt)
+		if retryAfter > 0 {
+			return false, &CircuitOpenError{Model: c.model, RetryAfter: retryAfter}
+		}
+		c.setState(CircuitHalfOpen)
+	}
+	if c.state == CircuitHalfOpen {
+		if c.probes >= c.config.HalfOpenRequests {
+			return false, &CircuitOpenError{Model: c.model}
+		}
+		c.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// abandon gives back a probe that was never sent.
+func (c *circuitBreaker) abandon(probe bool) {
+	if !probe {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == CircuitHalfOpen {
+		c.probes--
+	}
+}
+
+// record accounts for a completed request.
+func (c *circuitBreaker) record(err error, latency time.Duration, probe bool) {
+	if isCancellation(err) {
+		c.abandon(probe)
+		return
+	}
+	failure := err != nil && c.config.IsFailure(err)
+	slow := c.config.SlowCallDuration > 0 && latency >= c.config.SlowCallDuration
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if !probe {
+			return
+		}
+		if failure || slow {
+			c.open()
+			return
+		}
+		c.succeeded++
+		if c.succeeded >= c.config.HalfOpenRequests {
+			c.buckets = [circuitBuckets]circuitBucket{}
+			c.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		c.add(failure, slow)
+		total, failures, slowCalls := c.counts()
+		if total < c.config.MinRequests {
+			return
+		}
+		if float64(failures) >= c.config.ErrorRate*float64(total) ||
+			c.config.SlowCallRate > 0 && float64(slowCalls) >= c.config.SlowCallRate*float64(total) {
+			c.open()
+		}
+	}
+}
+
+// epoch returns the index of the current bucket of the window since the Unix epoch. Buckets
+// are at least a nanosecond wide, for windows shorter than circuitBuckets nanoseconds.
+func (c *circuitBreaker) epoch() int64 {
+	return time.Now().UnixNano() / max(int64(c.config.Window/circuitBuckets), 1)
+}
+
+// add counts a request in the current bucket of the window, c.mu must be held.
+func (c *circuitBreaker) add(failure, slow bool) {
+	epoch := c.epoch()
+	bucket := &c.buckets[epoch%circuitBuckets]
+	if bucket.epoch != epoch {
+		*bucket = circuitBucket{epoch: epoch}
+	}
+	bucket.total++
+	if failure {
+		bucket.failures++
+	}
+	if slow {
+		bucket.slow++
+	}
+}
+
+// counts sums the buckets within the window, c.mu must be held.
+func (c *circuitBreaker) counts() (total, failures, slow int) {
+	epoch := c.epoch()
+	for _, bucket := range c.buckets {
+		if epoch-bucket.epoch < circuitBuckets {
+			total += bucket.total
+			failures += bucket.failures
+			slow += bucket.slow
+		}
+	}
+	return total, failures, slow
+}
+
+// open opens the circuit, c.mu must be held.
+func (c *circuitBreaker) open() {
+	c.openedAt = time.Now()
+	c.setState(CircuitOpen)
+}
+
+// setState changes the state and resets the probes, c.mu must be held.
+func (c *circuitBreaker) setState(state CircuitState) {
+	from := c.state
+	c.state = state
+	c.probes = 0
+	c.succeeded = 0
+	if from != state && c.config.OnStateChange != nil {
+		c.config.OnStateChange(c.model, from, state)
+	}
+}
+
+func (c *circuitBreaker) currentState() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= c.config.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// SetCircuitBreaker gives every model its own circuit breaker and in-flight limit for unary and
+// streamed inference, nil disables them. Setting a config resets the state of every model.
+func (tc *TritonGRPCClient) SetCircuitBreaker(config *CircuitBreakerConfig) {
+	if config == nil {
+		tc.circuitBreakers.Store(nil)
+		return
+	}
+	tc.circuitBreakers.Store(newCircuitBreakers(*config))
+}
+
+// CircuitState returns the state of the circuit breaker of the model, closed when circuit
+// breakers are disabled.
+func (tc *TritonGRPCClient) CircuitState(modelName string) CircuitState {
+	breakers := tc.circuitBreakers.Load()
+	if breakers == nil {
+		return CircuitClosed
+	}
+	return breakers.get(modelName).currentState()
+}
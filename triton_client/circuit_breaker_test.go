@@ -0,0 +1,232 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stateChanges records the state changes of circuits.
+type stateChanges struct {
+	mu      sync.Mutex
+	changes []CircuitState
+}
+
+func (s *stateChanges) record(_ string, _, to CircuitState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, to)
+}
+
+func (s *stateChanges) get() []CircuitState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CircuitState(nil), s.changes...)
+}
+
+func TestTritonGRPCClient_CircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	server, client := newRetryTestClient(t, nil)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "other"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	server.InjectErrors("ModelInfer", busyErrors(codes.Unavailable, 6)...)
+	changes := &stateChanges{}
+	client.SetCircuitBreaker(&CircuitBreakerConfig{
+		MinRequests:   4,
+		ErrorRate:     0.5,
+		OpenDuration:  50 * time.Millisecond,
+		OnStateChange: changes.record,
+	})
+	request := &grpc_client.ModelInferRequest{ModelName: "model"}
+
+	// 2 failures out of 4 requests open the circuit
+	for i := 0; i < 2; i++ {
+		_, err := client.ModelInfer(ctx, request)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, CircuitClosed, client.CircuitState("model"))
+	}
+	// every model has its own circuit
+	_, err := client.ModelInfer(ctx, &grpc_client.ModelInferRequest{ModelName: "other"})
+	assert.Error(t, err)
+	assert.Equal(t, CircuitClosed, client.CircuitState("other"))
+	_, err = client.ModelInfer(ctx, request)
+	assert.Error(t, err)
+	assert.Equal(t, CircuitClosed, client.CircuitState("model"))
+	_, err = client.ModelInfer(ctx, request)
+	assert.Error(t, err)
+	assert.Equal(t, CircuitOpen, client.CircuitState("model"))
+
+	// requests fail fast while open
+	calls := len(server.InferRequests())
+	_, err = client.ModelGRPCInferContext(ctx, nil, nil, nil, "model", "")
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "model", openErr.Model)
+	assert.Greater(t, openErr.RetryAfter, time.Duration(0))
+	assert.Len(t, server.InferRequests(), calls)
+
+	// a failed probe opens it again
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, client.CircuitState("model"))
+	_, err = client.ModelInfer(ctx, request)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, CircuitOpen, client.CircuitState("model"))
+
+	// a successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	_, err = client.ModelInfer(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, client.CircuitState("model"))
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, changes.get())
+
+	client.SetCircuitBreaker(nil)
+	assert.Equal(t, CircuitClosed, client.CircuitState("model"))
+}
+
+func TestTritonGRPCClient_CircuitBreakerSlowCalls(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "slow"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	client.SetCircuitBreaker(&CircuitBreakerConfig{MinRequests: 2, SlowCallDuration: 20 * time.Millisecond, SlowCallRate: 0.5})
+
+	_, err := client.Infer(ctx, "slow", "", nil, nil)
+	assert.NoError(t, err)
+	_, err = client.Infer(ctx, "slow", "", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, client.CircuitState("slow"))
+
+	server.InjectLatency("ModelInfer", 30*time.Millisecond)
+	_, err = client.Infer(ctx, "slow", "", nil, nil)
+	assert.NoError(t, err)
+	_, err = client.Infer(ctx, "slow", "", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitOpen, client.CircuitState("slow"))
+}
+
+func TestTritonGRPCClient_CircuitBreakerShortWindow(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Config: &grpc_client.ModelConfig{Name: "face"}})
+	client.SetCircuitBreaker(&CircuitBreakerConfig{Window: time.Nanosecond, MinRequests: 1})
+
+	// a window shorter than its buckets must not divide by zero
+	server.InjectError("ModelInfer", status.Error(codes.Internal, "model crashed"))
+	_, err := client.Infer(ctx, "face", "", nil, nil)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestTritonGRPCClient_MaxInFlight(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "busy"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	server.InjectLatency("ModelInfer", 100*time.Millisecond)
+
+	// excess requests are rejected
+	client.SetCircuitBreaker(&CircuitBreakerConfig{MaxInFlight: 1})
+	done := make(chan error)
+	go func() {
+		_, err := client.Infer(ctx, "busy", "", nil, nil)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return len(server.InferRequests()) == 1 }, time.Second, time.Millisecond)
+	_, err := client.Infer(ctx, "busy", "", nil, nil)
+	var overloaded *OverloadedError
+	assert.True(t, errors.As(err, &overloaded))
+	assert.Equal(t, 1, overloaded.MaxInFlight)
+	assert.NoError(t, <-done)
+	// the slot is given back
+	_, err = client.Infer(ctx, "busy", "", nil, nil)
+	assert.NoError(t, err)
+
+	// or queued
+	client.SetCircuitBreaker(&CircuitBreakerConfig{MaxInFlight: 1, QueueExcess: true})
+	server.ResetRequests()
+	go func() {
+		_, err := client.Infer(ctx, "busy", "", nil, nil)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return len(server.InferRequests()) == 1 }, time.Second, time.Millisecond)
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Infer(shortCtx, "busy", "", nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = client.Infer(ctx, "busy", "", nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, <-done)
+}
+
+func TestInferStream_CircuitBreaker(t *testing.T) {
+	_, client := newStreamTestClient(t)
+	client.SetCircuitBreaker(&CircuitBreakerConfig{MinRequests: 2, MaxInFlight: 4})
+
+	var wg sync.WaitGroup
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *StreamResult) {
+		if result.Err != nil || result.IsFinal() {
+			wg.Done()
+		}
+	})
+	assert.NoError(t, err)
+
+	// decoupled responses complete their request on the final one
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo"})
+		assert.NoError(t, err)
+		wg.Wait()
+	}
+	assert.Equal(t, CircuitClosed, client.CircuitState("echo"))
+
+	// bad requests do not open the circuit
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "missing"})
+		assert.NoError(t, err)
+		wg.Wait()
+	}
+	assert.Equal(t, CircuitClosed, client.CircuitState("missing"))
+
+	// failures reported for streamed requests do
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "failing"})
+		assert.NoError(t, err)
+		wg.Wait()
+	}
+	assert.Equal(t, CircuitOpen, client.CircuitState("failing"))
+	_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "failing"})
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+
+	assert.NoError(t, stream.Close())
+	assert.Empty(t, stream.pending)
+
+	// requests left without a response by a closed stream do not count
+	breakers := newCircuitBreakers(CircuitBreakerConfig{MinRequests: 1})
+	for i := 0; i < 2; i++ {
+		done, err := breakers.acquire(context.Background(), "echo")
+		assert.NoError(t, err)
+		done(ErrStreamClosed)
+	}
+	assert.Equal(t, CircuitClosed, breakers.get("echo").currentState())
+}
@@ -0,0 +1,66 @@
+package triton_client
+
+import (
+	"context"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"time"
+)
+
+// InferenceClient is the transport independent part of the Triton API, implemented by both
+// TritonGRPCClient and TritonHTTPClientService. Model wrappers should depend on it rather than
+// on a concrete client so the transport can be chosen by configuration and faked in tests.
+type InferenceClient interface {
+	ServerAliveContext(ctx context.Context) (bool, error)
+	ServerReadyContext(ctx context.Context) (bool, error)
+	ModelReadyContext(ctx context.Context, modelName, modelVersion string) (bool, error)
+	WaitForModelReady(ctx context.Context, modelName, modelVersion string, pollInterval time.Duration) error
+	ServerMetadataContext(ctx context.Context) (*grpc_client.ServerMetadataResponse, error)
+	ModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error)
+	GetModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error)
+	CachedModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error)
+	CachedModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error)
+	InvalidateModelCache(modelName string)
+	ModelInferStatsContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelStatisticsResponse, error)
+	ModelRepositoryIndexContext(ctx context.Context, repoName string, isReady bool) (*grpc_client.RepositoryIndexResponse, error)
+	ModelLoadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error
+	ModelUnloadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error
+	ModelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error)
+	Infer(ctx context.Context, modelName, modelVersion string, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error)
+	Disconnect() error
+}
+
+var (
+	_ InferenceClient = (*TritonGRPCClient)(nil)
+	_ InferenceClient = (*TritonHTTPClientService)(nil)
+)
+
+// DefaultModelReadyPollInterval is the interval of WaitForModelReady when none is given.
+const DefaultModelReadyPollInterval = time.Second
+
+// waitForModelReady polls the model every pollInterval, DefaultModelReadyPollInterval when not
+// positive, until it reports ready or ctx ends. Errors while polling, e.g. while the model is
+// still loading, do not stop the wait.
+func waitForModelReady(ctx context.Context, client InferenceClient, modelName, modelVersion string, pollInterval time.Duration) error {
+	if pollInterval <= 0 {
+		pollInterval = DefaultModelReadyPollInterval
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ready, err := client.ModelReadyContext(ctx, modelName, modelVersion)
+		if err == nil && ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("model %s is not ready: %w, last error: %v", modelName, ctx.Err(), err)
+			}
+			return fmt.Errorf("model %s is not ready: %w", modelName, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package triton_client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by ClientConfigFromEnv.
+const (
+	EnvServerURL     = "TRITON_SERVER_URL"
+	EnvTLS           = "TRITON_TLS"
+	EnvTLSCAFile     = "TRITON_TLS_CA_FILE"
+	EnvTLSCertFile   = "TRITON_TLS_CERT_FILE"
+	EnvTLSKeyFile    = "TRITON_TLS_KEY_FILE"
+	EnvTLSServerName = "TRITON_TLS_SERVER_NAME"
+	EnvBearerToken   = "TRITON_BEARER_TOKEN"
+	EnvAPIKey        = "TRITON_API_KEY"
+	EnvAPIKeyHeader  = "TRITON_API_KEY_HEADER"
+	EnvMetadata      = "TRITON_METADATA"
+	EnvCompression   = "TRITON_COMPRESSION"
+
+	EnvAllowInsecureCredentials = "TRITON_ALLOW_INSECURE_CREDENTIALS"
+)
+
+// DefaultAPIKeyHeader is the header carrying ClientConfig.APIKey by default.
+const DefaultAPIKeyHeader = "x-api-key"
+
+// CompressionGzip compresses requests with gzip.
+const CompressionGzip = gzip.Name
+
+// TLSConfig configures TLS to Triton. The server is verified against the CAs in CAFile, or the
+// system roots when empty. CertFile and KeyFile present a client certificate for mTLS.
+type TLSConfig struct {
+	CAFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ServerName overrides the name the server certificate is verified against, e.g. when
+	// dialing an ingress by IP.
+	ServerName string `json:"server_name"`
+}
+
+// ClientConfig is a typed configuration of the connection to Triton, turned into dial options
+// by GRPCDialOptions. It can be built as a struct, read from the environment with
+// ClientConfigFromEnv or from a JSON file with LoadClientConfig.
+type ClientConfig struct {
+	ServerURL string `json:"server_url"`
+	// TLS enables TLS, the connection is insecure when nil.
+	TLS *TLSConfig `json:"tls"`
+	// BearerToken is sent as "authorization: Bearer <token>" with every call.
+	BearerToken string `json:"bearer_token"`
+	// APIKey is sent in the APIKeyHeader header, DefaultAPIKeyHeader by default, with every call.
+	APIKey       string `json:"api_key"`
+	APIKeyHeader string `json:"api_key_header"`
+	// AllowInsecureCredentials allows sending the bearer token and the API key without TLS, e.g.
+	// to a sidecar on localhost. Validate rejects them over an insecure connection otherwise.
+	AllowInsecureCredentials bool `json:"allow_insecure_credentials"`
+	// Metadata is sent with every call, see WithCallMetadata for the metadata of one call.
+	Metadata map[string]string `json:"metadata"`
+	// Compression compresses requests, CompressionGzip or empty for none.
+	Compression string `json:"compression"`
+	// DialOptions are appended to the options derived from the config.
+	DialOptions []grpc.DialOption `json:"-"`
+}
+
+// ClientConfigFromEnv reads a config from the TRITON_* environment variables. TLS is enabled
+// when TRITON_TLS is true, or when it is unset and any TRITON_TLS_* file or server name is set.
+// TRITON_METADATA holds comma separated key=value pairs.
+func ClientConfigFromEnv() (*ClientConfig, error) {
+	config := &ClientConfig{
+		ServerURL:    os.Getenv(EnvServerURL),
+		BearerToken:  os.Getenv(EnvBearerToken),
+		APIKey:       os.Getenv(EnvAPIKey),
+		APIKeyHeader: os.Getenv(EnvAPIKeyHeader),
+		Compression:  os.Getenv(EnvCompression),
+	}
+
+	tlsConfig := &TLSConfig{
+		CAFile:     os.Getenv(EnvTLSCAFile),
+		CertFile:   os.Getenv(EnvTLSCertFile),
+		KeyFile:    os.Getenv(EnvTLSKeyFile),
+		ServerName: os.Getenv(EnvTLSServerName),
+	}
+	enableTLS := *tlsConfig != TLSConfig{}
+	if value := os.Getenv(EnvTLS); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvTLS, err)
+		}
+		enableTLS = enabled
+	}
+	if enableTLS {
+		config.TLS = tlsConfig
+	}
+	if value := os.Getenv(EnvAllowInsecureCredentials); value != "" {
+		allowed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvAllowInsecureCredentials, err)
+		}
+		config.AllowInsecureCredentials = allowed
+	}
+
+	if value := os.Getenv(EnvMetadata); value != "" {
+		config.Metadata = make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return nil, fmt.Errorf("%s: %q is not a key=value pair", EnvMetadata, pair)
+			}
+			config.Metadata[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	}
+	return config, config.Validate()
+}
+
+// LoadClientConfig reads a config from a JSON file, with the fields named in snake case, e.g.
+// {"server_url": "triton:8001", "tls": {"ca_file": "/etc/triton/ca.pem"}}.
+func LoadClientConfig(path string) (*ClientConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &ClientConfig{}
+	if err = json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("client config %s: %w", path, err)
+	}
+	return config, config.Validate()
+}
+
+// Validate checks the config without reading the TLS files.
+func (c *ClientConfig) Validate() error {
+	if c.TLS != nil && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls client certificate requires both a cert file and a key file")
+	}
+	if c.TLS == nil && (c.BearerToken != "" || c.APIKey != "") && !c.AllowInsecureCredentials {
+		return errors.New("bearer token and api key require tls, set AllowInsecureCredentials to send them over an insecure connection")
+	}
+	if c.Compression != "" && c.Compression != CompressionGzip {
+		return fmt.Errorf("unsupported compression %q", c.Compression)
+	}
+	for key := range c.Metadata {
+		if key == "" || strings.HasPrefix(key, "grpc-") {
+			return fmt.Errorf("invalid metadata key %q", key)
+		}
+	}
+	return nil
+}
+
+// GRPCDialOptions returns the dial options implementing the config, reading the TLS files.
+func (c *ClientConfig) GRPCDialOptions() ([]grpc.DialOption, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var grpcOpts []grpc.DialOption
+	if c.TLS != nil {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if headers := c.headers(); len(headers) > 0 {
+		grpcOpts = append(grpcOpts,
+			grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				return invoker(metadata.AppendToOutgoingContext(ctx, headers...), method, req, reply, cc, opts...)
+			}),
+			grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return streamer(metadata.AppendToOutgoingContext(ctx, headers...), desc, cc, method, opts...)
+			}),
+		)
+	}
+	if c.Compression != "" {
+		grpcOpts = append(grpcOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(c.Compression)))
+	}
+	return append(grpcOpts, c.DialOptions...), nil
+}
+
+// headers returns the key value pairs sent with every call.
+func (c *ClientConfig) headers() []string {
+	keys := make([]string, 0, len(c.Metadata))
+	for key := range c.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	headers := make([]string, 0, 2*len(keys)+4)
+	for _, key := range keys {
+		headers = append(headers, key, c.Metadata[key])
+	}
+	if c.BearerToken != "" {
+		headers = append(headers, "authorization", "Bearer "+c.BearerToken)
+	}
+	if c.APIKey != "" {
+		header := c.APIKeyHeader
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		headers = append(headers, header, c.APIKey)
+	}
+	return headers
+}
+
+func (c *TLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		caPEM, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// NewTritonGRPCClientFromConfig inits a new gRPC client from a typed config.
+func NewTritonGRPCClientFromConfig(config *ClientConfig) (*TritonGRPCClient, error) {
+	if config.ServerURL == "" {
+		return nil, errors.New("client config has no server url")
+	}
+	grpcOpts, err := config.GRPCDialOptions()
+	if err != nil {
+		return nil, err
+	}
+	return NewTritonGRPCClient(config.ServerURL, grpcOpts)
+}
+
+// WithCallMetadata returns a context sending the key value pairs as metadata with the calls
+// made with it, in addition to ClientConfig.Metadata.
+func WithCallMetadata(ctx context.Context, pairs ...string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
@@ -0,0 +1,279 @@
+package triton_client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientConfig_Metadata(t *testing.T) {
+	ctx := context.Background()
+	server := tritontest.NewServer()
+	defer server.Close()
+	var mu sync.Mutex
+	var received []metadata.MD
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "echo"},
+		Infer: func(ctx context.Context, _ *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, md)
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+
+	client, err := NewTritonGRPCClientFromConfig(&ClientConfig{
+		ServerURL:   tritontest.Target,
+		BearerToken: "token",
+		APIKey:      "key",
+		// the fake server does not serve TLS
+		AllowInsecureCredentials: true,
+		Metadata:                 map[string]string{"tenant": "faces"},
+		Compression:              CompressionGzip,
+		DialOptions:              server.DialOptions(),
+	})
+	assert.NoError(t, err)
+	defer func() {
+		_ = client.Disconnect()
+	}()
+
+	_, err = client.Infer(WithCallMetadata(ctx, "request-source", "camera-1"), "echo", "", nil, nil)
+	assert.NoError(t, err)
+	done := make(chan struct{})
+	stream, err := client.ModelStreamInfer(ctx, func(*StreamResult) { close(done) })
+	assert.NoError(t, err)
+	_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo"})
+	assert.NoError(t, err)
+	<-done
+	assert.NoError(t, stream.Close())
+
+	// unary and streamed calls carry the headers
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 2)
+	for _, md := range received {
+		assert.Equal(t, []string{"Bearer token"}, md.Get("authorization"))
+		assert.Equal(t, []string{"key"}, md.Get(DefaultAPIKeyHeader))
+		assert.Equal(t, []string{"faces"}, md.Get("tenant"))
+	}
+	assert.Equal(t, []string{"camera-1"}, received[0].Get("request-source"))
+	assert.Empty(t, received[1].Get("request-source"))
+}
+
+// writeCertificate writes a certificate and its key in PEM files named after name, signed by
+// parent or self-signed when parent is nil.
+func writeCertificate(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, interface{}(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600))
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+	certificate.Leaf, err = x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate
+}
+
+// readyServer only answers ServerReady, for tests needing a server tritontest cannot provide.
+type readyServer struct {
+	grpc_client.UnimplementedGRPCInferenceServiceServer
+}
+
+func (s *readyServer) ServerReady(context.Context, *grpc_client.ServerReadyRequest) (*grpc_client.ServerReadyResponse, error) {
+	return &grpc_client.ServerReadyResponse{Ready: true}, nil
+}
+
+func TestClientConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCertificate(t, dir, "ca", &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	serverCert := writeCertificate(t, dir, "server", &x509.Certificate{DNSNames: []string{"triton.internal"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, &ca)
+	writeCertificate(t, dir, "client", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, &ca)
+	writeCertificate(t, dir, "other-ca", &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	grpc_client.RegisterGRPCInferenceServiceServer(server, &readyServer{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+
+	serverReady := func(tlsConfig *TLSConfig) error {
+		client, err := NewTritonGRPCClientFromConfig(&ClientConfig{ServerURL: "bufnet", TLS: tlsConfig, DialOptions: []grpc.DialOption{dialer}})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = client.Disconnect()
+		}()
+		_, err = client.ServerReady(time.Second)
+		return err
+	}
+	assert.NoError(t, serverReady(&TLSConfig{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "triton.internal",
+	}))
+
+	// the server requires a client certificate
+	assert.Error(t, serverReady(&TLSConfig{CAFile: filepath.Join(dir, "ca.pem"), ServerName: "triton.internal"}))
+	// the server certificate must be signed by the CA and match the server name
+	assert.Error(t, serverReady(&TLSConfig{
+		CAFile:     filepath.Join(dir, "other-ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "triton.internal",
+	}))
+	assert.Error(t, serverReady(&TLSConfig{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "other.internal",
+	}))
+	// files are checked when dialing
+	assert.Error(t, serverReady(&TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}))
+	assert.ErrorContains(t, serverReady(&TLSConfig{CAFile: filepath.Join(dir, "ca-key.pem")}), "no certificate found")
+	assert.Error(t, serverReady(&TLSConfig{CertFile: filepath.Join(dir, "client.pem")}))
+}
+
+func TestClientConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvServerURL, "triton:8001")
+	t.Setenv(EnvTLSCAFile, "/etc/triton/ca.pem")
+	t.Setenv(EnvBearerToken, "token")
+	t.Setenv(EnvMetadata, "tenant=faces, region = eu")
+	t.Setenv(EnvCompression, "gzip")
+
+	config, err := ClientConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, &ClientConfig{
+		ServerURL:   "triton:8001",
+		TLS:         &TLSConfig{CAFile: "/etc/triton/ca.pem"},
+		BearerToken: "token",
+		Metadata:    map[string]string{"tenant": "faces", "region": "eu"},
+		Compression: CompressionGzip,
+	}, config)
+
+	// TLS with the system roots
+	t.Setenv(EnvTLSCAFile, "")
+	t.Setenv(EnvTLS, "true")
+	config, err = ClientConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, &TLSConfig{}, config.TLS)
+	// an explicit TRITON_TLS wins over the TLS files
+	t.Setenv(EnvTLSCAFile, "/etc/triton/ca.pem")
+	t.Setenv(EnvTLS, "false")
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, "require tls")
+	t.Setenv(EnvAllowInsecureCredentials, "true")
+	config, err = ClientConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, config.TLS)
+	assert.True(t, config.AllowInsecureCredentials)
+	t.Setenv(EnvAllowInsecureCredentials, "sure")
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, EnvAllowInsecureCredentials)
+	t.Setenv(EnvAllowInsecureCredentials, "")
+	t.Setenv(EnvTLSCAFile, "")
+
+	t.Setenv(EnvTLS, "maybe")
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, EnvTLS)
+	t.Setenv(EnvTLS, "true")
+	t.Setenv(EnvMetadata, "tenant")
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, EnvMetadata)
+	t.Setenv(EnvMetadata, "")
+	t.Setenv(EnvCompression, "zstd")
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, "unsupported compression")
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "triton.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"server_url": "triton:8001",
+		"tls": {"ca_file": "ca.pem", "cert_file": "client.pem", "key_file": "client-key.pem", "server_name": "triton.internal"},
+		"api_key": "key",
+		"api_key_header": "x-triton-key",
+		"metadata": {"tenant": "faces"}
+	}`), 0600))
+	config, err := LoadClientConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &ClientConfig{
+		ServerURL:    "triton:8001",
+		TLS:          &TLSConfig{CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client-key.pem", ServerName: "triton.internal"},
+		APIKey:       "key",
+		APIKeyHeader: "x-triton-key",
+		Metadata:     map[string]string{"tenant": "faces"},
+	}, config)
+	assert.Equal(t, []string{"tenant", "faces", "x-triton-key", "key"}, config.headers())
+
+	// credentials are not sent in the clear unless allowed
+	assert.NoError(t, os.WriteFile(path, []byte(`{"api_key": "key"}`), 0600))
+	_, err = LoadClientConfig(path)
+	assert.ErrorContains(t, err, "require tls")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"api_key": "key", "allow_insecure_credentials": true}`), 0600))
+	config, err = LoadClientConfig(path)
+	assert.NoError(t, err)
+	assert.True(t, config.AllowInsecureCredentials)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"tls": {"cert_file": "client.pem"}}`), 0600))
+	_, err = LoadClientConfig(path)
+	assert.ErrorContains(t, err, "key file")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"metadata": {"grpc-timeout": "1s"}}`), 0600))
+	_, err = LoadClientConfig(path)
+	assert.ErrorContains(t, err, "invalid metadata key")
+	assert.NoError(t, os.WriteFile(path, []byte(`{`), 0600))
+	_, err = LoadClientConfig(path)
+	assert.Error(t, err)
+	_, err = LoadClientConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	_, err = NewTritonGRPCClientFromConfig(&ClientConfig{})
+	assert.Error(t, err)
+}
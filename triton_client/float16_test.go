@@ -0,0 +1,55 @@
+package triton_client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		value float32
+		bits  uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},
+		{float32(math.Pow(2, -24)), 0x0001},
+		{float32(math.Pow(2, -14)), 0x0400},
+		{float32(math.Inf(1)), 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.bits, Float32ToFloat16(tt.value), "%v", tt.value)
+		assert.Equal(t, tt.value, Float16ToFloat32(tt.bits), "%#x", tt.bits)
+	}
+
+	// rounding and overflow
+	assert.Equal(t, uint16(0x3c00), Float32ToFloat16(1+1.0/4096))
+	assert.Equal(t, uint16(0x3c01), Float32ToFloat16(1+3.0/4096))
+	assert.Equal(t, uint16(0x7c00), Float32ToFloat16(1e6))
+	assert.True(t, math.IsNaN(float64(Float16ToFloat32(Float32ToFloat16(float32(math.NaN()))))))
+}
+
+func TestBFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		value float32
+		bits  uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3f80},
+		{-2, 0xc000},
+		{float32(math.Inf(1)), 0x7f80},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.bits, Float32ToBFloat16(tt.value), "%v", tt.value)
+		assert.Equal(t, tt.value, BFloat16ToFloat32(tt.bits), "%#x", tt.bits)
+	}
+
+	assert.Equal(t, uint16(0x3f80), Float32ToBFloat16(math.Float32frombits(0x3f808000)))
+	assert.Equal(t, uint16(0x3f82), Float32ToBFloat16(math.Float32frombits(0x3f818000)))
+	assert.True(t, math.IsNaN(float64(BFloat16ToFloat32(Float32ToBFloat16(float32(math.NaN()))))))
+}
@@ -4,37 +4,47 @@ import (
 	"context"
 	grpc_client "github.com/okieraised/gotritron/grpc-client"
 	"google.golang.org/grpc"
+	"sync/atomic"
 	"time"
 )
 
-var tritonGRPCClient *TritonGRPCClient
-
 type TritonGRPCClient struct {
-	serverURL   string
-	grpcConn    *grpc.ClientConn
-	grpcClient  grpc_client.GRPCInferenceServiceClient
-	modelConfig interface{}
-	modelName   string
-}
-
-// GetGRPCInstance returns an instance of the Triton gRPC client
-func GetGRPCInstance() *TritonGRPCClient {
-	return tritonGRPCClient
+	serverURL       string
+	grpcConn        *grpc.ClientConn
+	grpcClient      grpc_client.GRPCInferenceServiceClient
+	healthClient    grpc_client.HealthClient
+	modelCache      *modelCache
+	retryPolicy     atomic.Pointer[RetryPolicy]
+	circuitBreakers atomic.Pointer[circuitBreakers]
+	healthGate      atomic.Pointer[HealthMonitor]
 }
 
-// NewTritonGRPCClient inits a new gRPC client
-func NewTritonGRPCClient(serverURL string, grpcOpts []grpc.DialOption) error {
+// NewTritonGRPCClient inits a new gRPC client. Every call dials its own connection,
+// so a process can talk to several Triton servers at once.
+// Transient failures are not retried until a policy is set with SetRetryPolicy.
+func NewTritonGRPCClient(serverURL string, grpcOpts []grpc.DialOption) (*TritonGRPCClient, error) {
+	tc := &TritonGRPCClient{
+		serverURL:  serverURL,
+		modelCache: newModelCache(DefaultModelCacheTTL),
+	}
+	grpcOpts = append(append([]grpc.DialOption(nil), grpcOpts...), grpc.WithChainUnaryInterceptor(tc.retryInterceptor))
 	grpcConn, err := grpc.Dial(serverURL, grpcOpts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	grpcClient := grpc_client.NewGRPCInferenceServiceClient(grpcConn)
-	tritonGRPCClient = &TritonGRPCClient{
-		serverURL:  serverURL,
-		grpcConn:   grpcConn,
-		grpcClient: grpcClient,
+	tc.grpcConn = grpcConn
+	tc.grpcClient = grpc_client.NewGRPCInferenceServiceClient(grpcConn)
+	tc.healthClient = grpc_client.NewHealthClient(grpcConn)
+	return tc, nil
+}
+
+// ServerAliveContext check server is alive using the given context.
+func (tc *TritonGRPCClient) ServerAliveContext(ctx context.Context) (bool, error) {
+	serverLiveResponse, err := tc.grpcClient.ServerLive(ctx, &grpc_client.ServerLiveRequest{})
+	if err != nil {
+		return false, err
 	}
-	return nil
+	return serverLiveResponse.Live, nil
 }
 
 // ServerAlive check server is alive.
@@ -42,11 +52,16 @@ func (tc *TritonGRPCClient) ServerAlive(timeout time.Duration) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	serverLiveResponse, err := tc.grpcClient.ServerLive(ctx, &grpc_client.ServerLiveRequest{})
+	return tc.ServerAliveContext(ctx)
+}
+
+// ServerReadyContext check server is ready using the given context.
+func (tc *TritonGRPCClient) ServerReadyContext(ctx context.Context) (bool, error) {
+	serverReadyResponse, err := tc.grpcClient.ServerReady(ctx, &grpc_client.ServerReadyRequest{})
 	if err != nil {
 		return false, err
 	}
-	return serverLiveResponse.Live, nil
+	return serverReadyResponse.Ready, nil
 }
 
 // ServerReady check server is ready.
@@ -54,54 +69,145 @@ func (tc *TritonGRPCClient) ServerReady(timeout time.Duration) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	serverReadyResponse, err := tc.grpcClient.ServerReady(ctx, &grpc_client.ServerReadyRequest{})
+	return tc.ServerReadyContext(ctx)
+}
+
+// HealthCheckContext checks the serving status of a service with the gRPC health protocol using
+// the given context, the empty service is the server as a whole.
+func (tc *TritonGRPCClient) HealthCheckContext(ctx context.Context, service string) (grpc_client.HealthCheckResponse_ServingStatus, error) {
+	healthCheckResponse, err := tc.healthClient.Check(ctx, &grpc_client.HealthCheckRequest{Service: service})
+	if err != nil {
+		return grpc_client.HealthCheckResponse_UNKNOWN, err
+	}
+	return healthCheckResponse.Status, nil
+}
+
+// HealthCheck checks the serving status of a service with the gRPC health protocol.
+func (tc *TritonGRPCClient) HealthCheck(service string, timeout time.Duration) (grpc_client.HealthCheckResponse_ServingStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.HealthCheckContext(ctx, service)
+}
+
+// ModelReadyContext check model is ready using the given context.
+func (tc *TritonGRPCClient) ModelReadyContext(ctx context.Context, modelName, modelVersion string) (bool, error) {
+	modelReadyResponse, err := tc.grpcClient.ModelReady(ctx, &grpc_client.ModelReadyRequest{Name: modelName, Version: modelVersion})
 	if err != nil {
 		return false, err
 	}
-	return serverReadyResponse.Ready, nil
+	return modelReadyResponse.Ready, nil
 }
 
-// ServerMetadata Get server metadata.
-func (tc *TritonGRPCClient) ServerMetadata(timeout time.Duration) (*grpc_client.ServerMetadataResponse, error) {
+// ModelReady check model is ready.
+func (tc *TritonGRPCClient) ModelReady(modelName, modelVersion string, timeout time.Duration) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ModelReadyContext(ctx, modelName, modelVersion)
+}
+
+// WaitForModelReady polls the model every pollInterval, DefaultModelReadyPollInterval when not
+// positive, until it reports ready or ctx ends. Errors while polling, e.g. while the model is still loading, do not stop the wait.
+func (tc *TritonGRPCClient) WaitForModelReady(ctx context.Context, modelName, modelVersion string, pollInterval time.Duration) error {
+	return waitForModelReady(ctx, tc, modelName, modelVersion, pollInterval)
+}
+
+// ServerMetadataContext Get server metadata using the given context.
+func (tc *TritonGRPCClient) ServerMetadataContext(ctx context.Context) (*grpc_client.ServerMetadataResponse, error) {
 	serverMetadataResponse, err := tc.grpcClient.ServerMetadata(ctx, &grpc_client.ServerMetadataRequest{})
 	return serverMetadataResponse, err
 }
 
-// ModelRepositoryIndex Get model repo index.
-func (tc *TritonGRPCClient) ModelRepositoryIndex(repoName string, isReady bool, timeout time.Duration) (*grpc_client.RepositoryIndexResponse, error) {
+// ServerMetadata Get server metadata.
+func (tc *TritonGRPCClient) ServerMetadata(timeout time.Duration) (*grpc_client.ServerMetadataResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.ServerMetadataContext(ctx)
+}
+
+// ModelMetadataContext Get model metadata using the given context.
+func (tc *TritonGRPCClient) ModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error) {
+	modelMetadataResponse, err := tc.grpcClient.ModelMetadata(ctx, &grpc_client.ModelMetadataRequest{Name: modelName, Version: modelVersion})
+	return modelMetadataResponse, err
+}
+
+// ModelMetadata Get model metadata.
+func (tc *TritonGRPCClient) ModelMetadata(modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelMetadataResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ModelMetadataContext(ctx, modelName, modelVersion)
+}
+
+// CachedModelMetadataContext Get model metadata from the client cache, fetching it on a miss.
+func (tc *TritonGRPCClient) CachedModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error) {
+	return tc.modelCache.metadata(ctx, modelName, modelVersion, tc.ModelMetadataContext)
+}
+
+// ModelRepositoryIndexContext Get model repo index using the given context.
+func (tc *TritonGRPCClient) ModelRepositoryIndexContext(ctx context.Context, repoName string, isReady bool) (*grpc_client.RepositoryIndexResponse, error) {
 	repositoryIndexResponse, err := tc.grpcClient.RepositoryIndex(ctx, &grpc_client.RepositoryIndexRequest{RepositoryName: repoName, Ready: isReady})
 	return repositoryIndexResponse, err
 }
 
-// GetModelConfiguration Get model configuration.
-func (tc *TritonGRPCClient) GetModelConfiguration(modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelConfigResponse, error) {
+// ModelRepositoryIndex Get model repo index.
+func (tc *TritonGRPCClient) ModelRepositoryIndex(repoName string, isReady bool, timeout time.Duration) (*grpc_client.RepositoryIndexResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ModelRepositoryIndexContext(ctx, repoName, isReady)
+}
+
+// GetModelConfigurationContext Get model configuration using the given context.
+func (tc *TritonGRPCClient) GetModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error) {
 	modelConfigResponse, err := tc.grpcClient.ModelConfig(ctx, &grpc_client.ModelConfigRequest{Name: modelName, Version: modelVersion})
 	return modelConfigResponse, err
 }
 
-// ModelInferStats Get Model infer stats.
-func (tc *TritonGRPCClient) ModelInferStats(modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelStatisticsResponse, error) {
+// GetModelConfiguration Get model configuration.
+func (tc *TritonGRPCClient) GetModelConfiguration(modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelConfigResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.GetModelConfigurationContext(ctx, modelName, modelVersion)
+}
+
+// CachedModelConfigurationContext Get model configuration from the client cache, fetching it on a miss.
+func (tc *TritonGRPCClient) CachedModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error) {
+	return tc.modelCache.config(ctx, modelName, modelVersion, tc.GetModelConfigurationContext)
+}
+
+// InvalidateModelCache drops the cached configuration and metadata of the model, or of all
+// models when modelName is empty.
+func (tc *TritonGRPCClient) InvalidateModelCache(modelName string) {
+	tc.modelCache.invalidate(modelName)
+}
+
+// SetModelCacheTTL changes how long model configurations and metadata are cached, a ttl <= 0
+// caches them until they are invalidated.
+func (tc *TritonGRPCClient) SetModelCacheTTL(ttl time.Duration) {
+	tc.modelCache.setTTL(ttl)
+}
+
+// ModelInferStatsContext Get Model infer stats using the given context.
+func (tc *TritonGRPCClient) ModelInferStatsContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelStatisticsResponse, error) {
 	modelStatisticsResponse, err := tc.grpcClient.ModelStatistics(ctx, &grpc_client.ModelStatisticsRequest{Name: modelName, Version: modelVersion})
 	return modelStatisticsResponse, err
 }
 
-// ModelLoadWithGRPC Load Model with grpc.
-func (tc *TritonGRPCClient) ModelLoadWithGRPC(repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter, timeout time.Duration) (*grpc_client.RepositoryModelLoadResponse, error) {
+// ModelInferStats Get Model infer stats.
+func (tc *TritonGRPCClient) ModelInferStats(modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelStatisticsResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ModelInferStatsContext(ctx, modelName, modelVersion)
+}
+
+// ModelLoadWithGRPCContext Load Model with grpc using the given context.
+func (tc *TritonGRPCClient) ModelLoadWithGRPCContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) (*grpc_client.RepositoryModelLoadResponse, error) {
+	defer tc.modelCache.invalidate(modelName)
 	loadResponse, err := tc.grpcClient.RepositoryModelLoad(ctx, &grpc_client.RepositoryModelLoadRequest{
 		RepositoryName: repoName,
 		ModelName:      modelName,
@@ -110,11 +216,23 @@ func (tc *TritonGRPCClient) ModelLoadWithGRPC(repoName, modelName string, modelC
 	return loadResponse, err
 }
 
-// ModelUnloadWithGRPC Unload model with grpc modelConfigBody if not is nil.
-func (tc *TritonGRPCClient) ModelUnloadWithGRPC(repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter, timeout time.Duration) (*grpc_client.RepositoryModelUnloadResponse, error) {
+// ModelLoadWithGRPC Load Model with grpc.
+func (tc *TritonGRPCClient) ModelLoadWithGRPC(repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter, timeout time.Duration) (*grpc_client.RepositoryModelLoadResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ModelLoadWithGRPCContext(ctx, repoName, modelName, modelConfigBody)
+}
+
+// ModelLoadContext Load model using the given context, see ModelLoadWithGRPCContext.
+func (tc *TritonGRPCClient) ModelLoadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error {
+	_, err := tc.ModelLoadWithGRPCContext(ctx, repoName, modelName, modelConfigBody)
+	return err
+}
+
+// ModelUnloadWithGRPCContext Unload model with grpc using the given context.
+func (tc *TritonGRPCClient) ModelUnloadWithGRPCContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) (*grpc_client.RepositoryModelUnloadResponse, error) {
+	defer tc.modelCache.invalidate(modelName)
 	unloadResponse, err := tc.grpcClient.RepositoryModelUnload(ctx, &grpc_client.RepositoryModelUnloadRequest{
 		RepositoryName: repoName,
 		ModelName:      modelName,
@@ -123,15 +241,23 @@ func (tc *TritonGRPCClient) ModelUnloadWithGRPC(repoName, modelName string, mode
 	return unloadResponse, err
 }
 
-// ShareMemoryStatus Get share memory / cuda memory status.
-func (tc *TritonGRPCClient) ShareMemoryStatus(isCUDA bool, regionName string, timeout time.Duration) (interface{}, error) {
+// ModelUnloadWithGRPC Unload model with grpc modelConfigBody if not is nil.
+func (tc *TritonGRPCClient) ModelUnloadWithGRPC(repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter, timeout time.Duration) (*grpc_client.RepositoryModelUnloadResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if isCUDA {
-		cudaSharedMemoryStatusResponse, err := tc.grpcClient.CudaSharedMemoryStatus(ctx, &grpc_client.CudaSharedMemoryStatusRequest{Name: regionName})
-		return cudaSharedMemoryStatusResponse, err
-	}
+	return tc.ModelUnloadWithGRPCContext(ctx, repoName, modelName, modelConfigBody)
+}
+
+// ModelUnloadContext Unload model using the given context, see ModelUnloadWithGRPCContext.
+func (tc *TritonGRPCClient) ModelUnloadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error {
+	_, err := tc.ModelUnloadWithGRPCContext(ctx, repoName, modelName, modelConfigBody)
+	return err
+}
+
+// SystemSharedMemoryStatusContext Get the status of a registered system shared memory region, or
+// of all of them when regionName is empty, using the given context.
+func (tc *TritonGRPCClient) SystemSharedMemoryStatusContext(ctx context.Context, regionName string) (*grpc_client.SystemSharedMemoryStatusResponse, error) {
 	systemSharedMemoryStatusResponse, err := tc.grpcClient.SystemSharedMemoryStatus(ctx, &grpc_client.SystemSharedMemoryStatusRequest{Name: regionName})
 	if err != nil {
 		return nil, err
@@ -139,11 +265,36 @@ func (tc *TritonGRPCClient) ShareMemoryStatus(isCUDA bool, regionName string, ti
 	return systemSharedMemoryStatusResponse, nil
 }
 
-// ShareCUDAMemoryRegister cuda share memory register.
-func (tc *TritonGRPCClient) ShareCUDAMemoryRegister(regionName string, cudaRawHandle []byte, cudaDeviceID int64, byteSize uint64, timeout time.Duration) (*grpc_client.CudaSharedMemoryRegisterResponse, error) {
+// SystemSharedMemoryStatus Get the status of a registered system shared memory region, or of all
+// of them when regionName is empty.
+func (tc *TritonGRPCClient) SystemSharedMemoryStatus(regionName string, timeout time.Duration) (*grpc_client.SystemSharedMemoryStatusResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.SystemSharedMemoryStatusContext(ctx, regionName)
+}
+
+// CudaSharedMemoryStatusContext Get the status of a registered cuda shared memory region, or of
+// all of them when regionName is empty, using the given context.
+func (tc *TritonGRPCClient) CudaSharedMemoryStatusContext(ctx context.Context, regionName string) (*grpc_client.CudaSharedMemoryStatusResponse, error) {
+	cudaSharedMemoryStatusResponse, err := tc.grpcClient.CudaSharedMemoryStatus(ctx, &grpc_client.CudaSharedMemoryStatusRequest{Name: regionName})
+	if err != nil {
+		return nil, err
+	}
+	return cudaSharedMemoryStatusResponse, nil
+}
+
+// CudaSharedMemoryStatus Get the status of a registered cuda shared memory region, or of all of
+// them when regionName is empty.
+func (tc *TritonGRPCClient) CudaSharedMemoryStatus(regionName string, timeout time.Duration) (*grpc_client.CudaSharedMemoryStatusResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.CudaSharedMemoryStatusContext(ctx, regionName)
+}
+
+// ShareCUDAMemoryRegisterContext cuda share memory register using the given context.
+func (tc *TritonGRPCClient) ShareCUDAMemoryRegisterContext(ctx context.Context, regionName string, cudaRawHandle []byte, cudaDeviceID int64, byteSize uint64) (*grpc_client.CudaSharedMemoryRegisterResponse, error) {
 	cudaSharedMemoryRegisterResponse, err := tc.grpcClient.CudaSharedMemoryRegister(
 		ctx, &grpc_client.CudaSharedMemoryRegisterRequest{
 			Name:      regionName,
@@ -155,20 +306,30 @@ func (tc *TritonGRPCClient) ShareCUDAMemoryRegister(regionName string, cudaRawHa
 	return cudaSharedMemoryRegisterResponse, err
 }
 
-// ShareCUDAMemoryUnRegister cuda share memory unregister.
-func (tc *TritonGRPCClient) ShareCUDAMemoryUnRegister(regionName string, timeout time.Duration) (*grpc_client.CudaSharedMemoryUnregisterResponse, error) {
+// ShareCUDAMemoryRegister cuda share memory register.
+func (tc *TritonGRPCClient) ShareCUDAMemoryRegister(regionName string, cudaRawHandle []byte, cudaDeviceID int64, byteSize uint64, timeout time.Duration) (*grpc_client.CudaSharedMemoryRegisterResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ShareCUDAMemoryRegisterContext(ctx, regionName, cudaRawHandle, cudaDeviceID, byteSize)
+}
+
+// ShareCUDAMemoryUnRegisterContext cuda share memory unregister using the given context.
+func (tc *TritonGRPCClient) ShareCUDAMemoryUnRegisterContext(ctx context.Context, regionName string) (*grpc_client.CudaSharedMemoryUnregisterResponse, error) {
 	cudaSharedMemoryUnRegisterResponse, err := tc.grpcClient.CudaSharedMemoryUnregister(ctx, &grpc_client.CudaSharedMemoryUnregisterRequest{Name: regionName})
 	return cudaSharedMemoryUnRegisterResponse, err
 }
 
-// ShareSystemMemoryRegister system share memory register.
-func (tc *TritonGRPCClient) ShareSystemMemoryRegister(regionName, cpuMemRegionKey string, byteSize, cpuMemOffset uint64, timeout time.Duration) (*grpc_client.SystemSharedMemoryRegisterResponse, error) {
+// ShareCUDAMemoryUnRegister cuda share memory unregister.
+func (tc *TritonGRPCClient) ShareCUDAMemoryUnRegister(regionName string, timeout time.Duration) (*grpc_client.CudaSharedMemoryUnregisterResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ShareCUDAMemoryUnRegisterContext(ctx, regionName)
+}
+
+// ShareSystemMemoryRegisterContext system share memory register using the given context.
+func (tc *TritonGRPCClient) ShareSystemMemoryRegisterContext(ctx context.Context, regionName, cpuMemRegionKey string, byteSize, cpuMemOffset uint64) (*grpc_client.SystemSharedMemoryRegisterResponse, error) {
 	systemSharedMemoryRegisterResponse, err := tc.grpcClient.SystemSharedMemoryRegister(
 		ctx, &grpc_client.SystemSharedMemoryRegisterRequest{
 			Name:     regionName,
@@ -180,21 +341,45 @@ func (tc *TritonGRPCClient) ShareSystemMemoryRegister(regionName, cpuMemRegionKe
 	return systemSharedMemoryRegisterResponse, err
 }
 
-// ShareSystemMemoryUnRegister system share memory unregister.
-func (tc *TritonGRPCClient) ShareSystemMemoryUnRegister(regionName string, timeout time.Duration) (*grpc_client.SystemSharedMemoryUnregisterResponse, error) {
+// ShareSystemMemoryRegister system share memory register.
+func (tc *TritonGRPCClient) ShareSystemMemoryRegister(regionName, cpuMemRegionKey string, byteSize, cpuMemOffset uint64, timeout time.Duration) (*grpc_client.SystemSharedMemoryRegisterResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return tc.ShareSystemMemoryRegisterContext(ctx, regionName, cpuMemRegionKey, byteSize, cpuMemOffset)
+}
+
+// ShareSystemMemoryUnRegisterContext system share memory unregister using the given context.
+func (tc *TritonGRPCClient) ShareSystemMemoryUnRegisterContext(ctx context.Context, regionName string) (*grpc_client.SystemSharedMemoryUnregisterResponse, error) {
 	systemSharedMemoryUnRegisterResponse, err := tc.grpcClient.SystemSharedMemoryUnregister(ctx, &grpc_client.SystemSharedMemoryUnregisterRequest{Name: regionName})
 	return systemSharedMemoryUnRegisterResponse, err
 }
 
+// ShareSystemMemoryUnRegister system share memory unregister.
+func (tc *TritonGRPCClient) ShareSystemMemoryUnRegister(regionName string, timeout time.Duration) (*grpc_client.SystemSharedMemoryUnregisterResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.ShareSystemMemoryUnRegisterContext(ctx, regionName)
+}
+
+// GetModelTracingSettingContext get model tracing setting using the given context.
+func (tc *TritonGRPCClient) GetModelTracingSettingContext(ctx context.Context, modelName string) (*grpc_client.TraceSettingResponse, error) {
+	traceSettingResponse, err := tc.grpcClient.TraceSetting(ctx, &grpc_client.TraceSettingRequest{ModelName: modelName})
+	return traceSettingResponse, err
+}
+
 // GetModelTracingSetting get model tracing setting.
 func (tc *TritonGRPCClient) GetModelTracingSetting(modelName string, timeout time.Duration) (*grpc_client.TraceSettingResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	traceSettingResponse, err := tc.grpcClient.TraceSetting(ctx, &grpc_client.TraceSettingRequest{ModelName: modelName})
+	return tc.GetModelTracingSettingContext(ctx, modelName)
+}
+
+// SetModelTracingSettingContext set model tracing setting using the given context.
+func (tc *TritonGRPCClient) SetModelTracingSettingContext(ctx context.Context, modelName string, settingMap map[string]*grpc_client.TraceSettingRequest_SettingValue) (*grpc_client.TraceSettingResponse, error) {
+	traceSettingResponse, err := tc.grpcClient.TraceSetting(ctx, &grpc_client.TraceSettingRequest{ModelName: modelName, Settings: settingMap})
 	return traceSettingResponse, err
 }
 
@@ -203,19 +388,45 @@ func (tc *TritonGRPCClient) SetModelTracingSetting(modelName string, settingMap
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	traceSettingResponse, err := tc.grpcClient.TraceSetting(ctx, &grpc_client.TraceSettingRequest{ModelName: modelName, Settings: settingMap})
-	return traceSettingResponse, err
+	return tc.SetModelTracingSettingContext(ctx, modelName, settingMap)
+}
+
+// GetLogSettingsContext get server log settings using the given context.
+func (tc *TritonGRPCClient) GetLogSettingsContext(ctx context.Context) (*grpc_client.LogSettingsResponse, error) {
+	logSettingsResponse, err := tc.grpcClient.LogSettings(ctx, &grpc_client.LogSettingsRequest{})
+	return logSettingsResponse, err
+}
+
+// GetLogSettings get server log settings.
+func (tc *TritonGRPCClient) GetLogSettings(timeout time.Duration) (*grpc_client.LogSettingsResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.GetLogSettingsContext(ctx)
 }
 
+// SetLogSettingsContext update server log settings, e.g. "log_verbose_level", using the given context.
+func (tc *TritonGRPCClient) SetLogSettingsContext(ctx context.Context, settingMap map[string]*grpc_client.LogSettingsRequest_SettingValue) (*grpc_client.LogSettingsResponse, error) {
+	logSettingsResponse, err := tc.grpcClient.LogSettings(ctx, &grpc_client.LogSettingsRequest{Settings: settingMap})
+	return logSettingsResponse, err
+}
+
+// SetLogSettings update server log settings, e.g. "log_verbose_level".
+func (tc *TritonGRPCClient) SetLogSettings(settingMap map[string]*grpc_client.LogSettingsRequest_SettingValue, timeout time.Duration) (*grpc_client.LogSettingsResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.SetLogSettingsContext(ctx, settingMap)
+}
+
+// Disconnect closes the connection to the server.
 func (tc *TritonGRPCClient) Disconnect() error {
 	err := tc.grpcConn.Close()
 	return err
 }
 
-// ModelGRPCInfer Call Triton with GRPC
-func (tc *TritonGRPCClient) ModelGRPCInfer(inferInputs []*grpc_client.ModelInferRequest_InferInputTensor, inferOutputs []*grpc_client.ModelInferRequest_InferRequestedOutputTensor, rawInputs [][]byte, modelName, modelVersion string, timeout time.Duration) (*grpc_client.ModelInferResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// ModelGRPCInferContext Call Triton with GRPC using the given context.
+func (tc *TritonGRPCClient) ModelGRPCInferContext(ctx context.Context, inferInputs []*grpc_client.ModelInferRequest_InferInputTensor, inferOutputs []*grpc_client.ModelInferRequest_InferRequestedOutputTensor, rawInputs [][]byte, modelName, modelVersion string, opts ...InferOption) (*grpc_client.ModelInferResponse, error) {
 	// Create infer request for specific model/version.
 	modelInferRequest := grpc_client.ModelInferRequest{
 		ModelName:        modelName,
@@ -224,9 +435,49 @@ func (tc *TritonGRPCClient) ModelGRPCInfer(inferInputs []*grpc_client.ModelInfer
 		Outputs:          inferOutputs,
 		RawInputContents: rawInputs,
 	}
-	modelInferResponse, err := tc.grpcClient.ModelInfer(ctx, &modelInferRequest)
+	if err := applyInferOptions(&modelInferRequest, opts); err != nil {
+		return nil, err
+	}
+	return tc.ModelInfer(ctx, &modelInferRequest)
+}
+
+// ModelInfer sends a fully built inference request to Triton using the given context. It fails
+// fast when the health gate reports Triton is not serving, see SetHealthGate, or the circuit
+// breaker of the model is open, see SetCircuitBreaker.
+func (tc *TritonGRPCClient) ModelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	if err := tc.healthGate.Load().Ready(); err != nil {
+		return nil, err
+	}
+	done, err := tc.circuitBreakers.Load().acquire(ctx, modelInferRequest.ModelName)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := tc.grpcClient.ModelInfer(ctx, modelInferRequest)
+	done(err)
 	if err != nil {
 		return nil, err
 	}
 	return modelInferResponse, nil
 }
+
+// ModelGRPCInfer Call Triton with GRPC
+func (tc *TritonGRPCClient) ModelGRPCInfer(inferInputs []*grpc_client.ModelInferRequest_InferInputTensor, inferOutputs []*grpc_client.ModelInferRequest_InferRequestedOutputTensor, rawInputs [][]byte, modelName, modelVersion string, timeout time.Duration, opts ...InferOption) (*grpc_client.ModelInferResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return tc.ModelGRPCInferContext(ctx, inferInputs, inferOutputs, rawInputs, modelName, modelVersion, opts...)
+}
+
+// Infer runs inference with typed inputs and outputs using the given context, opts set the
+// request ID and parameters.
+func (tc *TritonGRPCClient) Infer(ctx context.Context, modelName, modelVersion string, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error) {
+	modelInferRequest, err := NewModelInferRequest(modelName, modelVersion, inputs, outputs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := tc.ModelInfer(ctx, modelInferRequest)
+	if err != nil {
+		return nil, err
+	}
+	return newInferResultFor(modelInferRequest, modelInferResponse), nil
+}
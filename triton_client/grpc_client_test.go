@@ -1,24 +1,85 @@
 package triton_client
 
 import (
-	"fmt"
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 	"testing"
 	"time"
 )
 
-func TestNewTritonGRPCClient(t *testing.T) {
-	err := NewTritonGRPCClient(
-		"210.211.99.18:8301",
-		[]grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
-		},
-	)
+func TestTritonGRPCClient_WaitForModelReady(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Config: faceDetectionRetinaConfig})
+
+	// the model is loading, then not ready, then ready
+	server.SetModelReady("face_detection_retina", false)
+	server.InjectErrors("ModelReady", status.Error(codes.Unavailable, "model face_detection_retina is loading"))
+	go func() {
+		for len(server.Requests("ModelReady")) < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		server.SetModelReady("face_detection_retina", true)
+	}()
+	err := client.WaitForModelReady(context.Background(), "face_detection_retina", "", time.Millisecond)
 	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(server.Requests("ModelReady")), 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = client.WaitForModelReady(ctx, "missing", "", time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTritonGRPCClient_WaitForModelReady_DefaultInterval(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Config: faceDetectionRetinaConfig})
+
+	// a zero interval polls at the default interval
+	assert.NoError(t, client.WaitForModelReady(context.Background(), "face_detection_retina", "", 0))
+	server.SetModelReady("face_detection_retina", false)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := client.WaitForModelReady(ctx, "face_detection_retina", "", -time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, server.Requests("ModelReady"), 2)
+}
+
+// newFakeServerClient starts a fake Triton server and returns a client connected to it.
+func newFakeServerClient(t *testing.T) (*tritontest.Server, *TritonGRPCClient) {
+	server := tritontest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := NewTritonGRPCClient(tritontest.Target, append(server.DialOptions(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect()
+	})
+	return server, client
+}
+
+// faceDetectionRetinaConfig is the config of the RetinaFace model served by the fake server.
+var faceDetectionRetinaConfig = &grpc_client.ModelConfig{
+	Name:     "face_detection_retina",
+	Platform: "onnxruntime_onnx",
+	Input: []*grpc_client.ModelInput{
+		{Name: "data", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 3, 640, 640}},
+	},
+	Output: []*grpc_client.ModelOutput{
+		{Name: "face_rpn_cls_prob_reshape_stride32", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 4, 20, 20}},
+	},
+}
+
+func TestNewTritonGRPCClient(t *testing.T) {
+	server, tritonGRPCClient := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Version: "1", Config: faceDetectionRetinaConfig})
 	assert.NotNil(t, tritonGRPCClient.grpcClient)
 
 	isAlive, err := tritonGRPCClient.ServerAlive(5 * time.Second)
@@ -31,87 +92,135 @@ func TestNewTritonGRPCClient(t *testing.T) {
 
 	meta, err := tritonGRPCClient.ServerMetadata(5 * time.Second)
 	assert.NoError(t, err)
-	fmt.Println(meta)
+	assert.Equal(t, "tritontest", meta.Name)
 
 	index, err := tritonGRPCClient.ModelRepositoryIndex("", true, 5*time.Second)
 	assert.NoError(t, err)
-	fmt.Println(index)
+	assert.Len(t, index.Models, 1)
+	assert.Equal(t, "face_detection_retina", index.Models[0].Name)
 
 	modelConf, err := tritonGRPCClient.GetModelConfiguration("face_detection_retina", "1", 5*time.Second)
 	assert.NoError(t, err)
-	fmt.Println(modelConf)
+	assert.Equal(t, "data", modelConf.Config.Input[0].Name)
+
+	_, err = tritonGRPCClient.GetModelConfiguration("face_detection_retina", "2", 5*time.Second)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	server.SetServerReady(false)
+	isReady, err = tritonGRPCClient.ServerReady(5 * time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, false, isReady)
 }
 
 func TestTritonGRPCClient_ModelGRPCInfer(t *testing.T) {
-	err := NewTritonGRPCClient(
-		"210.211.99.18:8301",
-		[]grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithKeepaliveParams(keepalive.ClientParameters{PermitWithoutStream: true}),
+	server, tritonGRPCClient := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Version: "1",
+		Config:  faceDetectionRetinaConfig,
+		Infer: func(_ context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{
+				Outputs: []*grpc_client.ModelInferResponse_InferOutputTensor{
+					{Name: "face_rpn_cls_prob_reshape_stride32", Datatype: "FP32", Shape: []int64{1}},
+				},
+				RawOutputContents: [][]byte{req.RawInputContents[0][:4]},
+			}, nil
 		},
-	)
-	assert.NoError(t, err)
-	assert.NotNil(t, tritonGRPCClient.grpcClient)
+	})
 
 	modelConf, err := tritonGRPCClient.GetModelConfiguration("face_detection_retina", "", 5*time.Second)
 	assert.NoError(t, err)
-	fmt.Println(modelConf.Config.Input)
-
-	//rfd, err := gotritron.NewRetinaFaceDetection()
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-
-	//f, err := os.Open("../test_data/harrison.jpeg")
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//defer f.Close()
-	//
-	//content, err := io.ReadAll(f)
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//
-	//res, err := internal.ConvertImageToOpenCV(content)
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//
-	//processedImg, _, err := rfd.Preprocess(res)
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//
-	//input, err := rfd.Forward(processedImg)
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//
-	//fmt.Println(len(input))
-	//
-	//rawInputs := [][]byte{input}
-	//
-	//inferInputs := []*grpc_client.ModelInferRequest_InferInputTensor{
-	//	{
-	//		Name:     "data",
-	//		Datatype: "FP32",
-	//		Shape:    []int64{1, 3, 640, 640},
-	//	},
-	//}
-	//
-	//infer, err := GetGRPCInstance().ModelGRPCInfer(inferInputs, nil, rawInputs, "face_detection_retina", "1", 10*time.Second)
-	//if err != nil {
-	//	fmt.Println(err)
-	//	return
-	//}
-	//
-	//fmt.Println(infer.RawOutputContents)
 
+	input := make([]byte, 3*640*640*4)
+	input[0] = 42
+	inferInputs := []*grpc_client.ModelInferRequest_InferInputTensor{
+		{
+			Name:     modelConf.Config.Input[0].Name,
+			Datatype: "FP32",
+			Shape:    modelConf.Config.Input[0].Dims,
+		},
+	}
+	infer, err := tritonGRPCClient.ModelGRPCInfer(inferInputs, nil, [][]byte{input}, "face_detection_retina", "1", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "face_detection_retina", infer.ModelName)
+	assert.Equal(t, []byte{42, 0, 0, 0}, infer.RawOutputContents[0])
+
+	requests := server.InferRequests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, []int64{1, 3, 640, 640}, requests[0].Inputs[0].Shape)
+
+	server.InjectError("ModelInfer", status.Error(codes.ResourceExhausted, "queue is full"))
+	_, err = tritonGRPCClient.ModelGRPCInfer(inferInputs, nil, [][]byte{input}, "face_detection_retina", "1", 10*time.Second)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	server.InjectError("ModelInfer", nil)
+	server.InjectLatency("ModelInfer", time.Second)
+	_, err = tritonGRPCClient.ModelGRPCInfer(inferInputs, nil, [][]byte{input}, "face_detection_retina", "1", 10*time.Millisecond)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestTritonGRPCClient_Context(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: faceDetectionRetinaConfig,
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	for _, method := range []string{"ModelInfer", "ServerMetadata", "ModelConfig", "RepositoryIndex"} {
+		server.InjectLatency(method, 10*time.Second)
+	}
+
+	calls := map[string]func(ctx context.Context) error{
+		"ModelInfer": func(ctx context.Context) error {
+			_, err := client.ModelInfer(ctx, &grpc_client.ModelInferRequest{ModelName: "face_detection_retina"})
+			return err
+		},
+		"ServerMetadataContext": func(ctx context.Context) error {
+			_, err := client.ServerMetadataContext(ctx)
+			return err
+		},
+		"GetModelConfigurationContext": func(ctx context.Context) error {
+			_, err := client.GetModelConfigurationContext(ctx, "face_detection_retina", "")
+			return err
+		},
+		"ModelRepositoryIndexContext": func(ctx context.Context) error {
+			_, err := client.ModelRepositoryIndexContext(ctx, "", false)
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			// cancelling the context aborts the slow call
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			start := time.Now()
+			err := call(ctx)
+			assert.Equal(t, codes.Canceled, status.Code(err))
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
+
+	// the timeout wrappers apply their deadline
+	wrappers := map[string]func(timeout time.Duration) error{
+		"ServerMetadata": func(timeout time.Duration) error {
+			_, err := client.ServerMetadata(timeout)
+			return err
+		},
+		"GetModelConfiguration": func(timeout time.Duration) error {
+			_, err := client.GetModelConfiguration("face_detection_retina", "", timeout)
+			return err
+		},
+		"ModelRepositoryIndex": func(timeout time.Duration) error {
+			_, err := client.ModelRepositoryIndex("", false, timeout)
+			return err
+		},
+	}
+	for name, wrapper := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			err := wrapper(20 * time.Millisecond)
+			assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+			assert.Less(t, time.Since(start), time.Second)
+		})
+	}
 }
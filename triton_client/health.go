@@ -0,0 +1,317 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrNotServing is returned by inference calls gated by a HealthMonitor while Triton is not
+// serving.
+var ErrNotServing = errors.New("triton is not serving")
+
+// Health monitor defaults.
+const (
+	DefaultHealthCheckInterval = 5 * time.Second
+	DefaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// healthWatchMethod is the streaming RPC of the health protocol. The generated health client
+// only has Check, which is all Triton implements.
+const healthWatchMethod = "/grpc.health.v1.Health/Watch"
+
+// HealthStatus is the health of Triton as seen by a HealthMonitor.
+type HealthStatus struct {
+	// ServingStatus is the last status reported by the health service, UNKNOWN before the
+	// first report and after a failed check.
+	ServingStatus grpc_client.HealthCheckResponse_ServingStatus
+	// ConnState is the state of the client connection.
+	ConnState connectivity.State
+	// Err is the error of the last failed check, nil once a check succeeds.
+	Err error
+	// Since is when ServingStatus or ConnState last changed.
+	Since time.Time
+}
+
+// Serving reports whether Triton reports SERVING over a usable connection.
+func (s HealthStatus) Serving() bool {
+	return s.ServingStatus == grpc_client.HealthCheckResponse_SERVING &&
+		s.ConnState != connectivity.TransientFailure && s.ConnState != connectivity.Shutdown
+}
+
+// HealthMonitorConfig configures a HealthMonitor, zero values take the defaults.
+type HealthMonitorConfig struct {
+	// Service is the health service to follow, the empty service is the server as a whole.
+	Service string
+	// CheckInterval is the interval between checks when the server does not implement Watch,
+	// and between attempts to reopen a failed Watch.
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+}
+
+// HealthMonitor follows the health of Triton with the gRPC health protocol and the state of the
+// client connection. It watches the health service and falls back to polling Check when the
+// server does not implement Watch, as Triton does not. A connection in transient failure or
+// shut down is not serving whatever the last report. Changes are published to subscribers, the
+// monitor can gate inference calls, see TritonGRPCClient.SetHealthGate, and answer HTTP
+// readiness probes.
+type HealthMonitor struct {
+	client    *TritonGRPCClient
+	config    HealthMonitorConfig
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	connReady chan struct{}
+
+	mu          sync.Mutex
+	status      HealthStatus
+	subscribers map[chan HealthStatus]struct{}
+	closed      bool
+}
+
+// NewHealthMonitor checks the health of Triton once using the given context, then follows it
+// in the background until Close is called.
+func NewHealthMonitor(ctx context.Context, client *TritonGRPCClient, config HealthMonitorConfig) *HealthMonitor {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultHealthCheckInterval
+	}
+	if config.CheckTimeout <= 0 {
+		config.CheckTimeout = DefaultHealthCheckTimeout
+	}
+
+	m := &HealthMonitor{
+		client:      client,
+		config:      config,
+		connReady:   make(chan struct{}, 1),
+		status:      HealthStatus{Since: time.Now()},
+		subscribers: make(map[chan HealthStatus]struct{}),
+	}
+	m.check(ctx)
+	m.setConnState(client.grpcConn.GetState())
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(2)
+	go m.watchConn(loopCtx)
+	go m.watchHealth(loopCtx)
+	return m
+}
+
+// Status returns the current health.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Ready returns nil while Triton is serving and an error wrapping ErrNotServing otherwise. A nil
+// monitor is always ready.
+func (m *HealthMonitor) Ready() error {
+	if m == nil {
+		return nil
+	}
+	health := m.Status()
+	if health.Serving() {
+		return nil
+	}
+	if health.Err != nil {
+		return fmt.Errorf("%w: health %s, connection %s: %w", ErrNotServing, health.ServingStatus, health.ConnState, health.Err)
+	}
+	return fmt.Errorf("%w: health %s, connection %s", ErrNotServing, health.ServingStatus, health.ConnState)
+}
+
+// ServeHTTP answers readiness probes, with 200 while Triton is serving and 503 otherwise.
+func (m *HealthMonitor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if err := m.Ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+// Subscribe returns a channel receiving the current health, then every change of the serving
+// status or connection state. A subscriber that falls behind only receives the latest health.
+// The channel is closed by the returned function or Close.
+func (m *HealthMonitor) Subscribe() (<-chan HealthStatus, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan HealthStatus, 1)
+	if m.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	ch <- m.status
+	m.subscribers[ch] = struct{}{}
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close stops following the health, closes the subscriptions and removes the monitor from the
+// gate of its client. It does not close the client.
+func (m *HealthMonitor) Close() {
+	m.cancel()
+	m.wg.Wait()
+	m.client.healthGate.CompareAndSwap(m, nil)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	for ch := range m.subscribers {
+		close(ch)
+	}
+	m.subscribers = nil
+}
+
+// watchConn follows the state of the client connection until ctx ends.
+func (m *HealthMonitor) watchConn(ctx context.Context) {
+	defer m.wg.Done()
+	state := m.client.grpcConn.GetState()
+	for m.client.grpcConn.WaitForStateChange(ctx, state) {
+		state = m.client.grpcConn.GetState()
+		m.setConnState(state)
+		if state == connectivity.Ready {
+			// check at once after a reconnection rather than at the next interval
+			select {
+			case m.connReady <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// watchHealth follows the health service with Watch, or Check when Watch is not implemented,
+// until ctx ends.
+func (m *HealthMonitor) watchHealth(ctx context.Context) {
+	defer m.wg.Done()
+	for {
+		err := m.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			break
+		}
+		m.setServingStatus(grpc_client.HealthCheckResponse_UNKNOWN, err)
+		if !m.wait(ctx) {
+			return
+		}
+	}
+
+	for m.wait(ctx) {
+		m.check(ctx)
+	}
+}
+
+// watch streams the serving status until the stream fails.
+func (m *HealthMonitor) watch(ctx context.Context) error {
+	stream, err := m.client.grpcConn.NewStream(ctx, &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}, healthWatchMethod)
+	if err != nil {
+		return err
+	}
+	if err = stream.SendMsg(&grpc_client.HealthCheckRequest{Service: m.config.Service}); err != nil {
+		return err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		response := &grpc_client.HealthCheckResponse{}
+		if err = stream.RecvMsg(response); err != nil {
+			if err == io.EOF {
+				return errors.New("health watch ended")
+			}
+			return err
+		}
+		m.setServingStatus(response.Status, nil)
+	}
+}
+
+// check checks the serving status once.
+func (m *HealthMonitor) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.CheckTimeout)
+	defer cancel()
+
+	servingStatus, err := m.client.HealthCheckContext(ctx, m.config.Service)
+	if status.Code(err) == codes.NotFound {
+		servingStatus, err = grpc_client.HealthCheckResponse_SERVICE_UNKNOWN, nil
+	}
+	m.setServingStatus(servingStatus, err)
+}
+
+// wait waits for the check interval or a reconnection and reports whether ctx is still alive.
+func (m *HealthMonitor) wait(ctx context.Context) bool {
+	timer := time.NewTimer(m.config.CheckInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	case <-m.connReady:
+	}
+	return true
+}
+
+func (m *HealthMonitor) setServingStatus(servingStatus grpc_client.HealthCheckResponse_ServingStatus, err error) {
+	m.update(func(health *HealthStatus) {
+		health.ServingStatus = servingStatus
+		health.Err = err
+	})
+}
+
+func (m *HealthMonitor) setConnState(state connectivity.State) {
+	m.update(func(health *HealthStatus) {
+		health.ConnState = state
+	})
+}
+
+// update changes the health and publishes it when the serving status or connection state
+// changed.
+func (m *HealthMonitor) update(change func(health *HealthStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := m.status
+	change(&health)
+	if health.ServingStatus == m.status.ServingStatus && health.ConnState == m.status.ConnState {
+		m.status.Err = health.Err
+		return
+	}
+	health.Since = time.Now()
+	m.status = health
+	for ch := range m.subscribers {
+		select {
+		case ch <- health:
+		default:
+			// the subscriber fell behind, replace its unread health with the latest
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- health
+		}
+	}
+}
+
+// SetHealthGate makes inference calls fail fast with ErrNotServing while the monitor reports
+// Triton is not serving, nil removes the gate.
+func (tc *TritonGRPCClient) SetHealthGate(monitor *HealthMonitor) {
+	tc.healthGate.Store(monitor)
+}
@@ -0,0 +1,183 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthMonitor_Check(t *testing.T) {
+	ctx := context.Background()
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "echo"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+
+	monitor := NewHealthMonitor(ctx, client, HealthMonitorConfig{CheckInterval: 10 * time.Millisecond})
+	defer monitor.Close()
+	client.SetHealthGate(monitor)
+	updates, unsubscribe := monitor.Subscribe()
+	defer unsubscribe()
+
+	assert.True(t, monitor.Status().Serving())
+	assert.NoError(t, monitor.Ready())
+	assert.Equal(t, grpc_client.HealthCheckResponse_SERVING, (<-updates).ServingStatus)
+	assert.Eventually(t, func() bool { return monitor.Status().ConnState == connectivity.Ready }, time.Second, time.Millisecond)
+	_, err := client.Infer(ctx, "echo", "", nil, nil)
+	assert.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	monitor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// Triton does not implement Watch, the monitor polls Check
+	server.SetServerReady(false)
+	assert.Eventually(t, func() bool {
+		select {
+		case update := <-updates:
+			return update.ServingStatus == grpc_client.HealthCheckResponse_NOT_SERVING
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.Greater(t, len(server.Requests("Check")), 1)
+	assert.ErrorIs(t, monitor.Ready(), ErrNotServing)
+	recorder = httptest.NewRecorder()
+	monitor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	// inference fails fast
+	server.ResetRequests()
+	_, err = client.Infer(ctx, "echo", "", nil, nil)
+	assert.ErrorIs(t, err, ErrNotServing)
+	_, err = client.ModelStreamInfer(ctx, func(*StreamResult) {})
+	assert.ErrorIs(t, err, ErrNotServing)
+	assert.Empty(t, server.InferRequests())
+
+	server.SetServerReady(true)
+	assert.Eventually(t, func() bool { return monitor.Ready() == nil }, time.Second, time.Millisecond)
+	_, err = client.Infer(ctx, "echo", "", nil, nil)
+	assert.NoError(t, err)
+
+	// closing removes the gate
+	server.SetServerReady(false)
+	assert.Eventually(t, func() bool { return monitor.Ready() != nil }, time.Second, time.Millisecond)
+	monitor.Close()
+	_, ok := <-updates
+	for ok {
+		_, ok = <-updates
+	}
+	_, err = client.Infer(ctx, "echo", "", nil, nil)
+	assert.NoError(t, err)
+
+	// unknown services
+	monitor = NewHealthMonitor(ctx, client, HealthMonitorConfig{Service: "other"})
+	defer monitor.Close()
+	assert.Equal(t, grpc_client.HealthCheckResponse_SERVICE_UNKNOWN, monitor.Status().ServingStatus)
+	assert.NoError(t, monitor.Status().Err)
+}
+
+// watchHealthServer implements the health Check and Watch, the generated health service lacks
+// Watch.
+type watchHealthServer struct {
+	mu       sync.Mutex
+	status   grpc_client.HealthCheckResponse_ServingStatus
+	watchers []chan grpc_client.HealthCheckResponse_ServingStatus
+}
+
+func (s *watchHealthServer) setStatus(servingStatus grpc_client.HealthCheckResponse_ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = servingStatus
+	for _, watcher := range s.watchers {
+		watcher <- servingStatus
+	}
+}
+
+func (s *watchHealthServer) check(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	if err := dec(&grpc_client.HealthCheckRequest{}); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &grpc_client.HealthCheckResponse{Status: s.status}, nil
+}
+
+func (s *watchHealthServer) watch(_ interface{}, stream grpc.ServerStream) error {
+	if err := stream.RecvMsg(&grpc_client.HealthCheckRequest{}); err != nil {
+		return err
+	}
+	updates := make(chan grpc_client.HealthCheckResponse_ServingStatus, 10)
+	s.mu.Lock()
+	updates <- s.status
+	s.watchers = append(s.watchers, updates)
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case servingStatus := <-updates:
+			if err := stream.SendMsg(&grpc_client.HealthCheckResponse{Status: servingStatus}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func TestHealthMonitor_Watch(t *testing.T) {
+	ctx := context.Background()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	healthServer := &watchHealthServer{status: grpc_client.HealthCheckResponse_SERVING}
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "grpc.health.v1.Health",
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Check", Handler: healthServer.check}},
+		Streams:     []grpc.StreamDesc{{StreamName: "Watch", Handler: healthServer.watch, ServerStreams: true}},
+	}, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	client, err := NewTritonGRPCClient("bufnet", []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	})
+	assert.NoError(t, err)
+	defer func() {
+		_ = client.Disconnect()
+	}()
+
+	// changes are streamed without waiting for the check interval
+	monitor := NewHealthMonitor(ctx, client, HealthMonitorConfig{CheckInterval: time.Hour})
+	defer monitor.Close()
+	assert.True(t, monitor.Status().Serving())
+	healthServer.setStatus(grpc_client.HealthCheckResponse_NOT_SERVING)
+	assert.Eventually(t, func() bool {
+		return monitor.Status().ServingStatus == grpc_client.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond)
+	healthServer.setStatus(grpc_client.HealthCheckResponse_SERVING)
+	assert.Eventually(t, func() bool { return monitor.Ready() == nil }, time.Second, time.Millisecond)
+
+	// losing the server ends the watch
+	server.Stop()
+	assert.Eventually(t, func() bool { return monitor.Ready() != nil }, time.Second, time.Millisecond)
+	assert.Error(t, monitor.Status().Err)
+}
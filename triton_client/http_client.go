@@ -1,8 +1,21 @@
 package triton_client
 
 import (
-	"google.golang.org/grpc"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +25,8 @@ const (
 	DefaultHTTPClientMaxConnPerHost      int    = 16384
 	HTTPPrefix                           string = "http://"
 	JSONContentType                      string = "application/json"
+	BinaryContentType                    string = "application/octet-stream"
+	InferenceHeaderContentLength         string = "Inference-Header-Content-Length"
 	TritonAPIForModelVersionPrefix       string = "/versions/"
 	TritonAPIPrefix                      string = "/v2"
 	TritonAPIForServerIsLive                    = TritonAPIPrefix + "/health/live"
@@ -19,25 +34,592 @@ const (
 	TritonAPIForRepoIndex                       = TritonAPIPrefix + "/repository/index"
 	TritonAPIForRepoModelPrefix                 = TritonAPIPrefix + "/repository/models/"
 	TritonAPIForModelPrefix                     = TritonAPIPrefix + "/models/"
-	TritonAPIForCudaMemoryRegionPrefix          = TritonAPIPrefix + "/cudasharememory/region/"
-	TritonAPIForSystemMemoryRegionPrefix        = TritonAPIPrefix + "/systemsharememory/region/"
+	TritonAPIForModelStats                      = TritonAPIPrefix + "/models/stats"
+	TritonAPIForCudaMemory                      = TritonAPIPrefix + "/cudasharedmemory"
+	TritonAPIForCudaMemoryRegionPrefix          = TritonAPIForCudaMemory + "/region/"
+	TritonAPIForSystemMemory                    = TritonAPIPrefix + "/systemsharedmemory"
+	TritonAPIForSystemMemoryRegionPrefix        = TritonAPIForSystemMemory + "/region/"
+	TritonAPIForTraceSetting                    = TritonAPIPrefix + "/trace/setting"
+	TritonAPIForLogging                         = TritonAPIPrefix + "/logging"
 )
 
-var tritonHTTPClient *TritonGRPCClient
+// HTTPError is returned when Triton answers an HTTP request with a non-success status.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("triton http status %d: %s", e.StatusCode, e.Message)
+}
 
+var protoJSONUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// TritonHTTPClientService is a client for the Triton HTTP/REST (KServe v2) protocol. Responses
+// are decoded into the same generated types the gRPC client returns.
 type TritonHTTPClientService struct {
-	serverURL   string
-	httpConn    *grpc.ClientConn
-	httpClient  *http.Client
-	modelConfig interface{}
-	modelName   interface{}
+	serverURL  string
+	httpClient *http.Client
+	binaryData bool
+	modelCache *modelCache
+}
+
+// NewTritonHTTPClient inits a new HTTP client for serverURL, e.g. "127.0.0.1:8000". When
+// httpClient is nil a client with the package default timeouts is used.
+func NewTritonHTTPClient(serverURL string, httpClient *http.Client) (*TritonHTTPClientService, error) {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = HTTPPrefix + serverURL
+	}
+	if _, err := url.Parse(serverURL); err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: DefaultHTTPClientWriteTimeout}).DialContext,
+				MaxConnsPerHost:       DefaultHTTPClientMaxConnPerHost,
+				MaxIdleConnsPerHost:   DefaultHTTPClientMaxConnPerHost,
+				ResponseHeaderTimeout: DefaultHTTPClientReadTimeout,
+			},
+		}
+	}
+	return &TritonHTTPClientService{
+		serverURL:  strings.TrimRight(serverURL, "/"),
+		httpClient: httpClient,
+		binaryData: true,
+		modelCache: newModelCache(DefaultModelCacheTTL),
+	}, nil
+}
+
+// SetBinaryData chooses whether inference tensors are exchanged with the binary data
+// extension, the default, or as JSON arrays. FP16 and BF16 tensors require binary data.
+// It must be called before the client is used concurrently.
+func (hc *TritonHTTPClientService) SetBinaryData(enabled bool) {
+	hc.binaryData = enabled
+}
+
+// modelPath returns the route of a model, with the version when it is set.
+func modelPath(modelName, modelVersion string) string {
+	path := TritonAPIForModelPrefix + url.PathEscape(modelName)
+	if modelVersion != "" {
+		path += TritonAPIForModelVersionPrefix + url.PathEscape(modelVersion)
+	}
+	return path
+}
+
+// do sends a request with an optional JSON body and returns the response body. Non-success
+// statuses are returned as *HTTPError.
+func (hc *TritonHTTPClientService) do(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, hc.serverURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", JSONContentType)
+	}
+	respBody, _, err := hc.send(req)
+	return respBody, err
+}
+
+// send executes req and returns the response body and headers. Non-success statuses are
+// returned as *HTTPError.
+func (hc *TritonHTTPClientService) send(req *http.Request) ([]byte, http.Header, error) {
+	resp, err := hc.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, newHTTPError(resp.StatusCode, respBody)
+	}
+	return respBody, resp.Header, nil
+}
+
+func newHTTPError(statusCode int, body []byte) *HTTPError {
+	var errorBody struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &errorBody) == nil && errorBody.Error != "" {
+		message = errorBody.Error
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &HTTPError{StatusCode: statusCode, Message: message}
+}
+
+// getProto fetches path and decodes the JSON response into msg.
+func (hc *TritonHTTPClientService) getProto(ctx context.Context, path string, msg proto.Message) error {
+	respBody, err := hc.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return protoJSONUnmarshal.Unmarshal(respBody, msg)
+}
+
+// health returns whether a health endpoint answers with a success status. Triton answers 503
+// when not live or ready and 400 when a model is not ready, other statuses are errors.
+func (hc *TritonHTTPClientService) health(ctx context.Context, path string) (bool, error) {
+	_, err := hc.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusServiceUnavailable ||
+			httpErr.StatusCode == http.StatusBadRequest && strings.HasSuffix(path, "/ready")) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ServerAliveContext check server is alive using the given context.
+func (hc *TritonHTTPClientService) ServerAliveContext(ctx context.Context) (bool, error) {
+	return hc.health(ctx, TritonAPIForServerIsLive)
+}
+
+// ServerReadyContext check server is ready using the given context.
+func (hc *TritonHTTPClientService) ServerReadyContext(ctx context.Context) (bool, error) {
+	return hc.health(ctx, TritonAPIForServerIsReady)
+}
+
+// ModelReadyContext check model is ready using the given context.
+func (hc *TritonHTTPClientService) ModelReadyContext(ctx context.Context, modelName, modelVersion string) (bool, error) {
+	return hc.health(ctx, modelPath(modelName, modelVersion)+"/ready")
+}
+
+// WaitForModelReady polls the model every pollInterval, DefaultModelReadyPollInterval when not
+// positive, until it reports ready or ctx ends. Errors while polling, e.g. while the model is still loading, do not stop the wait.
+func (hc *TritonHTTPClientService) WaitForModelReady(ctx context.Context, modelName, modelVersion string, pollInterval time.Duration) error {
+	return waitForModelReady(ctx, hc, modelName, modelVersion, pollInterval)
+}
+
+// ServerMetadataContext Get server metadata using the given context.
+func (hc *TritonHTTPClientService) ServerMetadataContext(ctx context.Context) (*grpc_client.ServerMetadataResponse, error) {
+	serverMetadataResponse := &grpc_client.ServerMetadataResponse{}
+	err := hc.getProto(ctx, TritonAPIPrefix, serverMetadataResponse)
+	if err != nil {
+		return nil, err
+	}
+	return serverMetadataResponse, nil
+}
+
+// ModelMetadataContext Get model metadata using the given context.
+func (hc *TritonHTTPClientService) ModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error) {
+	modelMetadataResponse := &grpc_client.ModelMetadataResponse{}
+	err := hc.getProto(ctx, modelPath(modelName, modelVersion), modelMetadataResponse)
+	if err != nil {
+		return nil, err
+	}
+	return modelMetadataResponse, nil
+}
+
+// CachedModelMetadataContext Get model metadata from the client cache, fetching it on a miss.
+func (hc *TritonHTTPClientService) CachedModelMetadataContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error) {
+	return hc.modelCache.metadata(ctx, modelName, modelVersion, hc.ModelMetadataContext)
+}
+
+// GetModelConfigurationContext Get model configuration using the given context.
+func (hc *TritonHTTPClientService) GetModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error) {
+	modelConfig := &grpc_client.ModelConfig{}
+	err := hc.getProto(ctx, modelPath(modelName, modelVersion)+"/config", modelConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc_client.ModelConfigResponse{Config: modelConfig}, nil
+}
+
+// CachedModelConfigurationContext Get model configuration from the client cache, fetching it on a miss.
+func (hc *TritonHTTPClientService) CachedModelConfigurationContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error) {
+	return hc.modelCache.config(ctx, modelName, modelVersion, hc.GetModelConfigurationContext)
+}
+
+// InvalidateModelCache drops the cached configuration and metadata of the model, or of all
+// models when modelName is empty.
+func (hc *TritonHTTPClientService) InvalidateModelCache(modelName string) {
+	hc.modelCache.invalidate(modelName)
+}
+
+// SetModelCacheTTL changes how long model configurations and metadata are cached, a ttl <= 0
+// caches them until they are invalidated.
+func (hc *TritonHTTPClientService) SetModelCacheTTL(ttl time.Duration) {
+	hc.modelCache.setTTL(ttl)
+}
+
+// ModelInferStatsContext Get Model infer stats using the given context. An empty modelName
+// returns the statistics of every model.
+func (hc *TritonHTTPClientService) ModelInferStatsContext(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelStatisticsResponse, error) {
+	path := TritonAPIForModelStats
+	if modelName != "" {
+		path = modelPath(modelName, modelVersion) + "/stats"
+	}
+	modelStatisticsResponse := &grpc_client.ModelStatisticsResponse{}
+	err := hc.getProto(ctx, path, modelStatisticsResponse)
+	if err != nil {
+		return nil, err
+	}
+	return modelStatisticsResponse, nil
 }
 
-func GetHTTPInstance() *TritonGRPCClient {
-	return tritonHTTPClient
+// ModelRepositoryIndexContext Get model repo index using the given context. Triton serves the
+// index of all repositories over HTTP, so repoName is ignored.
+func (hc *TritonHTTPClientService) ModelRepositoryIndexContext(ctx context.Context, repoName string, isReady bool) (*grpc_client.RepositoryIndexResponse, error) {
+	respBody, err := hc.do(ctx, http.MethodPost, TritonAPIForRepoIndex, map[string]bool{"ready": isReady})
+	if err != nil {
+		return nil, err
+	}
+
+	var models []json.RawMessage
+	err = json.Unmarshal(respBody, &models)
+	if err != nil {
+		return nil, err
+	}
+	repositoryIndexResponse := &grpc_client.RepositoryIndexResponse{}
+	for _, model := range models {
+		modelIndex := &grpc_client.RepositoryIndexResponse_ModelIndex{}
+		err = protoJSONUnmarshal.Unmarshal(model, modelIndex)
+		if err != nil {
+			return nil, err
+		}
+		repositoryIndexResponse.Models = append(repositoryIndexResponse.Models, modelIndex)
+	}
+	return repositoryIndexResponse, nil
+}
+
+// repositoryParameters converts repository parameters to their JSON values. File contents
+// are base64 encoded as Triton expects.
+func repositoryParameters(parameters map[string]*grpc_client.ModelRepositoryParameter) map[string]interface{} {
+	values := make(map[string]interface{}, len(parameters))
+	for key, param := range parameters {
+		switch choice := param.GetParameterChoice().(type) {
+		case *grpc_client.ModelRepositoryParameter_BoolParam:
+			values[key] = choice.BoolParam
+		case *grpc_client.ModelRepositoryParameter_Int64Param:
+			values[key] = choice.Int64Param
+		case *grpc_client.ModelRepositoryParameter_StringParam:
+			values[key] = choice.StringParam
+		case *grpc_client.ModelRepositoryParameter_BytesParam:
+			values[key] = base64.StdEncoding.EncodeToString(choice.BytesParam)
+		}
+	}
+	return values
+}
+
+// ModelLoadContext Load model using the given context. Triton serves all repositories over
+// HTTP, so repoName is ignored.
+func (hc *TritonHTTPClientService) ModelLoadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error {
+	defer hc.modelCache.invalidate(modelName)
+	body := map[string]interface{}{"parameters": repositoryParameters(modelConfigBody)}
+	_, err := hc.do(ctx, http.MethodPost, TritonAPIForRepoModelPrefix+url.PathEscape(modelName)+"/load", body)
+	return err
+}
+
+// ModelUnloadContext Unload model using the given context. Triton serves all repositories
+// over HTTP, so repoName is ignored.
+func (hc *TritonHTTPClientService) ModelUnloadContext(ctx context.Context, repoName, modelName string, modelConfigBody map[string]*grpc_client.ModelRepositoryParameter) error {
+	defer hc.modelCache.invalidate(modelName)
+	body := map[string]interface{}{"parameters": repositoryParameters(modelConfigBody)}
+	_, err := hc.do(ctx, http.MethodPost, TritonAPIForRepoModelPrefix+url.PathEscape(modelName)+"/unload", body)
+	return err
+}
+
+// sharedMemoryPath returns the route of a shared memory action, for all regions when
+// regionName is empty.
+func sharedMemoryPath(base, regionName, action string) string {
+	if regionName == "" {
+		return base + "/" + action
+	}
+	return base + "/region/" + url.PathEscape(regionName) + "/" + action
+}
+
+// SystemSharedMemoryStatusContext Get system share memory status using the given context.
+func (hc *TritonHTTPClientService) SystemSharedMemoryStatusContext(ctx context.Context, regionName string) (*grpc_client.SystemSharedMemoryStatusResponse, error) {
+	respBody, err := hc.do(ctx, http.MethodGet, sharedMemoryPath(TritonAPIForSystemMemory, regionName, "status"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []json.RawMessage
+	err = json.Unmarshal(respBody, &regions)
+	if err != nil {
+		return nil, err
+	}
+	systemSharedMemoryStatusResponse := &grpc_client.SystemSharedMemoryStatusResponse{
+		Regions: make(map[string]*grpc_client.SystemSharedMemoryStatusResponse_RegionStatus, len(regions)),
+	}
+	for _, region := range regions {
+		regionStatus := &grpc_client.SystemSharedMemoryStatusResponse_RegionStatus{}
+		err = protoJSONUnmarshal.Unmarshal(region, regionStatus)
+		if err != nil {
+			return nil, err
+		}
+		systemSharedMemoryStatusResponse.Regions[regionStatus.Name] = regionStatus
+	}
+	return systemSharedMemoryStatusResponse, nil
+}
+
+// CudaSharedMemoryStatusContext Get cuda share memory status using the given context.
+func (hc *TritonHTTPClientService) CudaSharedMemoryStatusContext(ctx context.Context, regionName string) (*grpc_client.CudaSharedMemoryStatusResponse, error) {
+	respBody, err := hc.do(ctx, http.MethodGet, sharedMemoryPath(TritonAPIForCudaMemory, regionName, "status"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []json.RawMessage
+	err = json.Unmarshal(respBody, &regions)
+	if err != nil {
+		return nil, err
+	}
+	cudaSharedMemoryStatusResponse := &grpc_client.CudaSharedMemoryStatusResponse{
+		Regions: make(map[string]*grpc_client.CudaSharedMemoryStatusResponse_RegionStatus, len(regions)),
+	}
+	for _, region := range regions {
+		regionStatus := &grpc_client.CudaSharedMemoryStatusResponse_RegionStatus{}
+		err = protoJSONUnmarshal.Unmarshal(region, regionStatus)
+		if err != nil {
+			return nil, err
+		}
+		cudaSharedMemoryStatusResponse.Regions[regionStatus.Name] = regionStatus
+	}
+	return cudaSharedMemoryStatusResponse, nil
+}
+
+// ShareSystemMemoryRegisterContext system share memory register using the given context.
+func (hc *TritonHTTPClientService) ShareSystemMemoryRegisterContext(ctx context.Context, regionName, cpuMemRegionKey string, byteSize, cpuMemOffset uint64) error {
+	body := map[string]interface{}{
+		"key":       cpuMemRegionKey,
+		"offset":    cpuMemOffset,
+		"byte_size": byteSize,
+	}
+	_, err := hc.do(ctx, http.MethodPost, sharedMemoryPath(TritonAPIForSystemMemory, regionName, "register"), body)
+	return err
+}
+
+// ShareSystemMemoryUnRegisterContext system share memory unregister using the given context.
+// An empty regionName unregisters every region.
+func (hc *TritonHTTPClientService) ShareSystemMemoryUnRegisterContext(ctx context.Context, regionName string) error {
+	_, err := hc.do(ctx, http.MethodPost, sharedMemoryPath(TritonAPIForSystemMemory, regionName, "unregister"), nil)
+	return err
+}
+
+// ShareCUDAMemoryRegisterContext cuda share memory register using the given context.
+func (hc *TritonHTTPClientService) ShareCUDAMemoryRegisterContext(ctx context.Context, regionName string, cudaRawHandle []byte, cudaDeviceID int64, byteSize uint64) error {
+	body := map[string]interface{}{
+		"raw_handle": map[string]string{"b64": base64.StdEncoding.EncodeToString(cudaRawHandle)},
+		"device_id":  cudaDeviceID,
+		"byte_size":  byteSize,
+	}
+	_, err := hc.do(ctx, http.MethodPost, sharedMemoryPath(TritonAPIForCudaMemory, regionName, "register"), body)
+	return err
+}
+
+// ShareCUDAMemoryUnRegisterContext cuda share memory unregister using the given context.
+// An empty regionName unregisters every region.
+func (hc *TritonHTTPClientService) ShareCUDAMemoryUnRegisterContext(ctx context.Context, regionName string) error {
+	_, err := hc.do(ctx, http.MethodPost, sharedMemoryPath(TritonAPIForCudaMemory, regionName, "unregister"), nil)
+	return err
 }
 
-func NewTritonHTTPClient(serverURL string, opts []grpc.DialOption) error {
+// traceSettingPath returns the trace setting route of a model, or the global one when
+// modelName is empty.
+func traceSettingPath(modelName string) string {
+	if modelName == "" {
+		return TritonAPIForTraceSetting
+	}
+	return modelPath(modelName, "") + "/trace/setting"
+}
+
+// decodeTraceSettings converts the JSON trace settings, whose values are either a string or
+// a list of strings, into a TraceSettingResponse.
+func decodeTraceSettings(respBody []byte) (*grpc_client.TraceSettingResponse, error) {
+	var settings map[string]json.RawMessage
+	err := json.Unmarshal(respBody, &settings)
+	if err != nil {
+		return nil, err
+	}
+
+	traceSettingResponse := &grpc_client.TraceSettingResponse{
+		Settings: make(map[string]*grpc_client.TraceSettingResponse_SettingValue, len(settings)),
+	}
+	for key, raw := range settings {
+		var values []string
+		if err = json.Unmarshal(raw, &values); err != nil {
+			var value string
+			if err = json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("trace setting %s: %w", key, err)
+			}
+			values = []string{value}
+		}
+		traceSettingResponse.Settings[key] = &grpc_client.TraceSettingResponse_SettingValue{Value: values}
+	}
+	return traceSettingResponse, nil
+}
+
+// GetModelTracingSettingContext get model tracing setting using the given context.
+func (hc *TritonHTTPClientService) GetModelTracingSettingContext(ctx context.Context, modelName string) (*grpc_client.TraceSettingResponse, error) {
+	respBody, err := hc.do(ctx, http.MethodGet, traceSettingPath(modelName), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTraceSettings(respBody)
+}
+
+// SetModelTracingSettingContext set model tracing setting using the given context. A setting
+// without values is cleared.
+func (hc *TritonHTTPClientService) SetModelTracingSettingContext(ctx context.Context, modelName string, settingMap map[string]*grpc_client.TraceSettingRequest_SettingValue) (*grpc_client.TraceSettingResponse, error) {
+	body := make(map[string]interface{}, len(settingMap))
+	for key, setting := range settingMap {
+		switch {
+		case len(setting.GetValue()) == 0:
+			body[key] = nil
+		case key == "trace_level":
+			body[key] = setting.Value
+		default:
+			body[key] = setting.Value[0]
+		}
+	}
+
+	respBody, err := hc.do(ctx, http.MethodPost, traceSettingPath(modelName), body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTraceSettings(respBody)
+}
+
+// decodeLogSettings converts the JSON log settings into a LogSettingsResponse.
+func decodeLogSettings(respBody []byte) (*grpc_client.LogSettingsResponse, error) {
+	var settings map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	err := decoder.Decode(&settings)
+	if err != nil {
+		return nil, err
+	}
+
+	logSettingsResponse := &grpc_client.LogSettingsResponse{
+		Settings: make(map[string]*grpc_client.LogSettingsResponse_SettingValue, len(settings)),
+	}
+	for key, value := range settings {
+		setting := &grpc_client.LogSettingsResponse_SettingValue{}
+		switch v := value.(type) {
+		case bool:
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_BoolParam{BoolParam: v}
+		case json.Number:
+			number, err := v.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("log setting %s: %w", key, err)
+			}
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_Uint32Param{Uint32Param: uint32(number)}
+		case string:
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_StringParam{StringParam: v}
+		default:
+			return nil, fmt.Errorf("log setting %s has unsupported value %v", key, value)
+		}
+		logSettingsResponse.Settings[key] = setting
+	}
+	return logSettingsResponse, nil
+}
+
+// GetLogSettingsContext get server log settings using the given context.
+func (hc *TritonHTTPClientService) GetLogSettingsContext(ctx context.Context) (*grpc_client.LogSettingsResponse, error) {
+	respBody, err := hc.do(ctx, http.MethodGet, TritonAPIForLogging, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLogSettings(respBody)
+}
+
+// SetLogSettingsContext update server log settings, e.g. "log_verbose_level", using the given context.
+func (hc *TritonHTTPClientService) SetLogSettingsContext(ctx context.Context, settingMap map[string]*grpc_client.LogSettingsRequest_SettingValue) (*grpc_client.LogSettingsResponse, error) {
+	body := make(map[string]interface{}, len(settingMap))
+	for key, setting := range settingMap {
+		switch choice := setting.GetParameterChoice().(type) {
+		case *grpc_client.LogSettingsRequest_SettingValue_BoolParam:
+			body[key] = choice.BoolParam
+		case *grpc_client.LogSettingsRequest_SettingValue_Uint32Param:
+			body[key] = choice.Uint32Param
+		case *grpc_client.LogSettingsRequest_SettingValue_StringParam:
+			body[key] = choice.StringParam
+		}
+	}
+
+	respBody, err := hc.do(ctx, http.MethodPost, TritonAPIForLogging, body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLogSettings(respBody)
+}
+
+// ModelInfer sends a fully built inference request to Triton using the given context. Tensor
+// data is converted to and from the raw contents used by the gRPC protocol, so the response
+// can be wrapped by NewInferResult like a gRPC response.
+func (hc *TritonHTTPClientService) ModelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	request, payload, err := newJSONInferRequest(modelInferRequest, hc.binaryData)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	path := modelPath(modelInferRequest.ModelName, modelInferRequest.ModelVersion) + "/infer"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hc.serverURL+path, bytes.NewReader(append(header, payload...)))
+	if err != nil {
+		return nil, err
+	}
+	if hc.binaryData {
+		req.Header.Set("Content-Type", BinaryContentType)
+		req.Header.Set(InferenceHeaderContentLength, strconv.Itoa(len(header)))
+	} else {
+		req.Header.Set("Content-Type", JSONContentType)
+	}
+
+	respBody, respHeader, err := hc.send(req)
+	if err != nil {
+		return nil, err
+	}
+	headerLength := 0
+	if value := respHeader.Get(InferenceHeaderContentLength); value != "" {
+		headerLength, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header %q: %w", InferenceHeaderContentLength, value, err)
+		}
+	}
+	return decodeJSONInferResponse(respBody, headerLength)
+}
+
+// Infer runs inference with typed inputs and outputs using the given context, opts set the
+// request ID and parameters.
+func (hc *TritonHTTPClientService) Infer(ctx context.Context, modelName, modelVersion string, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error) {
+	modelInferRequest, err := NewModelInferRequest(modelName, modelVersion, inputs, outputs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := hc.ModelInfer(ctx, modelInferRequest)
+	if err != nil {
+		return nil, err
+	}
+	return newInferResultFor(modelInferRequest, modelInferResponse), nil
+}
 
+// Disconnect closes the idle connections to the server.
+func (hc *TritonHTTPClientService) Disconnect() error {
+	hc.httpClient.CloseIdleConnections()
 	return nil
 }
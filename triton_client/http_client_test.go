@@ -0,0 +1,305 @@
+package triton_client
+
+import (
+	"context"
+	"encoding/json"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newHTTPTestClient serves handler over httptest and returns a client connected to it.
+func newHTTPTestClient(t *testing.T, handler http.Handler) *TritonHTTPClientService {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewTritonHTTPClient(server.URL, nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect()
+	})
+	return client
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", JSONContentType)
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestTritonHTTPClientService_Health(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForServerIsLive, func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc(TritonAPIForServerIsReady, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/versions/2/ready", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/ready", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "model 'face' is not ready"})
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	alive, err := client.ServerAliveContext(ctx)
+	assert.NoError(t, err)
+	assert.True(t, alive)
+
+	ready, err := client.ServerReadyContext(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	ready, err = client.ModelReadyContext(ctx, "face", "2")
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	ready, err = client.ModelReadyContext(ctx, "face", "")
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	// other statuses are errors rather than not ready
+	var httpErr *HTTPError
+	_, err = client.ModelReadyContext(ctx, "missing", "")
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	client = newHTTPTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	_, err = client.ServerAliveContext(ctx)
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	_, err = client.ServerReadyContext(ctx)
+	assert.ErrorAs(t, err, &httpErr)
+}
+
+func TestTritonHTTPClientService_Metadata(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForModelPrefix+"face", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name":     "face",
+			"versions": []string{"1"},
+			"platform": "onnxruntime_onnx",
+			"inputs":   []map[string]interface{}{{"name": "data", "datatype": "FP32", "shape": []int64{1, 3, -1, -1}}},
+		})
+	})
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name":           "face",
+			"max_batch_size": 8,
+			"input":          []map[string]interface{}{{"name": "data", "data_type": "TYPE_FP32", "dims": []int64{3, -1, -1}}},
+			"unknown_field":  true,
+		})
+	})
+	mux.HandleFunc(TritonAPIForModelPrefix+"missing/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Request for unknown model: 'missing' is not found"})
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	metadata, err := client.ModelMetadataContext(ctx, "face", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "face", metadata.Name)
+	assert.Equal(t, []int64{1, 3, -1, -1}, metadata.Inputs[0].Shape)
+
+	config, err := client.GetModelConfigurationContext(ctx, "face", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(8), config.Config.MaxBatchSize)
+	assert.Equal(t, grpc_client.DataType_TYPE_FP32, config.Config.Input[0].DataType)
+
+	_, err = client.GetModelConfigurationContext(ctx, "missing", "")
+	httpErr, ok := err.(*HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	assert.Equal(t, "Request for unknown model: 'missing' is not found", httpErr.Message)
+}
+
+func TestTritonHTTPClientService_Repository(t *testing.T) {
+	var loadBody map[string]map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForRepoIndex, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]string{
+			{"name": "face", "version": "1", "state": "READY"},
+			{"name": "arcface", "version": "1", "state": "UNAVAILABLE", "reason": "unloaded"},
+		})
+	})
+	mux.HandleFunc(TritonAPIForRepoModelPrefix+"face/load", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&loadBody)
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	index, err := client.ModelRepositoryIndexContext(ctx, "", false)
+	assert.NoError(t, err)
+	assert.Len(t, index.Models, 2)
+	assert.Equal(t, "unloaded", index.Models[1].Reason)
+
+	err = client.ModelLoadContext(ctx, "", "face", map[string]*grpc_client.ModelRepositoryParameter{
+		"config":            {ParameterChoice: &grpc_client.ModelRepositoryParameter_StringParam{StringParam: `{"max_batch_size": 4}`}},
+		"file:1/model.onnx": {ParameterChoice: &grpc_client.ModelRepositoryParameter_BytesParam{BytesParam: []byte("onnx")}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"max_batch_size": 4}`, loadBody["parameters"]["config"])
+	assert.Equal(t, "b25ueA==", loadBody["parameters"]["file:1/model.onnx"])
+}
+
+func TestTritonHTTPClientService_SharedMemory(t *testing.T) {
+	var registerBody map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForSystemMemory+"/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{
+			{"name": "input0", "key": "/input0", "offset": 0, "byte_size": 64},
+		})
+	})
+	mux.HandleFunc(TritonAPIForSystemMemoryRegionPrefix+"input0/register", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&registerBody)
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := client.ShareSystemMemoryRegisterContext(ctx, "input0", "/input0", 64, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "/input0", registerBody["key"])
+	assert.Equal(t, float64(64), registerBody["byte_size"])
+
+	status, err := client.SystemSharedMemoryStatusContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(64), status.Regions["input0"].ByteSize)
+	assert.Equal(t, "/input0", status.Regions["input0"].Key)
+}
+
+func TestTritonHTTPClientService_Settings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/trace/setting", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"trace_level": []string{"TIMESTAMPS"},
+			"trace_rate":  "1000",
+		})
+	})
+	mux.HandleFunc(TritonAPIForLogging, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"log_info":          true,
+			"log_verbose_level": 1,
+			"log_format":        "default",
+		})
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trace, err := client.GetModelTracingSettingContext(ctx, "face")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TIMESTAMPS"}, trace.Settings["trace_level"].Value)
+	assert.Equal(t, []string{"1000"}, trace.Settings["trace_rate"].Value)
+
+	logSettings, err := client.SetLogSettingsContext(ctx, map[string]*grpc_client.LogSettingsRequest_SettingValue{
+		"log_verbose_level": {ParameterChoice: &grpc_client.LogSettingsRequest_SettingValue_Uint32Param{Uint32Param: 1}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, logSettings.Settings["log_info"].GetBoolParam())
+	assert.Equal(t, uint32(1), logSettings.Settings["log_verbose_level"].GetUint32Param())
+	assert.Equal(t, "default", logSettings.Settings["log_format"].GetStringParam())
+}
+
+func TestTritonHTTPClientService_InferJSON(t *testing.T) {
+	var request map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForModelPrefix+"add/infer", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"model_name":    "add",
+			"model_version": "1",
+			"id":            "req-1",
+			"outputs": []map[string]interface{}{
+				{"name": "sum", "datatype": "FP32", "shape": []int64{2, 2}, "data": [][]float32{{1.5, 2.5}, {3.5, -4}}},
+				{"name": "labels", "datatype": "BYTES", "shape": []int64{2}, "data": []string{"cat", "dog"}},
+				{"name": "index", "datatype": "INT8", "shape": []int64{2}, "data": []int8{-1, 7}},
+			},
+		})
+	})
+	client := newHTTPTestClient(t, mux)
+	client.SetBinaryData(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input := NewInferInput("x", []int64{1, 3}, DataTypeUint8)
+	assert.NoError(t, input.SetData([]uint8{1, 2, 255}))
+	result, err := client.Infer(ctx, "add", "", []*InferInput{input}, []*InferRequestedOutput{NewInferRequestedOutput("sum")})
+	assert.NoError(t, err)
+
+	inputs := request["inputs"].([]interface{})
+	assert.Equal(t, []interface{}{float64(1), float64(2), float64(255)}, inputs[0].(map[string]interface{})["data"])
+	assert.Equal(t, "sum", request["outputs"].([]interface{})[0].(map[string]interface{})["name"])
+
+	assert.Equal(t, "req-1", result.ID())
+	sum, err := result.AsFloat32("sum")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1.5, 2.5, 3.5, -4}, sum)
+	labels, err := result.AsStrings("labels")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cat", "dog"}, labels)
+	index, err := result.AsInt8("index")
+	assert.NoError(t, err)
+	assert.Equal(t, []int8{-1, 7}, index)
+
+	fp16 := NewInferInput("h", []int64{1}, DataTypeFP16)
+	assert.NoError(t, fp16.SetData([]float32{1}))
+	_, err = client.Infer(ctx, "add", "", []*InferInput{fp16}, nil)
+	assert.Error(t, err)
+}
+
+func TestTritonHTTPClientService_InferBinary(t *testing.T) {
+	var request map[string]interface{}
+	var inputData []byte
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/infer", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		headerLength, _ := strconv.Atoi(r.Header.Get(InferenceHeaderContentLength))
+		_ = json.Unmarshal(body[:headerLength], &request)
+		inputData = body[headerLength:]
+
+		scores := NewInferInput("scores", []int64{1, 2}, DataTypeFP16)
+		_ = scores.SetData([]float32{0.5, -2})
+		header, _ := json.Marshal(map[string]interface{}{
+			"model_name":    "face",
+			"model_version": "1",
+			"outputs": []map[string]interface{}{
+				{"name": "scores", "datatype": "FP16", "shape": []int64{1, 2}, "parameters": map[string]int{"binary_data_size": 4}},
+				{"name": "count", "datatype": "INT32", "shape": []int64{1}, "data": []int32{2}},
+			},
+		})
+		w.Header().Set(InferenceHeaderContentLength, strconv.Itoa(len(header)))
+		_, _ = w.Write(append(header, scores.RawData()...))
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data := NewInferInput("data", []int64{1, 2}, DataTypeFP32)
+	assert.NoError(t, data.SetData([]float32{1, 2}))
+	mask := NewInferInput("mask", []int64{1, 2}, DataTypeBool)
+	assert.NoError(t, mask.SetData([]bool{true, false}))
+	result, err := client.Infer(ctx, "face", "", []*InferInput{data, mask}, nil)
+	assert.NoError(t, err)
+
+	inputs := request["inputs"].([]interface{})
+	assert.Equal(t, float64(8), inputs[0].(map[string]interface{})["parameters"].(map[string]interface{})["binary_data_size"])
+	assert.Nil(t, inputs[0].(map[string]interface{})["data"])
+	assert.Equal(t, float64(2), inputs[1].(map[string]interface{})["parameters"].(map[string]interface{})["binary_data_size"])
+	assert.Equal(t, true, request["parameters"].(map[string]interface{})["binary_data_output"])
+	assert.Equal(t, append(append([]byte(nil), data.RawData()...), mask.RawData()...), inputData)
+
+	scores, err := result.AsFloat16("scores")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0.5, -2}, scores)
+	count, err := result.AsInt32("count")
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{2}, count)
+}
@@ -0,0 +1,386 @@
+package triton_client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"math"
+	"strconv"
+)
+
+// jsonInferTensor is an input or output tensor of the KServe v2 JSON inference protocol.
+type jsonInferTensor struct {
+	Name       string                 `json:"name"`
+	Shape      []int64                `json:"shape,omitempty"`
+	Datatype   string                 `json:"datatype,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Data       interface{}            `json:"data,omitempty"`
+}
+
+// jsonInferRequest is the body of a KServe v2 JSON inference request.
+type jsonInferRequest struct {
+	ID         string                 `json:"id,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Inputs     []jsonInferTensor      `json:"inputs"`
+	Outputs    []jsonInferTensor      `json:"outputs,omitempty"`
+}
+
+// jsonInferResponse is the body of a KServe v2 JSON inference response.
+type jsonInferResponse struct {
+	ModelName    string                 `json:"model_name"`
+	ModelVersion string                 `json:"model_version"`
+	ID           string                 `json:"id"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Outputs      []jsonInferTensor      `json:"outputs"`
+}
+
+// Parameters of the binary tensor data extension.
+const (
+	binaryDataSizeParam   = "binary_data_size"
+	binaryDataParam       = "binary_data"
+	binaryDataOutputParam = "binary_data_output"
+)
+
+// newJSONInferRequest converts a ModelInferRequest into its JSON form. With binaryData the
+// input contents are returned separately, in input order, to be appended after the JSON
+// header and all outputs are requested as binary data.
+func newJSONInferRequest(modelInferRequest *grpc_client.ModelInferRequest, binaryData bool) (*jsonInferRequest, []byte, error) {
+	request := &jsonInferRequest{
+		ID:         modelInferRequest.Id,
+		Parameters: inferParametersToJSON(modelInferRequest.Parameters),
+		Inputs:     make([]jsonInferTensor, 0, len(modelInferRequest.Inputs)),
+	}
+	if binaryData && len(modelInferRequest.Outputs) == 0 {
+		request.Parameters = setJSONParameter(request.Parameters, binaryDataOutputParam, true)
+	}
+
+	var payload []byte
+	rawIdx := 0
+	for _, input := range modelInferRequest.Inputs {
+		tensor := jsonInferTensor{
+			Name:       input.Name,
+			Shape:      input.Shape,
+			Datatype:   input.Datatype,
+			Parameters: inferParametersToJSON(input.Parameters),
+		}
+		// inputs in shared memory carry no data, raw contents are only given for the others
+		if usesSharedMemory(input.Parameters) {
+			request.Inputs = append(request.Inputs, tensor)
+			continue
+		}
+
+		var raw []byte
+		var err error
+		if rawIdx < len(modelInferRequest.RawInputContents) {
+			raw = modelInferRequest.RawInputContents[rawIdx]
+			rawIdx++
+		} else if input.Contents != nil {
+			raw, err = contentsToRaw(input.Datatype, input.Contents)
+			if err != nil {
+				return nil, nil, fmt.Errorf("input %s: %w", input.Name, err)
+			}
+		}
+
+		if binaryData {
+			tensor.Parameters = setJSONParameter(tensor.Parameters, binaryDataSizeParam, len(raw))
+			payload = append(payload, raw...)
+		} else {
+			tensor.Data, err = rawToJSONData(input.Datatype, raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("input %s: %w", input.Name, err)
+			}
+		}
+		request.Inputs = append(request.Inputs, tensor)
+	}
+
+	// a binary_data_output request parameter applies to every output that does not set its own
+	binaryOutput, ok := modelInferRequest.Parameters[binaryDataOutputParam].GetParameterChoice().(*grpc_client.InferParameter_BoolParam)
+	for _, output := range modelInferRequest.Outputs {
+		tensor := jsonInferTensor{
+			Name:       output.Name,
+			Parameters: inferParametersToJSON(output.Parameters),
+		}
+		if usesSharedMemory(output.Parameters) {
+			// the output is written to shared memory, it is never part of the response
+		} else if ok {
+			tensor.Parameters = setJSONParameter(tensor.Parameters, binaryDataParam, binaryOutput.BoolParam)
+		} else if binaryData {
+			tensor.Parameters = setJSONParameter(tensor.Parameters, binaryDataParam, true)
+		}
+		request.Outputs = append(request.Outputs, tensor)
+	}
+	return request, payload, nil
+}
+
+// setJSONParameter sets a parameter unless the caller already set it, allocating the map
+// when needed.
+func setJSONParameter(parameters map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if parameters == nil {
+		parameters = make(map[string]interface{})
+	}
+	if _, ok := parameters[key]; !ok {
+		parameters[key] = value
+	}
+	return parameters
+}
+
+// decodeJSONInferResponse converts an inference response into a ModelInferResponse with the
+// output data as raw contents. headerLength is the value of the Inference-Header-Content-Length
+// header, the binary output data follows the JSON header in output order.
+func decodeJSONInferResponse(respBody []byte, headerLength int) (*grpc_client.ModelInferResponse, error) {
+	var payload []byte
+	if headerLength > 0 {
+		if headerLength > len(respBody) {
+			return nil, fmt.Errorf("inference header length %d exceeds the response size %d", headerLength, len(respBody))
+		}
+		respBody, payload = respBody[:headerLength], respBody[headerLength:]
+	}
+
+	var response jsonInferResponse
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	err := decoder.Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters, err := inferParametersFromJSON(response.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse := &grpc_client.ModelInferResponse{
+		ModelName:    response.ModelName,
+		ModelVersion: response.ModelVersion,
+		Id:           response.ID,
+		Parameters:   parameters,
+	}
+
+	for _, output := range response.Outputs {
+		outputParameters, err := inferParametersFromJSON(output.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", output.Name, err)
+		}
+		var raw []byte
+		if size, ok := outputParameters[binaryDataSizeParam]; ok {
+			byteSize := int(size.GetInt64Param())
+			if byteSize < 0 || byteSize > len(payload) {
+				return nil, fmt.Errorf("output %s: binary data size %d exceeds the %d bytes left", output.Name, byteSize, len(payload))
+			}
+			raw, payload = payload[:byteSize], payload[byteSize:]
+			delete(outputParameters, binaryDataSizeParam)
+		} else if usesSharedMemory(outputParameters) {
+			// the output was written to shared memory
+		} else {
+			raw, err = jsonDataToRaw(output.Datatype, output.Data)
+			if err != nil {
+				return nil, fmt.Errorf("output %s: %w", output.Name, err)
+			}
+		}
+		modelInferResponse.Outputs = append(modelInferResponse.Outputs, &grpc_client.ModelInferResponse_InferOutputTensor{
+			Name:       output.Name,
+			Datatype:   output.Datatype,
+			Shape:      output.Shape,
+			Parameters: outputParameters,
+		})
+		modelInferResponse.RawOutputContents = append(modelInferResponse.RawOutputContents, raw)
+	}
+	return modelInferResponse, nil
+}
+
+// inferParametersToJSON converts inference parameters to their JSON values.
+func inferParametersToJSON(parameters map[string]*grpc_client.InferParameter) map[string]interface{} {
+	if len(parameters) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(parameters))
+	for key, param := range parameters {
+		switch choice := param.GetParameterChoice().(type) {
+		case *grpc_client.InferParameter_BoolParam:
+			values[key] = choice.BoolParam
+		case *grpc_client.InferParameter_Int64Param:
+			values[key] = choice.Int64Param
+		case *grpc_client.InferParameter_StringParam:
+			values[key] = choice.StringParam
+		case *grpc_client.InferParameter_DoubleParam:
+			values[key] = choice.DoubleParam
+		case *grpc_client.InferParameter_Uint64Param:
+			values[key] = choice.Uint64Param
+		}
+	}
+	return values
+}
+
+// inferParametersFromJSON converts JSON parameters decoded with UseNumber into inference
+// parameters. Integral numbers become int64 parameters, other numbers double parameters.
+func inferParametersFromJSON(values map[string]interface{}) (map[string]*grpc_client.InferParameter, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	parameters := make(map[string]*grpc_client.InferParameter, len(values))
+	for key, value := range values {
+		param := &grpc_client.InferParameter{}
+		switch v := value.(type) {
+		case bool:
+			param.ParameterChoice = &grpc_client.InferParameter_BoolParam{BoolParam: v}
+		case string:
+			param.ParameterChoice = &grpc_client.InferParameter_StringParam{StringParam: v}
+		case json.Number:
+			if number, err := v.Int64(); err == nil {
+				param.ParameterChoice = &grpc_client.InferParameter_Int64Param{Int64Param: number}
+			} else if number, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				param.ParameterChoice = &grpc_client.InferParameter_Uint64Param{Uint64Param: number}
+			} else if number, err := v.Float64(); err == nil {
+				param.ParameterChoice = &grpc_client.InferParameter_DoubleParam{DoubleParam: number}
+			} else {
+				return nil, fmt.Errorf("parameter %s: %w", key, err)
+			}
+		default:
+			return nil, fmt.Errorf("parameter %s has unsupported value %v", key, value)
+		}
+		parameters[key] = param
+	}
+	return parameters, nil
+}
+
+// rawToJSONData decodes little-endian raw contents into a flat slice that encodes as the
+// JSON data of a tensor.
+func rawToJSONData(datatype string, raw []byte) (interface{}, error) {
+	if datatype == DataTypeBytes {
+		elements, err := decodeBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+		strs := make([]string, len(elements))
+		for idx, element := range elements {
+			strs[idx] = string(element)
+		}
+		return strs, nil
+	}
+
+	size := dataTypeByteSize(datatype)
+	if size == 0 {
+		return nil, fmt.Errorf("unsupported datatype %s", datatype)
+	}
+	if len(raw)%size != 0 {
+		return nil, fmt.Errorf("got %d bytes, not a multiple of the %s element size %d", len(raw), datatype, size)
+	}
+
+	switch datatype {
+	case DataTypeBool:
+		return decodeFixed(raw, 1, func(b []byte) bool { return b[0] != 0 }), nil
+	case DataTypeUint8:
+		// a []uint8 would be encoded as a base64 string
+		return decodeFixed(raw, 1, func(b []byte) uint16 { return uint16(b[0]) }), nil
+	case DataTypeUint16:
+		return decodeFixed(raw, 2, binary.LittleEndian.Uint16), nil
+	case DataTypeUint32:
+		return decodeFixed(raw, 4, binary.LittleEndian.Uint32), nil
+	case DataTypeUint64:
+		return decodeFixed(raw, 8, binary.LittleEndian.Uint64), nil
+	case DataTypeInt8:
+		return decodeFixed(raw, 1, func(b []byte) int8 { return int8(b[0]) }), nil
+	case DataTypeInt16:
+		return decodeFixed(raw, 2, func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }), nil
+	case DataTypeInt32:
+		return decodeFixed(raw, 4, func(b []byte) int32 { return int32(binary.LittleEndian.Uint32(b)) }), nil
+	case DataTypeInt64:
+		return decodeFixed(raw, 8, func(b []byte) int64 { return int64(binary.LittleEndian.Uint64(b)) }), nil
+	case DataTypeFP32:
+		return decodeFixed(raw, 4, func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }), nil
+	case DataTypeFP64:
+		return decodeFixed(raw, 8, func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }), nil
+	}
+	return nil, fmt.Errorf("datatype %s cannot be sent as JSON data", datatype)
+}
+
+// jsonDataToRaw serializes the JSON data of a tensor, decoded with UseNumber, into
+// little-endian raw contents. Nested arrays are flattened in row-major order.
+func jsonDataToRaw(datatype string, data interface{}) ([]byte, error) {
+	elements := flattenJSONData(data, nil)
+
+	switch datatype {
+	case DataTypeBool:
+		raw := make([]byte, len(elements))
+		for idx, element := range elements {
+			val, ok := element.(bool)
+			if !ok {
+				return nil, fmt.Errorf("element %d: expected a bool, got %v", idx, element)
+			}
+			if val {
+				raw[idx] = 1
+			}
+		}
+		return raw, nil
+	case DataTypeBytes:
+		values := make([][]byte, len(elements))
+		for idx, element := range elements {
+			val, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("element %d: expected a string, got %v", idx, element)
+			}
+			values[idx] = []byte(val)
+		}
+		return encodeBytes(values), nil
+	case DataTypeFP32, DataTypeFP64:
+		values := make([]float64, len(elements))
+		for idx, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("element %d: expected a number, got %v", idx, element)
+			}
+			val, err := number.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", idx, err)
+			}
+			values[idx] = val
+		}
+		if datatype == DataTypeFP32 {
+			return encodeFixed(values, 4, func(b []byte, val float64) { binary.LittleEndian.PutUint32(b, math.Float32bits(float32(val))) }), nil
+		}
+		return encodeFixed(values, 8, func(b []byte, val float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(val)) }), nil
+	case DataTypeUint8, DataTypeUint16, DataTypeUint32, DataTypeUint64,
+		DataTypeInt8, DataTypeInt16, DataTypeInt32, DataTypeInt64:
+		signed := datatype[0] == 'I'
+		values := make([]uint64, len(elements))
+		for idx, element := range elements {
+			number, ok := element.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("element %d: expected a number, got %v", idx, element)
+			}
+			var err error
+			if signed {
+				var val int64
+				val, err = strconv.ParseInt(number.String(), 10, 64)
+				values[idx] = uint64(val)
+			} else {
+				values[idx], err = strconv.ParseUint(number.String(), 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", idx, err)
+			}
+		}
+		// two's complement truncation keeps the low bytes of signed values correct
+		return encodeFixed(values, dataTypeByteSize(datatype), func(b []byte, val uint64) {
+			for i := range b {
+				b[i] = byte(val >> (8 * i))
+			}
+		}), nil
+	}
+	return nil, fmt.Errorf("datatype %s cannot be received as JSON data", datatype)
+}
+
+// flattenJSONData appends the leaves of possibly nested JSON arrays to flat.
+func flattenJSONData(data interface{}, flat []interface{}) []interface{} {
+	if data == nil {
+		return flat
+	}
+	if elements, ok := data.([]interface{}); ok {
+		for _, element := range elements {
+			flat = flattenJSONData(element, flat)
+		}
+		return flat
+	}
+	return append(flat, data)
+}
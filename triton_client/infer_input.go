@@ -0,0 +1,334 @@
+package triton_client
+
+import (
+	"encoding/binary"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+	"math"
+)
+
+// Triton protocol datatype strings.
+const (
+	DataTypeBool   = "BOOL"
+	DataTypeUint8  = "UINT8"
+	DataTypeUint16 = "UINT16"
+	DataTypeUint32 = "UINT32"
+	DataTypeUint64 = "UINT64"
+	DataTypeInt8   = "INT8"
+	DataTypeInt16  = "INT16"
+	DataTypeInt32  = "INT32"
+	DataTypeInt64  = "INT64"
+	DataTypeFP16   = "FP16"
+	DataTypeFP32   = "FP32"
+	DataTypeFP64   = "FP64"
+	DataTypeBytes  = "BYTES"
+	DataTypeBF16   = "BF16"
+)
+
+// dataTypeByteSize returns the size of one element of a protocol datatype, or 0 for
+// BYTES and unknown datatypes.
+func dataTypeByteSize(datatype string) int {
+	return int(model_config.GetDataTypeByteSize(model_config.ProtocolStringToDataType(datatype)))
+}
+
+// InferInput describes an input tensor of an inference request and holds its serialized data.
+type InferInput struct {
+	name       string
+	shape      []int64
+	datatype   string
+	raw        []byte
+	parameters map[string]*grpc_client.InferParameter
+}
+
+// NewInferInput creates an input with the given name, shape and Triton datatype, e.g. "FP32".
+func NewInferInput(name string, shape []int64, datatype string) *InferInput {
+	return &InferInput{
+		name:     name,
+		shape:    append([]int64(nil), shape...),
+		datatype: datatype,
+	}
+}
+
+// Name returns the name of the input.
+func (i *InferInput) Name() string {
+	return i.name
+}
+
+// Datatype returns the Triton datatype of the input.
+func (i *InferInput) Datatype() string {
+	return i.datatype
+}
+
+// Shape returns the shape of the input.
+func (i *InferInput) Shape() []int64 {
+	return i.shape
+}
+
+// SetShape changes the shape of the input. Data that was already set is kept as is.
+func (i *InferInput) SetShape(shape []int64) {
+	i.shape = append([]int64(nil), shape...)
+}
+
+// RawData returns the serialized little-endian contents of the input.
+func (i *InferInput) RawData() []byte {
+	return i.raw
+}
+
+// elementCount returns the number of elements described by the shape.
+func (i *InferInput) elementCount() (int, error) {
+	count, err := shapeElementCount(i.shape)
+	if err != nil {
+		return 0, fmt.Errorf("input %s: %w", i.name, err)
+	}
+	return count, nil
+}
+
+// SetData serializes data into the input. The element type of data must match the datatype:
+// []bool, []uint8..[]uint64, []int8..[]int64, []float32, []float64 for the matching fixed size
+// types, []float32 or raw []uint16 bits for FP16 and BF16, and [][]byte or []string for BYTES.
+// The number of elements must match the shape.
+func (i *InferInput) SetData(data interface{}) error {
+	var raw []byte
+	var count int
+	mismatch := fmt.Errorf("input %s: cannot set %T data for datatype %s", i.name, data, i.datatype)
+
+	switch v := data.(type) {
+	case []bool:
+		if i.datatype != DataTypeBool {
+			return mismatch
+		}
+		raw = encodeFixed(v, 1, func(b []byte, val bool) {
+			if val {
+				b[0] = 1
+			}
+		})
+		count = len(v)
+	case []uint8:
+		if i.datatype != DataTypeUint8 {
+			return mismatch
+		}
+		raw = append([]byte(nil), v...)
+		count = len(v)
+	case []uint16:
+		if i.datatype != DataTypeUint16 && i.datatype != DataTypeFP16 && i.datatype != DataTypeBF16 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 2, binary.LittleEndian.PutUint16)
+		count = len(v)
+	case []uint32:
+		if i.datatype != DataTypeUint32 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 4, binary.LittleEndian.PutUint32)
+		count = len(v)
+	case []uint64:
+		if i.datatype != DataTypeUint64 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 8, binary.LittleEndian.PutUint64)
+		count = len(v)
+	case []int8:
+		if i.datatype != DataTypeInt8 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 1, func(b []byte, val int8) { b[0] = byte(val) })
+		count = len(v)
+	case []int16:
+		if i.datatype != DataTypeInt16 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 2, func(b []byte, val int16) { binary.LittleEndian.PutUint16(b, uint16(val)) })
+		count = len(v)
+	case []int32:
+		if i.datatype != DataTypeInt32 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 4, func(b []byte, val int32) { binary.LittleEndian.PutUint32(b, uint32(val)) })
+		count = len(v)
+	case []int64:
+		if i.datatype != DataTypeInt64 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 8, func(b []byte, val int64) { binary.LittleEndian.PutUint64(b, uint64(val)) })
+		count = len(v)
+	case []float32:
+		switch i.datatype {
+		case DataTypeFP32:
+			raw = encodeFixed(v, 4, func(b []byte, val float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(val)) })
+		case DataTypeFP16:
+			raw = encodeFixed(v, 2, func(b []byte, val float32) { binary.LittleEndian.PutUint16(b, Float32ToFloat16(val)) })
+		case DataTypeBF16:
+			raw = encodeFixed(v, 2, func(b []byte, val float32) { binary.LittleEndian.PutUint16(b, Float32ToBFloat16(val)) })
+		default:
+			return mismatch
+		}
+		count = len(v)
+	case []float64:
+		if i.datatype != DataTypeFP64 {
+			return mismatch
+		}
+		raw = encodeFixed(v, 8, func(b []byte, val float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(val)) })
+		count = len(v)
+	case [][]byte:
+		if i.datatype != DataTypeBytes {
+			return mismatch
+		}
+		raw = encodeBytes(v)
+		count = len(v)
+	case []string:
+		if i.datatype != DataTypeBytes {
+			return mismatch
+		}
+		elements := make([][]byte, len(v))
+		for idx, str := range v {
+			elements[idx] = []byte(str)
+		}
+		raw = encodeBytes(elements)
+		count = len(v)
+	default:
+		return fmt.Errorf("input %s: unsupported data type %T", i.name, data)
+	}
+
+	expected, err := i.elementCount()
+	if err != nil {
+		return err
+	}
+	if count != expected {
+		return fmt.Errorf("input %s: got %d elements, shape %v requires %d", i.name, count, i.shape, expected)
+	}
+
+	i.setRaw(raw)
+	return nil
+}
+
+// SetRawData sets already serialized little-endian contents. For fixed size datatypes the
+// byte size must match the shape.
+func (i *InferInput) SetRawData(raw []byte) error {
+	if size := dataTypeByteSize(i.datatype); size > 0 {
+		expected, err := i.elementCount()
+		if err != nil {
+			return err
+		}
+		if len(raw) != expected*size {
+			return fmt.Errorf("input %s: got %d bytes, shape %v of %s requires %d", i.name, len(raw), i.shape, i.datatype, expected*size)
+		}
+	}
+	i.setRaw(raw)
+	return nil
+}
+
+// setRaw sets the contents of the input, which is no longer read from shared memory.
+func (i *InferInput) setRaw(raw []byte) {
+	i.raw = raw
+	delete(i.parameters, sharedMemoryRegionParam)
+	delete(i.parameters, sharedMemoryByteSizeParam)
+	delete(i.parameters, sharedMemoryOffsetParam)
+}
+
+// Tensor returns the tensor metadata for a ModelInferRequest.
+func (i *InferInput) Tensor() *grpc_client.ModelInferRequest_InferInputTensor {
+	return &grpc_client.ModelInferRequest_InferInputTensor{
+		Name:       i.name,
+		Datatype:   i.datatype,
+		Shape:      i.shape,
+		Parameters: copyInferParameters(i.parameters),
+	}
+}
+
+// InferRequestedOutput describes an output requested from an inference request.
+type InferRequestedOutput struct {
+	name       string
+	parameters map[string]*grpc_client.InferParameter
+}
+
+// NewInferRequestedOutput creates a requested output with the given name.
+func NewInferRequestedOutput(name string) *InferRequestedOutput {
+	return &InferRequestedOutput{name: name}
+}
+
+// Name returns the name of the output.
+func (o *InferRequestedOutput) Name() string {
+	return o.name
+}
+
+// SetClassCount requests the top count classifications of the output instead of the output
+// tensor. Each classification is returned as a "value:index[:label]" BYTES element.
+func (o *InferRequestedOutput) SetClassCount(count int) {
+	o.parameters = setInferParameter(o.parameters, classificationParam, int64Parameter(int64(count)))
+}
+
+// SetBinaryData requests the output as binary data, or as JSON data when false, with the HTTP
+// client. The gRPC protocol always returns raw data and ignores it.
+func (o *InferRequestedOutput) SetBinaryData(binaryData bool) {
+	o.parameters = setInferParameter(o.parameters, binaryDataParam, boolParameter(binaryData))
+}
+
+// SetParameter sets a custom parameter of the output. The value must be a bool, an integer,
+// a float64 or a string.
+func (o *InferRequestedOutput) SetParameter(key string, value interface{}) error {
+	param, err := newInferParameter(value)
+	if err != nil {
+		return fmt.Errorf("output %s: parameter %s: %w", o.name, key, err)
+	}
+	o.parameters = setInferParameter(o.parameters, key, param)
+	return nil
+}
+
+// Tensor returns the tensor metadata for a ModelInferRequest.
+func (o *InferRequestedOutput) Tensor() *grpc_client.ModelInferRequest_InferRequestedOutputTensor {
+	return &grpc_client.ModelInferRequest_InferRequestedOutputTensor{
+		Name:       o.name,
+		Parameters: copyInferParameters(o.parameters),
+	}
+}
+
+// NewModelInferRequest builds an inference request for the given model from typed inputs and
+// outputs, then applies opts. Every input must have its data set or be placed in shared
+// memory. When no outputs are given Triton returns all of them.
+func NewModelInferRequest(modelName, modelVersion string, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*grpc_client.ModelInferRequest, error) {
+	modelInferRequest := &grpc_client.ModelInferRequest{
+		ModelName:    modelName,
+		ModelVersion: modelVersion,
+	}
+	for _, input := range inputs {
+		modelInferRequest.Inputs = append(modelInferRequest.Inputs, input.Tensor())
+		// raw contents are only given for the inputs that are not in shared memory
+		if usesSharedMemory(input.parameters) {
+			continue
+		}
+		if input.raw == nil {
+			return nil, fmt.Errorf("input %s: data is not set", input.name)
+		}
+		modelInferRequest.RawInputContents = append(modelInferRequest.RawInputContents, input.raw)
+	}
+	for _, output := range outputs {
+		modelInferRequest.Outputs = append(modelInferRequest.Outputs, output.Tensor())
+	}
+	if err := applyInferOptions(modelInferRequest, opts); err != nil {
+		return nil, err
+	}
+	return modelInferRequest, nil
+}
+
+func encodeFixed[T any](data []T, size int, put func([]byte, T)) []byte {
+	raw := make([]byte, len(data)*size)
+	for idx, val := range data {
+		put(raw[idx*size:(idx+1)*size], val)
+	}
+	return raw
+}
+
+// encodeBytes serializes BYTES elements, each prefixed by its 4-byte little-endian length.
+func encodeBytes(elements [][]byte) []byte {
+	size := 0
+	for _, element := range elements {
+		size += 4 + len(element)
+	}
+	raw := make([]byte, 0, size)
+	for _, element := range elements {
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(len(element)))
+		raw = append(raw, element...)
+	}
+	return raw
+}
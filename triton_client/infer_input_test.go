@@ -0,0 +1,111 @@
+package triton_client
+
+import (
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInferInput_SetData(t *testing.T) {
+	tests := []struct {
+		name     string
+		datatype string
+		shape    []int64
+		data     interface{}
+		raw      []byte
+	}{
+		{"bool", DataTypeBool, []int64{2}, []bool{true, false}, []byte{1, 0}},
+		{"uint8", DataTypeUint8, []int64{2}, []uint8{1, 255}, []byte{1, 255}},
+		{"uint16", DataTypeUint16, []int64{1}, []uint16{0x0102}, []byte{2, 1}},
+		{"uint32", DataTypeUint32, []int64{1}, []uint32{0x01020304}, []byte{4, 3, 2, 1}},
+		{"uint64", DataTypeUint64, []int64{1}, []uint64{1}, []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{"int8", DataTypeInt8, []int64{2}, []int8{-1, 1}, []byte{0xff, 1}},
+		{"int16", DataTypeInt16, []int64{1}, []int16{-2}, []byte{0xfe, 0xff}},
+		{"int32", DataTypeInt32, []int64{1}, []int32{-1}, []byte{0xff, 0xff, 0xff, 0xff}},
+		{"int64", DataTypeInt64, []int64{1}, []int64{2}, []byte{2, 0, 0, 0, 0, 0, 0, 0}},
+		{"fp32", DataTypeFP32, []int64{1, 1}, []float32{1}, []byte{0, 0, 0x80, 0x3f}},
+		{"fp16", DataTypeFP16, []int64{1}, []float32{1}, []byte{0, 0x3c}},
+		{"fp16 bits", DataTypeFP16, []int64{1}, []uint16{0x3c00}, []byte{0, 0x3c}},
+		{"bf16", DataTypeBF16, []int64{1}, []float32{1}, []byte{0x80, 0x3f}},
+		{"fp64", DataTypeFP64, []int64{1}, []float64{1}, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		{"bytes", DataTypeBytes, []int64{2}, [][]byte{{'a'}, {}}, []byte{1, 0, 0, 0, 'a', 0, 0, 0, 0}},
+		{"string", DataTypeBytes, []int64{1, 1}, []string{"ab"}, []byte{2, 0, 0, 0, 'a', 'b'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := NewInferInput("INPUT0", tt.shape, tt.datatype)
+			assert.NoError(t, input.SetData(tt.data))
+			assert.Equal(t, tt.raw, input.RawData())
+		})
+	}
+}
+
+func TestInferInput_SetDataErrors(t *testing.T) {
+	input := NewInferInput("INPUT0", []int64{2, 2}, DataTypeFP32)
+	assert.EqualError(t, input.SetData([]float32{1, 2, 3}), "input INPUT0: got 3 elements, shape [2 2] requires 4")
+	assert.EqualError(t, input.SetData([]int32{1, 2, 3, 4}), "input INPUT0: cannot set []int32 data for datatype FP32")
+	assert.EqualError(t, input.SetData(1.0), "input INPUT0: unsupported data type float64")
+	assert.Nil(t, input.RawData())
+
+	input = NewInferInput("INPUT0", []int64{-1, 2}, DataTypeFP32)
+	assert.Error(t, input.SetData([]float32{1, 2}))
+
+	input = NewInferInput("INPUT0", []int64{1, 2}, DataTypeFP32)
+	assert.EqualError(t, input.SetRawData(make([]byte, 4)), "input INPUT0: got 4 bytes, shape [1 2] of FP32 requires 8")
+	assert.NoError(t, input.SetRawData(make([]byte, 8)))
+}
+
+func TestNewModelInferRequest(t *testing.T) {
+	input := NewInferInput("INPUT0", []int64{1, 2}, DataTypeInt32)
+	_, err := NewModelInferRequest("simple", "1", []*InferInput{input}, nil)
+	assert.EqualError(t, err, "input INPUT0: data is not set")
+
+	assert.NoError(t, input.SetData([]int32{1, 2}))
+	req, err := NewModelInferRequest("simple", "1", []*InferInput{input}, []*InferRequestedOutput{NewInferRequestedOutput("OUTPUT0")})
+	assert.NoError(t, err)
+	assert.Equal(t, "simple", req.ModelName)
+	assert.Equal(t, "1", req.ModelVersion)
+	assert.Equal(t, "INT32", req.Inputs[0].Datatype)
+	assert.Equal(t, []int64{1, 2}, req.Inputs[0].Shape)
+	assert.Equal(t, [][]byte{{1, 0, 0, 0, 2, 0, 0, 0}}, req.RawInputContents)
+	assert.Equal(t, "OUTPUT0", req.Outputs[0].Name)
+}
+
+func TestNewModelInferRequest_SharedMemory(t *testing.T) {
+	image := NewInferInput("image", []int64{1, 2}, DataTypeFP32)
+	assert.NoError(t, image.SetData([]float32{1, 2}))
+	image.SetSharedMemory("input_data", 8, 16)
+	scale := NewInferInput("scale", []int64{1, 1}, DataTypeFP32)
+	assert.NoError(t, scale.SetData([]float32{0.5}))
+	boxes := NewInferRequestedOutput("boxes")
+	boxes.SetSharedMemory("output_data", 64, 0)
+
+	req, err := NewModelInferRequest("face", "", []*InferInput{image, scale}, []*InferRequestedOutput{boxes})
+	assert.NoError(t, err)
+	assert.Nil(t, image.RawData())
+	assert.Equal(t, [][]byte{scale.RawData()}, req.RawInputContents)
+	assert.Equal(t, "input_data", req.Inputs[0].Parameters[sharedMemoryRegionParam].GetStringParam())
+	assert.Equal(t, int64(8), req.Inputs[0].Parameters[sharedMemoryByteSizeParam].GetInt64Param())
+	assert.Equal(t, int64(16), req.Inputs[0].Parameters[sharedMemoryOffsetParam].GetInt64Param())
+	assert.Nil(t, req.Inputs[1].Parameters)
+	assert.Equal(t, "output_data", req.Outputs[0].Parameters[sharedMemoryRegionParam].GetStringParam())
+
+	// the raw contents of the other inputs are still checked
+	config := &grpc_client.ModelConfig{
+		Name:   "face",
+		Input:  []*grpc_client.ModelInput{{Name: "image", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 2}}, {Name: "scale", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 1}}},
+		Output: []*grpc_client.ModelOutput{{Name: "boxes", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1, 16}}},
+	}
+	assert.NoError(t, ValidateInferRequest(config, req))
+
+	jsonRequest, payload, err := newJSONInferRequest(req, true)
+	assert.NoError(t, err)
+	assert.Equal(t, scale.RawData(), payload)
+	assert.Nil(t, jsonRequest.Inputs[0].Parameters[binaryDataSizeParam])
+	assert.Equal(t, 4, jsonRequest.Inputs[1].Parameters[binaryDataSizeParam])
+	assert.Nil(t, jsonRequest.Outputs[0].Parameters[binaryDataParam])
+
+	// setting data again takes the input out of shared memory
+	assert.NoError(t, image.SetData([]float32{3, 4}))
+	assert.Nil(t, image.Tensor().Parameters)
+}
@@ -0,0 +1,139 @@
+package triton_client
+
+import (
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"time"
+)
+
+// Request and output parameters understood by Triton.
+const (
+	priorityParam       = "priority"
+	timeoutParam        = "timeout"
+	classificationParam = "classification"
+)
+
+// InferOption sets the ID or a parameter of an inference request.
+type InferOption func(modelInferRequest *grpc_client.ModelInferRequest) error
+
+// WithRequestID sets the request ID, Triton echoes it on the response and InferResult.ID
+// returns it.
+func WithRequestID(id string) InferOption {
+	return func(modelInferRequest *grpc_client.ModelInferRequest) error {
+		modelInferRequest.Id = id
+		return nil
+	}
+}
+
+// WithPriority sets the priority of the request for models with priority levels in their
+// dynamic batcher, 1 is the highest priority. 0 uses the default priority of the model.
+func WithPriority(priority uint64) InferOption {
+	return WithParameter(priorityParam, priority)
+}
+
+// WithServerTimeout sets how long the request may wait in the scheduler queue of the server
+// before it is rejected. It is sent in microseconds and only applies to models whose
+// scheduler supports request timeouts.
+func WithServerTimeout(timeout time.Duration) InferOption {
+	return WithParameter(timeoutParam, timeout.Microseconds())
+}
+
+// WithBinaryDataOutput requests every output as binary data, or as JSON data when false, with
+// the HTTP client. The gRPC protocol always returns raw data and ignores it.
+func WithBinaryDataOutput(binaryData bool) InferOption {
+	return WithParameter(binaryDataOutputParam, binaryData)
+}
+
+// WithClassCount requests the top count classifications of every requested output instead of
+// the output tensors, see InferRequestedOutput.SetClassCount.
+func WithClassCount(count int) InferOption {
+	return func(modelInferRequest *grpc_client.ModelInferRequest) error {
+		if len(modelInferRequest.Outputs) == 0 {
+			return errors.New("class count requires the outputs to be requested")
+		}
+		for _, output := range modelInferRequest.Outputs {
+			output.Parameters = setInferParameter(output.Parameters, classificationParam, int64Parameter(int64(count)))
+		}
+		return nil
+	}
+}
+
+// WithParameter sets a custom request parameter, passed to the model. The value must be a bool,
+// an integer, a float64 or a string.
+func WithParameter(key string, value interface{}) InferOption {
+	return func(modelInferRequest *grpc_client.ModelInferRequest) error {
+		param, err := newInferParameter(value)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", key, err)
+		}
+		modelInferRequest.Parameters = setInferParameter(modelInferRequest.Parameters, key, param)
+		return nil
+	}
+}
+
+// applyInferOptions applies opts to the request in order.
+func applyInferOptions(modelInferRequest *grpc_client.ModelInferRequest, opts []InferOption) error {
+	for _, opt := range opts {
+		if err := opt(modelInferRequest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newInferParameter converts a Go value into an inference parameter.
+func newInferParameter(value interface{}) (*grpc_client.InferParameter, error) {
+	switch v := value.(type) {
+	case bool:
+		return boolParameter(v), nil
+	case int:
+		return int64Parameter(int64(v)), nil
+	case int32:
+		return int64Parameter(int64(v)), nil
+	case int64:
+		return int64Parameter(v), nil
+	case uint:
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Uint64Param{Uint64Param: uint64(v)}}, nil
+	case uint32:
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Uint64Param{Uint64Param: uint64(v)}}, nil
+	case uint64:
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Uint64Param{Uint64Param: v}}, nil
+	case float64:
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_DoubleParam{DoubleParam: v}}, nil
+	case string:
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_StringParam{StringParam: v}}, nil
+	case *grpc_client.InferParameter:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported parameter type %T", value)
+}
+
+func int64Parameter(value int64) *grpc_client.InferParameter {
+	return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Int64Param{Int64Param: value}}
+}
+
+func boolParameter(value bool) *grpc_client.InferParameter {
+	return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_BoolParam{BoolParam: value}}
+}
+
+// setInferParameter sets a parameter, allocating the map when needed.
+func setInferParameter(parameters map[string]*grpc_client.InferParameter, key string, param *grpc_client.InferParameter) map[string]*grpc_client.InferParameter {
+	if parameters == nil {
+		parameters = make(map[string]*grpc_client.InferParameter)
+	}
+	parameters[key] = param
+	return parameters
+}
+
+// copyInferParameters returns a copy of the parameter map, nil when it is empty.
+func copyInferParameters(parameters map[string]*grpc_client.InferParameter) map[string]*grpc_client.InferParameter {
+	if len(parameters) == 0 {
+		return nil
+	}
+	copied := make(map[string]*grpc_client.InferParameter, len(parameters))
+	for key, param := range parameters {
+		copied[key] = param
+	}
+	return copied
+}
@@ -0,0 +1,112 @@
+package triton_client
+
+import (
+	"context"
+	"encoding/json"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewModelInferRequest_Options(t *testing.T) {
+	input := NewInferInput("x", []int64{1, 2}, DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{1, 2}))
+	scores := NewInferRequestedOutput("scores")
+	scores.SetBinaryData(false)
+	assert.NoError(t, scores.SetParameter("threshold", 0.5))
+	assert.Error(t, scores.SetParameter("threshold", []float32{0.5}))
+
+	req, err := NewModelInferRequest("face", "", []*InferInput{input}, []*InferRequestedOutput{scores, NewInferRequestedOutput("labels")},
+		WithRequestID("frame-7"),
+		WithPriority(1),
+		WithServerTimeout(1500*time.Millisecond),
+		WithBinaryDataOutput(true),
+		WithClassCount(3),
+		WithParameter("camera", "lobby"),
+		WithParameter("scale", 2),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "frame-7", req.Id)
+	assert.Equal(t, uint64(1), req.Parameters[priorityParam].GetUint64Param())
+	assert.Equal(t, int64(1500000), req.Parameters[timeoutParam].GetInt64Param())
+	assert.True(t, req.Parameters[binaryDataOutputParam].GetBoolParam())
+	assert.Equal(t, "lobby", req.Parameters["camera"].GetStringParam())
+	assert.Equal(t, int64(2), req.Parameters["scale"].GetInt64Param())
+
+	assert.Equal(t, int64(3), req.Outputs[0].Parameters[classificationParam].GetInt64Param())
+	assert.Equal(t, 0.5, req.Outputs[0].Parameters["threshold"].GetDoubleParam())
+	assert.Equal(t, &grpc_client.InferParameter_BoolParam{BoolParam: false}, req.Outputs[0].Parameters[binaryDataParam].GetParameterChoice())
+	assert.Equal(t, int64(3), req.Outputs[1].Parameters[classificationParam].GetInt64Param())
+
+	_, err = NewModelInferRequest("face", "", []*InferInput{input}, nil, WithClassCount(3))
+	assert.Error(t, err)
+	_, err = NewModelInferRequest("face", "", []*InferInput{input}, nil, WithParameter("size", struct{}{}))
+	assert.EqualError(t, err, "parameter size: unsupported parameter type struct {}")
+}
+
+func TestTritonGRPCClient_InferOptions(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "face"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	ctx := context.Background()
+
+	input := NewInferInput("data", []int64{1, 2}, DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{1, 2}))
+	result, err := client.Infer(ctx, "face", "", []*InferInput{input}, nil, WithRequestID("frame-1"), WithPriority(2))
+	assert.NoError(t, err)
+	assert.Equal(t, "frame-1", result.ID())
+
+	_, err = client.ModelGRPCInfer([]*grpc_client.ModelInferRequest_InferInputTensor{input.Tensor()}, nil, [][]byte{input.RawData()},
+		"face", "", 5*time.Second, WithServerTimeout(time.Second))
+	assert.NoError(t, err)
+
+	requests := server.InferRequests()
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "frame-1", requests[0].Id)
+	assert.Equal(t, uint64(2), requests[0].Parameters[priorityParam].GetUint64Param())
+	assert.Equal(t, int64(1000000), requests[1].Parameters[timeoutParam].GetInt64Param())
+
+	// the request ID is kept on the result when the server does not echo it
+	result = newInferResultFor(&grpc_client.ModelInferRequest{Id: "frame-2"}, &grpc_client.ModelInferResponse{})
+	assert.Equal(t, "frame-2", result.ID())
+}
+
+func TestTritonHTTPClientService_InferOptions(t *testing.T) {
+	var request map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(TritonAPIForModelPrefix+"face/infer", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"model_name": "face",
+			"outputs":    []map[string]interface{}{{"name": "labels", "datatype": "BYTES", "shape": []int64{1}, "data": []string{"0.9:1:face"}}},
+		})
+	})
+	client := newHTTPTestClient(t, mux)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	input := NewInferInput("x", []int64{1}, DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{1}))
+	result, err := client.Infer(ctx, "face", "", []*InferInput{input}, []*InferRequestedOutput{NewInferRequestedOutput("labels")},
+		WithRequestID("req-9"), WithPriority(1), WithBinaryDataOutput(false), WithClassCount(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "req-9", result.ID())
+
+	assert.Equal(t, "req-9", request["id"])
+	parameters := request["parameters"].(map[string]interface{})
+	assert.Equal(t, float64(1), parameters[priorityParam])
+	assert.Equal(t, false, parameters[binaryDataOutputParam])
+	outputParameters := request["outputs"].([]interface{})[0].(map[string]interface{})["parameters"].(map[string]interface{})
+	assert.Equal(t, false, outputParameters[binaryDataParam])
+	assert.Equal(t, float64(1), outputParameters[classificationParam])
+	labels, err := result.AsStrings("labels")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0.9:1:face"}, labels)
+}
@@ -0,0 +1,415 @@
+package triton_client
+
+import (
+	"encoding/binary"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"gorgonia.org/tensor"
+	"math"
+)
+
+// InferResult wraps a ModelInferResponse and decodes its outputs into typed slices.
+type InferResult struct {
+	response *grpc_client.ModelInferResponse
+	outputs  map[string]int
+}
+
+// NewInferResult wraps the response of an inference request.
+func NewInferResult(response *grpc_client.ModelInferResponse) *InferResult {
+	outputs := make(map[string]int, len(response.Outputs))
+	for idx, output := range response.Outputs {
+		outputs[output.Name] = idx
+	}
+	return &InferResult{
+		response: response,
+		outputs:  outputs,
+	}
+}
+
+// newInferResultFor wraps the response of modelInferRequest, echoing the request ID when
+// the server did not.
+func newInferResultFor(modelInferRequest *grpc_client.ModelInferRequest, response *grpc_client.ModelInferResponse) *InferResult {
+	if response.Id == "" {
+		response.Id = modelInferRequest.Id
+	}
+	return NewInferResult(response)
+}
+
+// Response returns the underlying ModelInferResponse.
+func (r *InferResult) Response() *grpc_client.ModelInferResponse {
+	return r.response
+}
+
+// ModelName returns the name of the model that produced the result.
+func (r *InferResult) ModelName() string {
+	return r.response.ModelName
+}
+
+// ModelVersion returns the version of the model that produced the result.
+func (r *InferResult) ModelVersion() string {
+	return r.response.ModelVersion
+}
+
+// ID returns the ID of the request that produced the result.
+func (r *InferResult) ID() string {
+	return r.response.Id
+}
+
+// OutputNames returns the names of the outputs in the order Triton returned them.
+func (r *InferResult) OutputNames() []string {
+	names := make([]string, len(r.response.Outputs))
+	for idx, output := range r.response.Outputs {
+		names[idx] = output.Name
+	}
+	return names
+}
+
+// Output returns the metadata of the named output.
+func (r *InferResult) Output(name string) (*grpc_client.ModelInferResponse_InferOutputTensor, error) {
+	idx, ok := r.outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("output %s not found in response", name)
+	}
+	return r.response.Outputs[idx], nil
+}
+
+// Shape returns the shape of the named output as reported by Triton.
+func (r *InferResult) Shape(name string) ([]int64, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return nil, err
+	}
+	return output.Shape, nil
+}
+
+// Datatype returns the Triton datatype of the named output.
+func (r *InferResult) Datatype(name string) (string, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return "", err
+	}
+	return output.Datatype, nil
+}
+
+// RawData returns the little-endian contents of the named output. Outputs returned as typed
+// contents instead of raw contents are serialized on the fly.
+func (r *InferResult) RawData(name string) ([]byte, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return nil, err
+	}
+	idx := r.outputs[name]
+	if idx < len(r.response.RawOutputContents) {
+		return r.response.RawOutputContents[idx], nil
+	}
+	if output.Contents == nil {
+		return nil, fmt.Errorf("output %s has no data", name)
+	}
+	return contentsToRaw(output.Datatype, output.Contents)
+}
+
+// fixedData returns the raw data of the named output after checking its datatype and that
+// its size matches the shape.
+func (r *InferResult) fixedData(name, datatype string) ([]byte, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return nil, err
+	}
+	if output.Datatype != datatype {
+		return nil, fmt.Errorf("output %s has datatype %s, expected %s", name, output.Datatype, datatype)
+	}
+	raw, err := r.RawData(name)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := shapeElementCount(output.Shape)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+	if expected := count * dataTypeByteSize(datatype); len(raw) != expected {
+		return nil, fmt.Errorf("output %s: got %d bytes, shape %v of %s requires %d", name, len(raw), output.Shape, datatype, expected)
+	}
+	return raw, nil
+}
+
+// AsBool decodes a BOOL output.
+func (r *InferResult) AsBool(name string) ([]bool, error) {
+	raw, err := r.fixedData(name, DataTypeBool)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 1, func(b []byte) bool { return b[0] != 0 }), nil
+}
+
+// AsUint8 decodes a UINT8 output.
+func (r *InferResult) AsUint8(name string) ([]uint8, error) {
+	raw, err := r.fixedData(name, DataTypeUint8)
+	if err != nil {
+		return nil, err
+	}
+	return append([]uint8(nil), raw...), nil
+}
+
+// AsUint16 decodes a UINT16 output.
+func (r *InferResult) AsUint16(name string) ([]uint16, error) {
+	raw, err := r.fixedData(name, DataTypeUint16)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 2, binary.LittleEndian.Uint16), nil
+}
+
+// AsUint32 decodes a UINT32 output.
+func (r *InferResult) AsUint32(name string) ([]uint32, error) {
+	raw, err := r.fixedData(name, DataTypeUint32)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 4, binary.LittleEndian.Uint32), nil
+}
+
+// AsUint64 decodes a UINT64 output.
+func (r *InferResult) AsUint64(name string) ([]uint64, error) {
+	raw, err := r.fixedData(name, DataTypeUint64)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 8, binary.LittleEndian.Uint64), nil
+}
+
+// AsInt8 decodes an INT8 output.
+func (r *InferResult) AsInt8(name string) ([]int8, error) {
+	raw, err := r.fixedData(name, DataTypeInt8)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 1, func(b []byte) int8 { return int8(b[0]) }), nil
+}
+
+// AsInt16 decodes an INT16 output.
+func (r *InferResult) AsInt16(name string) ([]int16, error) {
+	raw, err := r.fixedData(name, DataTypeInt16)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 2, func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }), nil
+}
+
+// AsInt32 decodes an INT32 output.
+func (r *InferResult) AsInt32(name string) ([]int32, error) {
+	raw, err := r.fixedData(name, DataTypeInt32)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 4, func(b []byte) int32 { return int32(binary.LittleEndian.Uint32(b)) }), nil
+}
+
+// AsInt64 decodes an INT64 output.
+func (r *InferResult) AsInt64(name string) ([]int64, error) {
+	raw, err := r.fixedData(name, DataTypeInt64)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 8, func(b []byte) int64 { return int64(binary.LittleEndian.Uint64(b)) }), nil
+}
+
+// AsFloat32 decodes an FP32 output.
+func (r *InferResult) AsFloat32(name string) ([]float32, error) {
+	raw, err := r.fixedData(name, DataTypeFP32)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 4, func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }), nil
+}
+
+// AsFloat64 decodes an FP64 output.
+func (r *InferResult) AsFloat64(name string) ([]float64, error) {
+	raw, err := r.fixedData(name, DataTypeFP64)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 8, func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }), nil
+}
+
+// AsFloat16 decodes an FP16 output into float32 values.
+func (r *InferResult) AsFloat16(name string) ([]float32, error) {
+	raw, err := r.fixedData(name, DataTypeFP16)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 2, func(b []byte) float32 { return Float16ToFloat32(binary.LittleEndian.Uint16(b)) }), nil
+}
+
+// AsBF16 decodes a BF16 output into float32 values.
+func (r *InferResult) AsBF16(name string) ([]float32, error) {
+	raw, err := r.fixedData(name, DataTypeBF16)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFixed(raw, 2, func(b []byte) float32 { return BFloat16ToFloat32(binary.LittleEndian.Uint16(b)) }), nil
+}
+
+// AsBytes decodes a BYTES output into its length-prefixed elements.
+func (r *InferResult) AsBytes(name string) ([][]byte, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return nil, err
+	}
+	if output.Datatype != DataTypeBytes {
+		return nil, fmt.Errorf("output %s has datatype %s, expected %s", name, output.Datatype, DataTypeBytes)
+	}
+	raw, err := r.RawData(name)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := decodeBytes(raw)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+
+	count, err := shapeElementCount(output.Shape)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+	if len(elements) != count {
+		return nil, fmt.Errorf("output %s: got %d elements, shape %v requires %d", name, len(elements), output.Shape, count)
+	}
+	return elements, nil
+}
+
+// AsStrings decodes a BYTES output into strings.
+func (r *InferResult) AsStrings(name string) ([]string, error) {
+	elements, err := r.AsBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(elements))
+	for idx, element := range elements {
+		strs[idx] = string(element)
+	}
+	return strs, nil
+}
+
+// AsTensor decodes the named output into a tensor.Dense with the shape reported by Triton.
+// FP16 and BF16 outputs are widened to float32. BYTES outputs are not supported.
+func (r *InferResult) AsTensor(name string) (*tensor.Dense, error) {
+	output, err := r.Output(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var backing interface{}
+	switch output.Datatype {
+	case DataTypeBool:
+		backing, err = r.AsBool(name)
+	case DataTypeUint8:
+		backing, err = r.AsUint8(name)
+	case DataTypeUint16:
+		backing, err = r.AsUint16(name)
+	case DataTypeUint32:
+		backing, err = r.AsUint32(name)
+	case DataTypeUint64:
+		backing, err = r.AsUint64(name)
+	case DataTypeInt8:
+		backing, err = r.AsInt8(name)
+	case DataTypeInt16:
+		backing, err = r.AsInt16(name)
+	case DataTypeInt32:
+		backing, err = r.AsInt32(name)
+	case DataTypeInt64:
+		backing, err = r.AsInt64(name)
+	case DataTypeFP16:
+		backing, err = r.AsFloat16(name)
+	case DataTypeFP32:
+		backing, err = r.AsFloat32(name)
+	case DataTypeFP64:
+		backing, err = r.AsFloat64(name)
+	case DataTypeBF16:
+		backing, err = r.AsBF16(name)
+	default:
+		return nil, fmt.Errorf("output %s: cannot build a tensor from datatype %s", name, output.Datatype)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	shape := make([]int, len(output.Shape))
+	for idx, dim := range output.Shape {
+		shape[idx] = int(dim)
+	}
+	return tensor.New(tensor.WithBacking(backing), tensor.WithShape(shape...)), nil
+}
+
+// shapeElementCount returns the number of elements described by a fully specified shape.
+func shapeElementCount(shape []int64) (int, error) {
+	count := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			return 0, fmt.Errorf("shape %v must not contain wildcard dimensions", shape)
+		}
+		count *= int(dim)
+	}
+	return count, nil
+}
+
+func decodeFixed[T any](raw []byte, size int, get func([]byte) T) []T {
+	data := make([]T, len(raw)/size)
+	for idx := range data {
+		data[idx] = get(raw[idx*size : (idx+1)*size])
+	}
+	return data
+}
+
+// decodeBytes parses BYTES contents, each element prefixed by its 4-byte little-endian length.
+func decodeBytes(raw []byte) ([][]byte, error) {
+	var elements [][]byte
+	for offset := 0; offset < len(raw); {
+		if offset+4 > len(raw) {
+			return nil, fmt.Errorf("truncated length prefix at byte %d", offset)
+		}
+		size := int(binary.LittleEndian.Uint32(raw[offset:]))
+		offset += 4
+		if offset+size > len(raw) {
+			return nil, fmt.Errorf("element at byte %d needs %d bytes, only %d left", offset-4, size, len(raw)-offset)
+		}
+		elements = append(elements, raw[offset:offset+size])
+		offset += size
+	}
+	return elements, nil
+}
+
+// contentsToRaw serializes typed tensor contents into little-endian raw contents.
+func contentsToRaw(datatype string, contents *grpc_client.InferTensorContents) ([]byte, error) {
+	switch datatype {
+	case DataTypeBool:
+		return encodeFixed(contents.BoolContents, 1, func(b []byte, val bool) {
+			if val {
+				b[0] = 1
+			}
+		}), nil
+	case DataTypeUint8:
+		return encodeFixed(contents.UintContents, 1, func(b []byte, val uint32) { b[0] = byte(val) }), nil
+	case DataTypeUint16:
+		return encodeFixed(contents.UintContents, 2, func(b []byte, val uint32) { binary.LittleEndian.PutUint16(b, uint16(val)) }), nil
+	case DataTypeUint32:
+		return encodeFixed(contents.UintContents, 4, binary.LittleEndian.PutUint32), nil
+	case DataTypeUint64:
+		return encodeFixed(contents.Uint64Contents, 8, binary.LittleEndian.PutUint64), nil
+	case DataTypeInt8:
+		return encodeFixed(contents.IntContents, 1, func(b []byte, val int32) { b[0] = byte(val) }), nil
+	case DataTypeInt16:
+		return encodeFixed(contents.IntContents, 2, func(b []byte, val int32) { binary.LittleEndian.PutUint16(b, uint16(val)) }), nil
+	case DataTypeInt32:
+		return encodeFixed(contents.IntContents, 4, func(b []byte, val int32) { binary.LittleEndian.PutUint32(b, uint32(val)) }), nil
+	case DataTypeInt64:
+		return encodeFixed(contents.Int64Contents, 8, func(b []byte, val int64) { binary.LittleEndian.PutUint64(b, uint64(val)) }), nil
+	case DataTypeFP32:
+		return encodeFixed(contents.Fp32Contents, 4, func(b []byte, val float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(val)) }), nil
+	case DataTypeFP64:
+		return encodeFixed(contents.Fp64Contents, 8, func(b []byte, val float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(val)) }), nil
+	case DataTypeBytes:
+		return encodeBytes(contents.BytesContents), nil
+	}
+	return nil, fmt.Errorf("datatype %s cannot be sent as typed contents", datatype)
+}
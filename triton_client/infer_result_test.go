@@ -0,0 +1,114 @@
+package triton_client
+
+import (
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestInferResult() *InferResult {
+	return NewInferResult(&grpc_client.ModelInferResponse{
+		ModelName:    "simple",
+		ModelVersion: "1",
+		Id:           "42",
+		Outputs: []*grpc_client.ModelInferResponse_InferOutputTensor{
+			{Name: "FP32", Datatype: DataTypeFP32, Shape: []int64{1, 2}},
+			{Name: "INT64", Datatype: DataTypeInt64, Shape: []int64{1}},
+			{Name: "BOOL", Datatype: DataTypeBool, Shape: []int64{2}},
+			{Name: "FP16", Datatype: DataTypeFP16, Shape: []int64{1}},
+			{Name: "BF16", Datatype: DataTypeBF16, Shape: []int64{1}},
+			{Name: "BYTES", Datatype: DataTypeBytes, Shape: []int64{2}},
+			{Name: "SHORT", Datatype: DataTypeFP32, Shape: []int64{2}},
+		},
+		RawOutputContents: [][]byte{
+			{0, 0, 0x80, 0x3f, 0, 0, 0, 0x40},
+			{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			{1, 0},
+			{0, 0x3c},
+			{0x80, 0x3f},
+			{2, 0, 0, 0, 'h', 'i', 0, 0, 0, 0},
+			{0, 0, 0x80},
+		},
+	})
+}
+
+func TestInferResult(t *testing.T) {
+	result := newTestInferResult()
+	assert.Equal(t, "simple", result.ModelName())
+	assert.Equal(t, "1", result.ModelVersion())
+	assert.Equal(t, "42", result.ID())
+	assert.Equal(t, []string{"FP32", "INT64", "BOOL", "FP16", "BF16", "BYTES", "SHORT"}, result.OutputNames())
+
+	shape, err := result.Shape("FP32")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, shape)
+
+	fp32, err := result.AsFloat32("FP32")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 2}, fp32)
+
+	int64s, err := result.AsInt64("INT64")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1}, int64s)
+
+	bools, err := result.AsBool("BOOL")
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, bools)
+
+	fp16, err := result.AsFloat16("FP16")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1}, fp16)
+
+	bf16, err := result.AsBF16("BF16")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1}, bf16)
+
+	strs, err := result.AsStrings("BYTES")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi", ""}, strs)
+
+	dense, err := result.AsTensor("FP32")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, []int(dense.Shape()))
+	assert.Equal(t, []float32{1, 2}, dense.Data())
+}
+
+func TestInferResult_Errors(t *testing.T) {
+	result := newTestInferResult()
+
+	_, err := result.AsFloat32("MISSING")
+	assert.EqualError(t, err, "output MISSING not found in response")
+
+	_, err = result.AsInt32("FP32")
+	assert.EqualError(t, err, "output FP32 has datatype FP32, expected INT32")
+
+	_, err = result.AsFloat32("SHORT")
+	assert.EqualError(t, err, "output SHORT: got 3 bytes, shape [2] of FP32 requires 8")
+
+	_, err = result.AsTensor("BYTES")
+	assert.EqualError(t, err, "output BYTES: cannot build a tensor from datatype BYTES")
+
+	truncated := NewInferResult(&grpc_client.ModelInferResponse{
+		Outputs:           []*grpc_client.ModelInferResponse_InferOutputTensor{{Name: "BYTES", Datatype: DataTypeBytes, Shape: []int64{1}}},
+		RawOutputContents: [][]byte{{5, 0, 0, 0, 'a'}},
+	})
+	_, err = truncated.AsStrings("BYTES")
+	assert.EqualError(t, err, "output BYTES: element at byte 0 needs 5 bytes, only 1 left")
+}
+
+func TestInferResult_Contents(t *testing.T) {
+	result := NewInferResult(&grpc_client.ModelInferResponse{
+		Outputs: []*grpc_client.ModelInferResponse_InferOutputTensor{
+			{Name: "INT32", Datatype: DataTypeInt32, Shape: []int64{2}, Contents: &grpc_client.InferTensorContents{IntContents: []int32{-1, 7}}},
+			{Name: "BYTES", Datatype: DataTypeBytes, Shape: []int64{1}, Contents: &grpc_client.InferTensorContents{BytesContents: [][]byte{[]byte("abc")}}},
+		},
+	})
+
+	ints, err := result.AsInt32("INT32")
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{-1, 7}, ints)
+
+	strs, err := result.AsStrings("BYTES")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, strs)
+}
@@ -0,0 +1,138 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"sync"
+	"time"
+)
+
+// DefaultModelCacheTTL is how long model configs and metadata are cached by default.
+const DefaultModelCacheTTL = 5 * time.Minute
+
+// modelCacheFetchTimeout bounds a fetch, which does not end with the caller that started it.
+const modelCacheFetchTimeout = 30 * time.Second
+
+type modelCacheKind int
+
+const (
+	modelCacheConfig modelCacheKind = iota
+	modelCacheMetadata
+)
+
+type modelCacheKey struct {
+	kind    modelCacheKind
+	name    string
+	version string
+}
+
+// modelCacheEntry holds a cached value. done is closed once the fetch of the value finished,
+// until then concurrent lookups wait on it instead of fetching again.
+type modelCacheEntry struct {
+	value   interface{}
+	err     error
+	expires time.Time
+	done    chan struct{}
+}
+
+// modelCache caches model configs and metadata by model name and version.
+type modelCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[modelCacheKey]*modelCacheEntry
+}
+
+func newModelCache(ttl time.Duration) *modelCache {
+	return &modelCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[modelCacheKey]*modelCacheEntry),
+	}
+}
+
+// setTTL changes the TTL of the entries fetched from now on. A ttl <= 0 keeps entries until
+// they are invalidated.
+func (c *modelCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// get returns the cached value of key, calling fetch on a miss or when the entry expired.
+// Concurrent misses share a single fetch and its result, errors are not cached. The fetch runs
+// detached from the context of the caller that started it, so a caller giving up does not fail
+// the others, and every caller only waits until its own ctx ends.
+func (c *modelCache) get(ctx context.Context, key modelCacheKey, fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if c.ttl > 0 && !c.now().Before(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &modelCacheEntry{done: make(chan struct{})}
+		c.entries[key] = entry
+		go c.fetch(context.WithoutCancel(ctx), key, entry, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.done:
+		return entry.value, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch fills entry with the result of fetch.
+func (c *modelCache) fetch(ctx context.Context, key modelCacheKey, entry *modelCacheEntry, fetch func(ctx context.Context) (interface{}, error)) {
+	ctx, cancel := context.WithTimeout(ctx, modelCacheFetchTimeout)
+	defer cancel()
+	value, err := fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.value, entry.err = value, err
+	entry.expires = c.now().Add(c.ttl)
+	if err != nil && c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	close(entry.done)
+}
+
+// invalidate drops every cached entry of the model, or of all models when modelName is empty.
+func (c *modelCache) invalidate(modelName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if modelName == "" || key.name == modelName {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *modelCache) config(ctx context.Context, modelName, modelVersion string, fetch func(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelConfigResponse, error)) (*grpc_client.ModelConfigResponse, error) {
+	value, err := c.get(ctx, modelCacheKey{kind: modelCacheConfig, name: modelName, version: modelVersion}, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx, modelName, modelVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*grpc_client.ModelConfigResponse), nil
+}
+
+func (c *modelCache) metadata(ctx context.Context, modelName, modelVersion string, fetch func(ctx context.Context, modelName, modelVersion string) (*grpc_client.ModelMetadataResponse, error)) (*grpc_client.ModelMetadataResponse, error) {
+	value, err := c.get(ctx, modelCacheKey{kind: modelCacheMetadata, name: modelName, version: modelVersion}, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx, modelName, modelVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*grpc_client.ModelMetadataResponse), nil
+}
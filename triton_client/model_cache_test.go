@@ -0,0 +1,164 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestModelCache_TTL(t *testing.T) {
+	cache := newModelCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	var fetches int
+	fetch := func(ctx context.Context) (interface{}, error) {
+		fetches++
+		return fetches, nil
+	}
+	key := modelCacheKey{kind: modelCacheConfig, name: "face"}
+
+	value, err := cache.get(context.Background(), key, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	value, _ = cache.get(context.Background(), key, fetch)
+	assert.Equal(t, 1, value)
+
+	// other versions and kinds are cached separately
+	value, _ = cache.get(context.Background(), modelCacheKey{kind: modelCacheConfig, name: "face", version: "2"}, fetch)
+	assert.Equal(t, 2, value)
+	value, _ = cache.get(context.Background(), modelCacheKey{kind: modelCacheMetadata, name: "face"}, fetch)
+	assert.Equal(t, 3, value)
+
+	now = now.Add(time.Minute)
+	value, _ = cache.get(context.Background(), key, fetch)
+	assert.Equal(t, 4, value)
+
+	cache.invalidate("face")
+	value, _ = cache.get(context.Background(), key, fetch)
+	assert.Equal(t, 5, value)
+
+	cache.setTTL(0)
+	cache.invalidate("")
+	value, _ = cache.get(context.Background(), key, fetch)
+	assert.Equal(t, 6, value)
+	now = now.Add(24 * time.Hour)
+	value, _ = cache.get(context.Background(), key, fetch)
+	assert.Equal(t, 6, value)
+}
+
+func TestModelCache_Singleflight(t *testing.T) {
+	cache := newModelCache(time.Minute)
+	key := modelCacheKey{kind: modelCacheConfig, name: "face"}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (interface{}, error) {
+		fetches.Add(1)
+		<-release
+		return "config", nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]interface{}, 8)
+	for idx := range values {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			values[idx], _ = cache.get(context.Background(), key, fetch)
+		}(idx)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), fetches.Load())
+	for _, value := range values {
+		assert.Equal(t, "config", value)
+	}
+}
+
+func TestModelCache_CallerCancels(t *testing.T) {
+	cache := newModelCache(time.Minute)
+	key := modelCacheKey{kind: modelCacheConfig, name: "face"}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	fetch := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "config", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// the caller starting the fetch gives up, the others still get the value
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := cache.get(ctx, key, fetch)
+		first <- err
+	}()
+	<-started
+	second := make(chan interface{})
+	go func() {
+		value, err := cache.get(context.Background(), key, fetch)
+		assert.NoError(t, err)
+		second <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	assert.Equal(t, "config", <-second)
+}
+
+func TestModelCache_ErrorsAreNotCached(t *testing.T) {
+	cache := newModelCache(time.Minute)
+	key := modelCacheKey{kind: modelCacheConfig, name: "face"}
+
+	_, err := cache.get(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("unavailable")
+	})
+	assert.Error(t, err)
+
+	value, err := cache.get(context.Background(), key, func(ctx context.Context) (interface{}, error) {
+		return "config", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "config", value)
+}
+
+func TestTritonGRPCClient_CachedModelConfigurationContext(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Config: &grpc_client.ModelConfig{Name: "face", MaxBatchSize: 1}})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		config, err := client.CachedModelConfigurationContext(ctx, "face", "")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), config.Config.MaxBatchSize)
+	}
+	assert.Len(t, server.Requests("ModelConfig"), 1)
+
+	server.AddModel(&tritontest.Model{Config: &grpc_client.ModelConfig{Name: "face", MaxBatchSize: 8}})
+	_, err := client.ModelLoadWithGRPCContext(ctx, "", "face", nil)
+	assert.NoError(t, err)
+	config, err := client.CachedModelConfigurationContext(ctx, "face", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(8), config.Config.MaxBatchSize)
+	assert.Len(t, server.Requests("ModelConfig"), 2)
+
+	_, err = client.CachedModelMetadataContext(ctx, "face", "")
+	assert.NoError(t, err)
+	_, err = client.CachedModelMetadataContext(ctx, "face", "")
+	assert.NoError(t, err)
+	assert.Len(t, server.Requests("ModelMetadata"), 1)
+}
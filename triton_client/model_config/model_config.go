@@ -0,0 +1,222 @@
+// Package model_config ports the model configuration utilities of Triton's
+// common/src/model_config.cc to the generated Go types.
+package model_config
+
+import (
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"strconv"
+	"strings"
+)
+
+// WildcardDim is the size of a variable-size dimension.
+const WildcardDim = -1
+
+// IsFixedSizeDataType reports whether every element of dtype has the same size.
+func IsFixedSizeDataType(dtype grpc_client.DataType) bool {
+	return dtype != grpc_client.DataType_TYPE_STRING
+}
+
+// GetDataTypeByteSize returns the size of one element of dtype, or 0 for TYPE_STRING and
+// invalid datatypes.
+func GetDataTypeByteSize(dtype grpc_client.DataType) int64 {
+	switch dtype {
+	case grpc_client.DataType_TYPE_BOOL, grpc_client.DataType_TYPE_UINT8, grpc_client.DataType_TYPE_INT8:
+		return 1
+	case grpc_client.DataType_TYPE_UINT16, grpc_client.DataType_TYPE_INT16, grpc_client.DataType_TYPE_FP16, grpc_client.DataType_TYPE_BF16:
+		return 2
+	case grpc_client.DataType_TYPE_UINT32, grpc_client.DataType_TYPE_INT32, grpc_client.DataType_TYPE_FP32:
+		return 4
+	case grpc_client.DataType_TYPE_UINT64, grpc_client.DataType_TYPE_INT64, grpc_client.DataType_TYPE_FP64:
+		return 8
+	}
+	return 0
+}
+
+// GetElementCount returns the number of elements of a shape, -1 when it has a wildcard
+// dimension. Like Triton an empty shape has 0 elements.
+func GetElementCount(dims []int64) int64 {
+	var count int64
+	for idx, dim := range dims {
+		if dim == WildcardDim {
+			return -1
+		}
+		if idx == 0 {
+			count = dim
+		} else {
+			count *= dim
+		}
+	}
+	return count
+}
+
+// GetInputElementCount returns the number of elements of a model input.
+func GetInputElementCount(mio *grpc_client.ModelInput) int64 {
+	return GetElementCount(mio.Dims)
+}
+
+// GetOutputElementCount returns the number of elements of a model output.
+func GetOutputElementCount(mio *grpc_client.ModelOutput) int64 {
+	return GetElementCount(mio.Dims)
+}
+
+// GetByteSize returns the size of a tensor, -1 when the datatype is not fixed size or the
+// shape has a wildcard dimension.
+func GetByteSize(dtype grpc_client.DataType, dims []int64) int64 {
+	size := GetDataTypeByteSize(dtype)
+	if size == 0 {
+		return -1
+	}
+	count := GetElementCount(dims)
+	if count == -1 {
+		return -1
+	}
+	return count * size
+}
+
+// GetBatchByteSize returns the size of a batch of batchSize tensors, see GetByteSize. A batch
+// size below 1 counts as a single tensor unless the shape is empty.
+func GetBatchByteSize(batchSize int64, dtype grpc_client.DataType, dims []int64) int64 {
+	if len(dims) == 0 {
+		return batchSize * GetDataTypeByteSize(dtype)
+	}
+	size := GetByteSize(dtype, dims)
+	if size == -1 {
+		return -1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return batchSize * size
+}
+
+// GetInputByteSize returns the size of a model input, see GetByteSize.
+func GetInputByteSize(mio *grpc_client.ModelInput) int64 {
+	return GetByteSize(mio.DataType, mio.Dims)
+}
+
+// GetOutputByteSize returns the size of a model output, see GetByteSize.
+func GetOutputByteSize(mio *grpc_client.ModelOutput) int64 {
+	return GetByteSize(mio.DataType, mio.Dims)
+}
+
+// CompareDims reports whether two shapes are equal.
+func CompareDims(dims0, dims1 []int64) bool {
+	if len(dims0) != len(dims1) {
+		return false
+	}
+	for idx := range dims0 {
+		if dims0[idx] != dims1[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareDimsWithWildcard reports whether two shapes have the same rank and equal dimensions,
+// a wildcard dimension in either shape matches any size.
+func CompareDimsWithWildcard(dims0, dims1 []int64) bool {
+	if len(dims0) != len(dims1) {
+		return false
+	}
+	for idx := range dims0 {
+		if dims0[idx] != WildcardDim && dims1[idx] != WildcardDim && dims0[idx] != dims1[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// DimsListToString formats a shape like "[1,3,-1,-1]".
+func DimsListToString(dims []int64) string {
+	return DimsListToStringFrom(dims, 0)
+}
+
+// DimsListToStringFrom formats the dimensions of a shape starting at startIdx, e.g. to leave
+// out the batch dimension.
+func DimsListToStringFrom(dims []int64, startIdx int) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for idx := startIdx; idx < len(dims); idx++ {
+		if idx < 0 {
+			continue
+		}
+		if idx > startIdx {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatInt(dims[idx], 10))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// DataTypeToProtocolString returns the protocol datatype of dtype, e.g. "FP32" for TYPE_FP32
+// and "BYTES" for TYPE_STRING, or "<invalid>".
+func DataTypeToProtocolString(dtype grpc_client.DataType) string {
+	switch dtype {
+	case grpc_client.DataType_TYPE_BOOL:
+		return "BOOL"
+	case grpc_client.DataType_TYPE_UINT8:
+		return "UINT8"
+	case grpc_client.DataType_TYPE_UINT16:
+		return "UINT16"
+	case grpc_client.DataType_TYPE_UINT32:
+		return "UINT32"
+	case grpc_client.DataType_TYPE_UINT64:
+		return "UINT64"
+	case grpc_client.DataType_TYPE_INT8:
+		return "INT8"
+	case grpc_client.DataType_TYPE_INT16:
+		return "INT16"
+	case grpc_client.DataType_TYPE_INT32:
+		return "INT32"
+	case grpc_client.DataType_TYPE_INT64:
+		return "INT64"
+	case grpc_client.DataType_TYPE_FP16:
+		return "FP16"
+	case grpc_client.DataType_TYPE_FP32:
+		return "FP32"
+	case grpc_client.DataType_TYPE_FP64:
+		return "FP64"
+	case grpc_client.DataType_TYPE_STRING:
+		return "BYTES"
+	case grpc_client.DataType_TYPE_BF16:
+		return "BF16"
+	}
+	return "<invalid>"
+}
+
+// ProtocolStringToDataType returns the datatype of a protocol datatype string, or
+// TYPE_INVALID for unknown strings.
+func ProtocolStringToDataType(dtype string) grpc_client.DataType {
+	switch dtype {
+	case "BOOL":
+		return grpc_client.DataType_TYPE_BOOL
+	case "UINT8":
+		return grpc_client.DataType_TYPE_UINT8
+	case "UINT16":
+		return grpc_client.DataType_TYPE_UINT16
+	case "UINT32":
+		return grpc_client.DataType_TYPE_UINT32
+	case "UINT64":
+		return grpc_client.DataType_TYPE_UINT64
+	case "INT8":
+		return grpc_client.DataType_TYPE_INT8
+	case "INT16":
+		return grpc_client.DataType_TYPE_INT16
+	case "INT32":
+		return grpc_client.DataType_TYPE_INT32
+	case "INT64":
+		return grpc_client.DataType_TYPE_INT64
+	case "FP16":
+		return grpc_client.DataType_TYPE_FP16
+	case "FP32":
+		return grpc_client.DataType_TYPE_FP32
+	case "FP64":
+		return grpc_client.DataType_TYPE_FP64
+	case "BYTES":
+		return grpc_client.DataType_TYPE_STRING
+	case "BF16":
+		return grpc_client.DataType_TYPE_BF16
+	}
+	return grpc_client.DataType_TYPE_INVALID
+}
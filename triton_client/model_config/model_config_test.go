@@ -0,0 +1,125 @@
+package model_config
+
+import (
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDataTypes(t *testing.T) {
+	tests := []struct {
+		dtype    grpc_client.DataType
+		protocol string
+		size     int64
+	}{
+		{grpc_client.DataType_TYPE_BOOL, "BOOL", 1},
+		{grpc_client.DataType_TYPE_UINT8, "UINT8", 1},
+		{grpc_client.DataType_TYPE_UINT16, "UINT16", 2},
+		{grpc_client.DataType_TYPE_UINT32, "UINT32", 4},
+		{grpc_client.DataType_TYPE_UINT64, "UINT64", 8},
+		{grpc_client.DataType_TYPE_INT8, "INT8", 1},
+		{grpc_client.DataType_TYPE_INT16, "INT16", 2},
+		{grpc_client.DataType_TYPE_INT32, "INT32", 4},
+		{grpc_client.DataType_TYPE_INT64, "INT64", 8},
+		{grpc_client.DataType_TYPE_FP16, "FP16", 2},
+		{grpc_client.DataType_TYPE_FP32, "FP32", 4},
+		{grpc_client.DataType_TYPE_FP64, "FP64", 8},
+		{grpc_client.DataType_TYPE_STRING, "BYTES", 0},
+		{grpc_client.DataType_TYPE_BF16, "BF16", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			assert.Equal(t, tt.size, GetDataTypeByteSize(tt.dtype))
+			assert.Equal(t, tt.protocol, DataTypeToProtocolString(tt.dtype))
+			assert.Equal(t, tt.dtype, ProtocolStringToDataType(tt.protocol))
+			assert.Equal(t, tt.dtype != grpc_client.DataType_TYPE_STRING, IsFixedSizeDataType(tt.dtype))
+		})
+	}
+
+	assert.Equal(t, int64(0), GetDataTypeByteSize(grpc_client.DataType_TYPE_INVALID))
+	assert.Equal(t, "<invalid>", DataTypeToProtocolString(grpc_client.DataType_TYPE_INVALID))
+	for _, protocol := range []string{"", "INT", "FP8", "INT128", "STRING", "fp32", "BYTESS"} {
+		assert.Equal(t, grpc_client.DataType_TYPE_INVALID, ProtocolStringToDataType(protocol), protocol)
+	}
+}
+
+func TestGetElementCountAndByteSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		dtype    grpc_client.DataType
+		dims     []int64
+		count    int64
+		byteSize int64
+	}{
+		{"empty shape", grpc_client.DataType_TYPE_FP32, nil, 0, 0},
+		{"scalar dimension", grpc_client.DataType_TYPE_FP32, []int64{1}, 1, 4},
+		{"image", grpc_client.DataType_TYPE_FP32, []int64{3, 640, 640}, 3 * 640 * 640, 4 * 3 * 640 * 640},
+		{"zero dimension", grpc_client.DataType_TYPE_INT64, []int64{4, 0}, 0, 0},
+		{"wildcard", grpc_client.DataType_TYPE_FP32, []int64{3, -1, -1}, -1, -1},
+		{"bytes", grpc_client.DataType_TYPE_STRING, []int64{2}, 2, -1},
+		{"invalid datatype", grpc_client.DataType_TYPE_INVALID, []int64{2}, 2, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.count, GetElementCount(tt.dims))
+			assert.Equal(t, tt.byteSize, GetByteSize(tt.dtype, tt.dims))
+			assert.Equal(t, tt.count, GetInputElementCount(&grpc_client.ModelInput{DataType: tt.dtype, Dims: tt.dims}))
+			assert.Equal(t, tt.byteSize, GetOutputByteSize(&grpc_client.ModelOutput{DataType: tt.dtype, Dims: tt.dims}))
+		})
+	}
+}
+
+func TestGetBatchByteSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int64
+		dims      []int64
+		byteSize  int64
+	}{
+		{"batch of tensors", 8, []int64{2, 2}, 8 * 16},
+		{"batch size 0 counts once", 0, []int64{2, 2}, 16},
+		{"empty shape scales the element", 8, nil, 32},
+		{"empty shape with batch size 0", 0, nil, 0},
+		{"wildcard", 8, []int64{-1}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.byteSize, GetBatchByteSize(tt.batchSize, grpc_client.DataType_TYPE_FP32, tt.dims))
+		})
+	}
+}
+
+func TestCompareDims(t *testing.T) {
+	tests := []struct {
+		name     string
+		dims0    []int64
+		dims1    []int64
+		equal    bool
+		wildcard bool
+	}{
+		{"equal", []int64{1, 3}, []int64{1, 3}, true, true},
+		{"both empty", nil, []int64{}, true, true},
+		{"different rank", []int64{1, 3}, []int64{1, 3, 1}, false, false},
+		{"different size", []int64{1, 3}, []int64{1, 4}, false, false},
+		{"wildcard on the left", []int64{-1, 3}, []int64{8, 3}, false, true},
+		{"wildcard on the right", []int64{8, 3}, []int64{8, -1}, false, true},
+		{"wildcard does not fix rank", []int64{-1}, []int64{8, 3}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.equal, CompareDims(tt.dims0, tt.dims1))
+			assert.Equal(t, tt.wildcard, CompareDimsWithWildcard(tt.dims0, tt.dims1))
+		})
+	}
+}
+
+func TestDimsListToString(t *testing.T) {
+	assert.Equal(t, "[]", DimsListToString(nil))
+	assert.Equal(t, "[1,3,-1,-1]", DimsListToString([]int64{1, 3, -1, -1}))
+	assert.Equal(t, "[3,-1,-1]", DimsListToStringFrom([]int64{1, 3, -1, -1}, 1))
+	assert.Equal(t, "[]", DimsListToStringFrom([]int64{1}, 2))
+}
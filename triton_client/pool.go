@@ -0,0 +1,450 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoReadyEndpoint is returned when no endpoint of a Pool is healthy with the model ready.
+var ErrNoReadyEndpoint = errors.New("no ready endpoint")
+
+// Balancing selects how a Pool spreads requests over the ready endpoints.
+type Balancing int
+
+const (
+	// RoundRobin sends requests to the ready endpoints in turn.
+	RoundRobin Balancing = iota
+	// LeastOutstanding sends a request to the ready endpoint with the fewest requests in flight.
+	LeastOutstanding
+)
+
+// Pool defaults.
+const (
+	DefaultPoolProbeInterval   = 5 * time.Second
+	DefaultPoolProbeTimeout    = time.Second
+	DefaultPoolResolveInterval = 30 * time.Second
+	DefaultPoolMaxFailures     = 3
+	DefaultPoolDrainTimeout    = 30 * time.Second
+)
+
+// poolDrainPoll is how often a removed endpoint is checked for requests in flight.
+const poolDrainPoll = 10 * time.Millisecond
+
+// PoolConfig configures a Pool, zero values take the pool defaults.
+type PoolConfig struct {
+	// Resolver returns the addresses of the replicas.
+	Resolver Resolver
+	// DialOptions are used to connect to every replica.
+	DialOptions []grpc.DialOption
+	// Dial connects to a replica, it defaults to NewTritonGRPCClient with DialOptions.
+	Dial func(address string) (*TritonGRPCClient, error)
+	// RetryPolicy is set on the client of every replica, see SetRetryPolicy.
+	RetryPolicy *RetryPolicy
+	Balancing   Balancing
+	// ProbeInterval is how often every endpoint is probed with ServerReady and RepositoryIndex.
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+	// ResolveInterval is how often the Resolver is called again.
+	ResolveInterval time.Duration
+	// MaxFailures consecutive Unavailable inference errors eject an endpoint until it is probed
+	// successfully again.
+	MaxFailures int
+	// DrainTimeout is how long an endpoint removed by the resolver is kept connected for its
+	// requests in flight to complete.
+	DrainTimeout time.Duration
+}
+
+// EndpointStatus is the state of a Pool endpoint as of its last probe.
+type EndpointStatus struct {
+	Address string
+	Healthy bool
+	// Models are the ready models of the endpoint, with their version.
+	Models      []*grpc_client.RepositoryIndexResponse_ModelIndex
+	Outstanding int64
+	// Err is why the endpoint is not healthy, e.g. its connection failed.
+	Err error
+}
+
+// Pool routes inference over several Triton replicas. Endpoints are probed periodically with
+// ServerReady and RepositoryIndex, a request only goes to a healthy endpoint where the model
+// is ready. Endpoints failing inference with Unavailable are ejected until a probe succeeds.
+type Pool struct {
+	config PoolConfig
+
+	// resolveMu serializes Resolve, which dials without holding mu.
+	resolveMu sync.Mutex
+	mu        sync.RWMutex
+	endpoints []*poolEndpoint
+	next      atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	drains sync.WaitGroup
+}
+
+type poolEndpoint struct {
+	address string
+	// client is nil when the connection failed, the address is dialed again on Resolve.
+	client      *TritonGRPCClient
+	outstanding atomic.Int64
+
+	mu       sync.Mutex
+	healthy  bool
+	failures int
+	models   []*grpc_client.RepositoryIndexResponse_ModelIndex
+	err      error
+}
+
+// NewPool resolves and probes the endpoints, then keeps probing and resolving them in the
+// background until Close. It only fails when no endpoint could be connected, endpoints that
+// failed to connect or are not ready yet are reported by Endpoints.
+func NewPool(ctx context.Context, config PoolConfig) (*Pool, error) {
+	if config.Resolver == nil {
+		return nil, errors.New("pool requires a resolver")
+	}
+	if config.Dial == nil {
+		config.Dial = func(address string) (*TritonGRPCClient, error) {
+			return NewTritonGRPCClient(address, config.DialOptions)
+		}
+	}
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = DefaultPoolProbeInterval
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = DefaultPoolProbeTimeout
+	}
+	if config.ResolveInterval <= 0 {
+		config.ResolveInterval = DefaultPoolResolveInterval
+	}
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = DefaultPoolMaxFailures
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = DefaultPoolDrainTimeout
+	}
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	p := &Pool{config: config, ctx: loopCtx, cancel: cancel, done: make(chan struct{})}
+	if err := p.Resolve(ctx); err != nil {
+		cancel()
+		_ = p.closeEndpoints()
+		return nil, err
+	}
+	p.Probe(ctx)
+
+	go p.run(loopCtx)
+	return p, nil
+}
+
+// run probes and resolves the endpoints until ctx ends.
+func (p *Pool) run(ctx context.Context) {
+	defer close(p.done)
+	probe := time.NewTicker(p.config.ProbeInterval)
+	defer probe.Stop()
+	resolve := time.NewTicker(p.config.ResolveInterval)
+	defer resolve.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-probe.C:
+			p.Probe(ctx)
+		case <-resolve.C:
+			// a failed resolution keeps the current endpoints
+			_ = p.Resolve(ctx)
+			p.Probe(ctx)
+		}
+	}
+}
+
+// Resolve calls the resolver and updates the endpoints, connecting to new addresses and
+// draining removed ones, which are disconnected once their requests in flight completed or
+// DrainTimeout passed. New endpoints are not used until they are probed. Addresses that fail
+// to connect are kept with the error in EndpointStatus.Err and dialed again on the next
+// Resolve. It only fails when the resolver fails or no endpoint is connected.
+func (p *Pool) Resolve(ctx context.Context) error {
+	addresses, err := p.config.Resolver.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to resolve endpoints: %w", err)
+	}
+	if len(addresses) == 0 {
+		return errors.New("resolver returned no endpoints")
+	}
+
+	p.resolveMu.Lock()
+	defer p.resolveMu.Unlock()
+
+	current := make(map[string]*poolEndpoint)
+	for _, endpoint := range p.snapshot() {
+		current[endpoint.address] = endpoint
+	}
+	var endpoints []*poolEndpoint
+	var errs []error
+	connected := false
+	for _, address := range addresses {
+		if containsEndpoint(endpoints, address) {
+			continue
+		}
+		if endpoint, ok := current[address]; ok && endpoint.client != nil {
+			endpoints = append(endpoints, endpoint)
+			delete(current, address)
+			connected = true
+			continue
+		}
+		// dialing may block, e.g. with grpc.WithBlock, inference keeps using the current endpoints
+		client, err := p.config.Dial(address)
+		if err != nil {
+			err = fmt.Errorf("unable to connect to %s: %w", address, err)
+			errs = append(errs, err)
+			endpoints = append(endpoints, &poolEndpoint{address: address, err: err})
+			continue
+		}
+		client.SetRetryPolicy(p.config.RetryPolicy)
+		endpoints = append(endpoints, &poolEndpoint{address: address, client: client, err: errors.New("not probed yet")})
+		connected = true
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].address < endpoints[j].address })
+
+	p.mu.Lock()
+	p.endpoints = endpoints
+	p.mu.Unlock()
+	for _, removed := range current {
+		if removed.client != nil {
+			p.drains.Add(1)
+			go p.drain(removed)
+		}
+	}
+
+	if !connected {
+		return errors.Join(append(errs, errors.New("no endpoint could be connected"))...)
+	}
+	return nil
+}
+
+// drain disconnects a removed endpoint once it has no request in flight, after DrainTimeout or
+// when the pool is closed.
+func (p *Pool) drain(endpoint *poolEndpoint) {
+	defer p.drains.Done()
+	timeout := time.NewTimer(p.config.DrainTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(poolDrainPoll)
+	defer ticker.Stop()
+
+	// the first poll lets requests that picked the endpoint before its removal count themselves
+	for {
+		select {
+		case <-p.ctx.Done():
+		case <-timeout.C:
+		case <-ticker.C:
+			if endpoint.outstanding.Load() > 0 {
+				continue
+			}
+		}
+		_ = endpoint.client.Disconnect()
+		return
+	}
+}
+
+func containsEndpoint(endpoints []*poolEndpoint, address string) bool {
+	for _, endpoint := range endpoints {
+		if endpoint.address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Probe probes every endpoint concurrently and waits for the results.
+func (p *Pool) Probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, endpoint := range p.snapshot() {
+		if endpoint.client == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(endpoint *poolEndpoint) {
+			defer wg.Done()
+			endpoint.probe(ctx, p.config.ProbeTimeout)
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+// probe checks the server is ready and lists its ready models.
+func (e *poolEndpoint) probe(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var models []*grpc_client.RepositoryIndexResponse_ModelIndex
+	ready, err := e.client.ServerReadyContext(ctx)
+	if err == nil && !ready {
+		err = errors.New("server is not ready")
+	}
+	if err == nil {
+		var index *grpc_client.RepositoryIndexResponse
+		index, err = e.client.ModelRepositoryIndexContext(ctx, "", true)
+		models = index.GetModels()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.healthy = err == nil
+	e.err = err
+	if err == nil {
+		e.failures = 0
+		e.models = models
+	}
+}
+
+// serves reports whether the endpoint is healthy with the model ready, an empty version
+// matches any version.
+func (e *poolEndpoint) serves(modelName, modelVersion string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.healthy {
+		return false
+	}
+	for _, model := range e.models {
+		if model.Name == modelName && (modelVersion == "" || model.Version == modelVersion) && (model.State == "" || model.State == "READY") {
+			return true
+		}
+	}
+	return false
+}
+
+// done records the result of a request sent to the endpoint, ejecting it after too many
+// consecutive Unavailable errors.
+func (e *poolEndpoint) done(err error, maxFailures int) {
+	e.outstanding.Add(-1)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if status.Code(err) != codes.Unavailable {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= maxFailures {
+		e.healthy = false
+		e.err = fmt.Errorf("ejected after %d failures: %w", e.failures, err)
+	}
+}
+
+func (p *Pool) snapshot() []*poolEndpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.endpoints
+}
+
+// pick selects an endpoint serving the model according to the balancing of the pool.
+func (p *Pool) pick(modelName, modelVersion string) (*poolEndpoint, error) {
+	var candidates []*poolEndpoint
+	for _, endpoint := range p.snapshot() {
+		if endpoint.serves(modelName, modelVersion) {
+			candidates = append(candidates, endpoint)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("model %s: %w", modelName, ErrNoReadyEndpoint)
+	}
+
+	start := int(p.next.Add(1) % uint64(len(candidates)))
+	picked := candidates[start]
+	if p.config.Balancing == LeastOutstanding {
+		for idx := 1; idx < len(candidates); idx++ {
+			candidate := candidates[(start+idx)%len(candidates)]
+			if candidate.outstanding.Load() < picked.outstanding.Load() {
+				picked = candidate
+			}
+		}
+	}
+	return picked, nil
+}
+
+// Client returns the client of an endpoint serving the model, e.g. to run a sequence or a
+// stream, which must stay on one replica. Requests sent with it are not tracked by the pool.
+func (p *Pool) Client(modelName, modelVersion string) (*TritonGRPCClient, error) {
+	endpoint, err := p.pick(modelName, modelVersion)
+	if err != nil {
+		return nil, err
+	}
+	return endpoint.client, nil
+}
+
+// ModelInfer sends a fully built request to an endpoint serving its model.
+func (p *Pool) ModelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	endpoint, err := p.pick(modelInferRequest.ModelName, modelInferRequest.ModelVersion)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.outstanding.Add(1)
+	modelInferResponse, err := endpoint.client.ModelInfer(ctx, modelInferRequest)
+	endpoint.done(err, p.config.MaxFailures)
+	return modelInferResponse, err
+}
+
+// Infer runs inference with typed inputs and outputs on an endpoint serving the model, opts
+// set the request ID and parameters.
+func (p *Pool) Infer(ctx context.Context, modelName, modelVersion string, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error) {
+	modelInferRequest, err := NewModelInferRequest(modelName, modelVersion, inputs, outputs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := p.ModelInfer(ctx, modelInferRequest)
+	if err != nil {
+		return nil, err
+	}
+	return newInferResultFor(modelInferRequest, modelInferResponse), nil
+}
+
+// Endpoints returns the state of the endpoints sorted by address.
+func (p *Pool) Endpoints() []EndpointStatus {
+	endpoints := p.snapshot()
+	statuses := make([]EndpointStatus, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint.mu.Lock()
+		statuses = append(statuses, EndpointStatus{
+			Address:     endpoint.address,
+			Healthy:     endpoint.healthy,
+			Models:      endpoint.models,
+			Outstanding: endpoint.outstanding.Load(),
+			Err:         endpoint.err,
+		})
+		endpoint.mu.Unlock()
+	}
+	return statuses
+}
+
+// Close stops probing and disconnects from every endpoint.
+func (p *Pool) Close() error {
+	p.cancel()
+	<-p.done
+	p.drains.Wait()
+	return p.closeEndpoints()
+}
+
+func (p *Pool) closeEndpoints() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for _, endpoint := range p.endpoints {
+		if endpoint.client != nil {
+			errs = append(errs, endpoint.client.Disconnect())
+		}
+	}
+	p.endpoints = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,306 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolvers(t *testing.T) {
+	ctx := context.Background()
+
+	addresses, err := StaticResolver{"a:8001", "b:8001"}.Resolve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:8001", "b:8001"}, addresses)
+
+	path := filepath.Join(t.TempDir(), "endpoints")
+	assert.NoError(t, os.WriteFile(path, []byte("# replicas\na:8001\n\n  b:8001  \n"), 0600))
+	addresses, err = (&FileResolver{Path: path}).Resolve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:8001", "b:8001"}, addresses)
+	_, err = (&FileResolver{Path: filepath.Join(t.TempDir(), "missing")}).Resolve(ctx)
+	assert.Error(t, err)
+
+	addresses, err = (&DNSResolver{Host: "127.0.0.1", Port: "8001"}).Resolve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:8001"}, addresses)
+}
+
+// newTestPool starts a fake server per address serving the "echo" model and returns a pool
+// over the servers the resolver returns.
+func newTestPool(t *testing.T, resolver Resolver, balancing Balancing, addresses ...string) (*Pool, map[string]*tritontest.Server) {
+	servers := make(map[string]*tritontest.Server, len(addresses))
+	for _, address := range addresses {
+		server := tritontest.NewServer()
+		t.Cleanup(server.Close)
+		server.AddModel(&tritontest.Model{
+			Version: "1",
+			Config:  &grpc_client.ModelConfig{Name: "echo"},
+			Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+				return &grpc_client.ModelInferResponse{}, nil
+			},
+		})
+		servers[address] = server
+	}
+
+	pool, err := NewPool(context.Background(), PoolConfig{
+		Resolver: resolver,
+		Dial: func(address string) (*TritonGRPCClient, error) {
+			return NewTritonGRPCClient(tritontest.Target, servers[address].DialOptions())
+		},
+		Balancing:     balancing,
+		ProbeInterval: time.Hour,
+		MaxFailures:   2,
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = pool.Close()
+	})
+	return pool, servers
+}
+
+func inferCounts(servers map[string]*tritontest.Server) map[string]int {
+	counts := make(map[string]int, len(servers))
+	for address, server := range servers {
+		counts[address] = len(server.InferRequests())
+		server.ResetRequests()
+	}
+	return counts
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	ctx := context.Background()
+	pool, servers := newTestPool(t, StaticResolver{"a", "b", "c"}, RoundRobin, "a", "b", "c")
+
+	// the model is not ready on c
+	servers["c"].SetModelReady("echo", false)
+	pool.Probe(ctx)
+	endpoints := pool.Endpoints()
+	assert.Len(t, endpoints, 3)
+	assert.Equal(t, "a", endpoints[0].Address)
+	assert.True(t, endpoints[0].Healthy)
+	assert.Equal(t, "echo", endpoints[0].Models[0].Name)
+	assert.True(t, endpoints[2].Healthy)
+	assert.Empty(t, endpoints[2].Models)
+
+	for i := 0; i < 10; i++ {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"a": 5, "b": 5, "c": 0}, inferCounts(servers))
+
+	// versions must match
+	_, err := pool.Infer(ctx, "echo", "1", nil, nil)
+	assert.NoError(t, err)
+	_, err = pool.Infer(ctx, "echo", "2", nil, nil)
+	assert.ErrorIs(t, err, ErrNoReadyEndpoint)
+	_, err = pool.Client("missing", "")
+	assert.ErrorIs(t, err, ErrNoReadyEndpoint)
+	client, err := pool.Client("echo", "")
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+	inferCounts(servers)
+
+	// a server that is not ready is skipped
+	servers["b"].SetServerReady(false)
+	pool.Probe(ctx)
+	assert.False(t, pool.Endpoints()[1].Healthy)
+	assert.Error(t, pool.Endpoints()[1].Err)
+	for i := 0; i < 4; i++ {
+		_, err = pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"a": 4, "b": 0, "c": 0}, inferCounts(servers))
+}
+
+func TestPool_Ejection(t *testing.T) {
+	ctx := context.Background()
+	pool, servers := newTestPool(t, StaticResolver{"a", "b"}, RoundRobin, "a", "b")
+
+	// a fails until it is ejected after 2 consecutive failures
+	servers["a"].InjectError("ModelInfer", status.Error(codes.Unavailable, "restarting"))
+	var failures int
+	for i := 0; i < 10; i++ {
+		if _, err := pool.Infer(ctx, "echo", "", nil, nil); err != nil {
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+			failures++
+		}
+	}
+	assert.Equal(t, 2, failures)
+	assert.False(t, pool.Endpoints()[0].Healthy)
+	assert.ErrorContains(t, pool.Endpoints()[0].Err, "ejected")
+
+	// other errors do not count
+	servers["b"].InjectError("ModelInfer", status.Error(codes.InvalidArgument, "bad input"))
+	for i := 0; i < 3; i++ {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	assert.True(t, pool.Endpoints()[1].Healthy)
+	servers["b"].InjectError("ModelInfer", nil)
+
+	// a comes back once probed successfully
+	servers["a"].InjectError("ModelInfer", nil)
+	inferCounts(servers)
+	pool.Probe(ctx)
+	assert.True(t, pool.Endpoints()[0].Healthy)
+	for i := 0; i < 4; i++ {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, inferCounts(servers))
+}
+
+func TestPool_LeastOutstanding(t *testing.T) {
+	ctx := context.Background()
+	pool, servers := newTestPool(t, StaticResolver{"a", "b"}, LeastOutstanding, "a", "b")
+
+	servers["a"].InjectLatency("ModelInfer", 200*time.Millisecond)
+	servers["b"].InjectLatency("ModelInfer", 200*time.Millisecond)
+	done := make(chan error)
+	go func() {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool {
+		endpoints := pool.Endpoints()
+		return endpoints[0].Outstanding+endpoints[1].Outstanding == 1
+	}, time.Second, time.Millisecond)
+	busy := 0
+	if pool.Endpoints()[1].Outstanding == 1 {
+		busy = 1
+	}
+	servers["a"].InjectLatency("ModelInfer", 0)
+	servers["b"].InjectLatency("ModelInfer", 0)
+
+	// every request goes to the idle endpoint while the other is busy
+	for i := 0; i < 4; i++ {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, <-done)
+	counts := inferCounts(servers)
+	assert.Equal(t, 1, counts[[]string{"a", "b"}[busy]])
+	assert.Equal(t, 4, counts[[]string{"b", "a"}[busy]])
+	assert.Zero(t, pool.Endpoints()[0].Outstanding+pool.Endpoints()[1].Outstanding)
+}
+
+func TestPool_Resolve(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "endpoints")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n"), 0600))
+	pool, servers := newTestPool(t, &FileResolver{Path: path}, RoundRobin, "a", "b")
+	assert.Len(t, pool.Endpoints(), 1)
+
+	// b is added and only used once probed
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb\n"), 0600))
+	assert.NoError(t, pool.Resolve(ctx))
+	assert.Len(t, pool.Endpoints(), 2)
+	assert.False(t, pool.Endpoints()[1].Healthy)
+	pool.Probe(ctx)
+	for i := 0; i < 4; i++ {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, inferCounts(servers))
+
+	// a is removed
+	assert.NoError(t, os.WriteFile(path, []byte("b\n"), 0600))
+	assert.NoError(t, pool.Resolve(ctx))
+	assert.Len(t, pool.Endpoints(), 1)
+	assert.Equal(t, "b", pool.Endpoints()[0].Address)
+
+	// a failed resolution keeps the endpoints
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+	assert.Error(t, pool.Resolve(ctx))
+	assert.Len(t, pool.Endpoints(), 1)
+
+	_, err := NewPool(ctx, PoolConfig{})
+	assert.Error(t, err)
+}
+
+func TestPool_DialFailures(t *testing.T) {
+	ctx := context.Background()
+	server := tritontest.NewServer()
+	defer server.Close()
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "echo"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+
+	slow := make(chan struct{})
+	dial := func(address string) (*TritonGRPCClient, error) {
+		switch address {
+		case "bad":
+			return nil, errors.New("connection refused")
+		case "slow":
+			<-slow
+		}
+		return NewTritonGRPCClient(tritontest.Target, server.DialOptions())
+	}
+	path := filepath.Join(t.TempDir(), "endpoints")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nbad\n"), 0600))
+
+	// an address failing to connect is reported but does not fail the pool
+	pool, err := NewPool(ctx, PoolConfig{Resolver: &FileResolver{Path: path}, Dial: dial, ProbeInterval: time.Hour})
+	assert.NoError(t, err)
+	defer func() {
+		_ = pool.Close()
+	}()
+	endpoints := pool.Endpoints()
+	assert.Len(t, endpoints, 2)
+	assert.True(t, endpoints[0].Healthy)
+	assert.False(t, endpoints[1].Healthy)
+	assert.ErrorContains(t, endpoints[1].Err, "connection refused")
+	_, err = pool.Infer(ctx, "echo", "", nil, nil)
+	assert.NoError(t, err)
+
+	// inference goes on while an address is being dialed
+	assert.NoError(t, os.WriteFile(path, []byte("a\nslow\n"), 0600))
+	resolved := make(chan error)
+	go func() {
+		resolved <- pool.Resolve(ctx)
+	}()
+	for i := 0; i < 3; i++ {
+		_, err = pool.Infer(ctx, "echo", "", nil, nil)
+		assert.NoError(t, err)
+	}
+	close(slow)
+	assert.NoError(t, <-resolved)
+	assert.Equal(t, "slow", pool.Endpoints()[1].Address)
+
+	// the pool fails when nothing connects
+	assert.NoError(t, os.WriteFile(path, []byte("bad\n"), 0600))
+	_, err = NewPool(ctx, PoolConfig{Resolver: &FileResolver{Path: path}, Dial: dial})
+	assert.ErrorContains(t, err, "no endpoint could be connected")
+}
+
+func TestPool_Drain(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "endpoints")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n"), 0600))
+	pool, servers := newTestPool(t, &FileResolver{Path: path}, RoundRobin, "a", "b")
+
+	// a request in flight on a removed endpoint completes
+	servers["a"].InjectLatency("ModelInfer", 100*time.Millisecond)
+	done := make(chan error)
+	go func() {
+		_, err := pool.Infer(ctx, "echo", "", nil, nil)
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return len(servers["a"].InferRequests()) == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, os.WriteFile(path, []byte("b\n"), 0600))
+	assert.NoError(t, pool.Resolve(ctx))
+	assert.Len(t, pool.Endpoints(), 1)
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,79 @@
+package triton_client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Resolver returns the addresses of the Triton replicas a Pool connects to. It is called again
+// periodically so replicas can be added and removed while the pool runs.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// StaticResolver is a fixed list of addresses.
+type StaticResolver []string
+
+// Resolve returns the addresses.
+func (r StaticResolver) Resolve(context.Context) ([]string, error) {
+	return append([]string(nil), r...), nil
+}
+
+// DNSResolver resolves every address of Host, e.g. a headless Kubernetes service, to a
+// replica listening on Port.
+type DNSResolver struct {
+	Host string
+	Port string
+	// Resolver defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Resolve looks up the addresses of the host.
+func (r *DNSResolver) Resolve(ctx context.Context) ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	hosts, err := resolver.LookupHost(ctx, r.Host)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addresses = append(addresses, net.JoinHostPort(host, r.Port))
+	}
+	return addresses, nil
+}
+
+// FileResolver reads one address per line from a file, ignoring empty lines and lines starting
+// with #. The file is read again on every resolution, so it can be updated in place.
+type FileResolver struct {
+	Path string
+}
+
+// Resolve reads the addresses from the file.
+func (r *FileResolver) Resolve(context.Context) ([]string, error) {
+	file, err := os.Open(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var addresses []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addresses = append(addresses, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read endpoints from %s: %w", r.Path, err)
+	}
+	return addresses, nil
+}
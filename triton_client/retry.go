@@ -0,0 +1,183 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"math/rand"
+	"path"
+	"time"
+)
+
+// RetryPolicy retries gRPC calls failing with a transient error, e.g. while Triton reloads a
+// model or its queue is full. Read-only calls (health, metadata, configuration, statistics,
+// repository index and shared memory status) are retried, inference only when the context is
+// marked with RetryInference. Calls changing the server state and streams are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, < 2 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, each further wait is Multiplier times
+	// longer up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction of it, in [0, 1].
+	Jitter float64
+	// RetryableCodes defaults to Unavailable and ResourceExhausted.
+	RetryableCodes []codes.Code
+	// PerAttemptTimeout bounds each attempt, an attempt running out of it is retried.
+	PerAttemptTimeout time.Duration
+	// Timeout bounds all attempts and waits together, on top of the deadline of the context.
+	Timeout time.Duration
+	// OnRetry is called before waiting to retry a failed attempt, e.g. for logging and metrics.
+	OnRetry func(event RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	// Method is the RPC method, e.g. "ModelInfer".
+	Method string
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+	Err     error
+	// Backoff is the wait before the next attempt.
+	Backoff time.Duration
+}
+
+// DefaultRetryPolicy retries up to 4 times over about 2 seconds.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// retryableMethods are the RPC methods retried without being asked to, they do not change the
+// server state.
+var retryableMethods = map[string]bool{
+	"ServerLive":               true,
+	"ServerReady":              true,
+	"ModelReady":               true,
+	"ServerMetadata":           true,
+	"ModelMetadata":            true,
+	"ModelConfig":              true,
+	"ModelStatistics":          true,
+	"RepositoryIndex":          true,
+	"SystemSharedMemoryStatus": true,
+	"CudaSharedMemoryStatus":   true,
+}
+
+type retryInferenceKey struct{}
+
+// RetryInference marks ctx so inference calls made with it are retried by the retry policy of
+// the client. Only use it for requests that are safe to run twice, a request whose response
+// was lost may have been executed.
+func RetryInference(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryInferenceKey{}, true)
+}
+
+// SetRetryPolicy sets the retry policy of the client, nil disables retries.
+func (tc *TritonGRPCClient) SetRetryPolicy(policy *RetryPolicy) {
+	tc.retryPolicy.Store(policy)
+}
+
+// retryInterceptor retries the unary calls of the client according to its retry policy.
+func (tc *TritonGRPCClient) retryInterceptor(ctx context.Context, fullMethod string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	policy := tc.retryPolicy.Load()
+	method := path.Base(fullMethod)
+	if policy == nil || policy.MaxAttempts < 2 || !(retryableMethods[method] || method == "ModelInfer" && ctx.Value(retryInferenceKey{}) != nil) {
+		return invoker(ctx, fullMethod, req, reply, cc, opts...)
+	}
+	return policy.do(ctx, method, func(ctx context.Context) error {
+		return invoker(ctx, fullMethod, req, reply, cc, opts...)
+	})
+}
+
+// do calls attempt until it succeeds, fails with an error that is not retryable, the attempts
+// are exhausted or ctx ends, and returns the error of the last attempt.
+func (p *RetryPolicy) do(ctx context.Context, method string, attempt func(ctx context.Context) error) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	for n := 1; ; n++ {
+		err := p.attempt(ctx, attempt)
+		if err == nil || n >= p.MaxAttempts || ctx.Err() != nil || !p.retryable(err) {
+			return err
+		}
+
+		backoff := p.backoff(n)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(RetryEvent{Method: method, Attempt: n, Err: err, Backoff: backoff})
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt runs a single attempt within the per-attempt timeout.
+func (p *RetryPolicy) attempt(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if p.PerAttemptTimeout <= 0 {
+		return attempt(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, p.PerAttemptTimeout)
+	defer cancel()
+
+	err := attempt(attemptCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return status.Errorf(codes.DeadlineExceeded, "attempt timed out after %s: %v", p.PerAttemptTimeout, err)
+	}
+	return err
+}
+
+// retryable reports whether a failed attempt may be retried. Attempts running out of the
+// per-attempt timeout are, while the overall deadline has not passed.
+func (p *RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	if code == codes.DeadlineExceeded && p.PerAttemptTimeout > 0 {
+		return true
+	}
+	if len(p.RetryableCodes) == 0 {
+		return code == codes.Unavailable || code == codes.ResourceExhausted
+	}
+	for _, retryable := range p.RetryableCodes {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the failed attempt n, with jitter.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(n-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		backoff *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,188 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// busyErrors returns n errors with code, to fail the next n calls of a method.
+func busyErrors(code codes.Code, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = status.Error(code, "server is busy")
+	}
+	return errs
+}
+
+// newRetryTestClient starts a fake server serving the model "model" and returns a client
+// connected to it with the retry policy.
+func newRetryTestClient(t *testing.T, policy *RetryPolicy) (*tritontest.Server, *TritonGRPCClient) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "model"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	client.SetRetryPolicy(policy)
+	return server, client
+}
+
+func testRetryPolicy(events *[]RetryEvent) *RetryPolicy {
+	var mu sync.Mutex
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		OnRetry: func(event RetryEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			*events = append(*events, event)
+		},
+	}
+}
+
+func TestTritonGRPCClient_Retry(t *testing.T) {
+	ctx := context.Background()
+
+	// without a policy the first failure is returned
+	server, client := newRetryTestClient(t, nil)
+	server.InjectErrors("ServerReady", busyErrors(codes.Unavailable, 2)...)
+	_, err := client.ServerReadyContext(ctx)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	var events []RetryEvent
+	server, client = newRetryTestClient(t, testRetryPolicy(&events))
+	server.InjectErrors("ServerReady", busyErrors(codes.Unavailable, 2)...)
+	ready, err := client.ServerReadyContext(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	assert.Len(t, server.Requests("ServerReady"), 3)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "ServerReady", events[0].Method)
+	assert.Equal(t, 1, events[0].Attempt)
+	assert.Equal(t, codes.Unavailable, status.Code(events[0].Err))
+	assert.Equal(t, 2, events[1].Attempt)
+
+	// attempts are exhausted
+	events = nil
+	server, client = newRetryTestClient(t, testRetryPolicy(&events))
+	server.InjectErrors("ServerReady", busyErrors(codes.ResourceExhausted, 5)...)
+	_, err = client.ServerReadyContext(ctx)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Len(t, server.Requests("ServerReady"), 3)
+	assert.Len(t, events, 2)
+
+	// other codes are not retried
+	server, client = newRetryTestClient(t, DefaultRetryPolicy())
+	server.InjectErrors("ServerReady", busyErrors(codes.InvalidArgument, 1)...)
+	_, err = client.ServerReadyContext(ctx)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Len(t, server.Requests("ServerReady"), 1)
+
+	// unless they are configured
+	policy := testRetryPolicy(&events)
+	policy.RetryableCodes = []codes.Code{codes.InvalidArgument}
+	server, client = newRetryTestClient(t, policy)
+	server.InjectErrors("ServerReady", busyErrors(codes.InvalidArgument, 1)...)
+	_, err = client.ServerReadyContext(ctx)
+	assert.NoError(t, err)
+
+	// calls changing the server state are never retried
+	server, client = newRetryTestClient(t, testRetryPolicy(&events))
+	server.InjectErrors("RepositoryModelLoad", busyErrors(codes.Unavailable, 1)...)
+	assert.Error(t, client.ModelLoadContext(ctx, "", "model", nil))
+	assert.Len(t, server.Requests("RepositoryModelLoad"), 1)
+}
+
+func TestTritonGRPCClient_RetryInference(t *testing.T) {
+	ctx := context.Background()
+	var events []RetryEvent
+	server, client := newRetryTestClient(t, testRetryPolicy(&events))
+
+	// inference is retried only when asked to
+	server.InjectErrors("ModelInfer", busyErrors(codes.Unavailable, 1)...)
+	_, err := client.ModelInfer(ctx, &grpc_client.ModelInferRequest{ModelName: "model"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Empty(t, events)
+
+	server.ResetRequests()
+	server.InjectErrors("ModelInfer", busyErrors(codes.Unavailable, 1)...)
+	response, err := client.ModelGRPCInferContext(RetryInference(ctx), nil, nil, nil, "model", "", WithRequestID("42"))
+	assert.NoError(t, err)
+	assert.Equal(t, "42", response.Id)
+	assert.Len(t, server.InferRequests(), 2)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "ModelInfer", events[0].Method)
+}
+
+func TestTritonGRPCClient_RetryDeadlines(t *testing.T) {
+	ctx := context.Background()
+
+	// a slow attempt is cut short and retried
+	var events []RetryEvent
+	policy := testRetryPolicy(&events)
+	policy.PerAttemptTimeout = 20 * time.Millisecond
+	server, client := newRetryTestClient(t, policy)
+	var calls atomic.Int32
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "slow"},
+		Infer: func(ctx context.Context, _ *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	})
+	start := time.Now()
+	_, err := client.Infer(RetryInference(ctx), "slow", "", nil, nil)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Len(t, events, 1)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(events[0].Err))
+
+	// the overall timeout bounds all attempts
+	policy = &RetryPolicy{MaxAttempts: 100, InitialBackoff: 5 * time.Millisecond, Timeout: 50 * time.Millisecond}
+	server, client = newRetryTestClient(t, policy)
+	server.InjectError("ServerReady", status.Error(codes.Unavailable, "server is busy"))
+	start = time.Now()
+	_, err = client.ServerReadyContext(ctx)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Less(t, len(server.Requests("ServerReady")), 100)
+
+	// and so does the context, while waiting to retry
+	policy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	server, client = newRetryTestClient(t, policy)
+	server.InjectErrors("ServerReady", busyErrors(codes.Unavailable, 1)...)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = client.ServerReadyContext(cancelCtx)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, server.Requests("ServerReady"), 1)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(4))
+
+	policy.Multiplier = 3
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(2)
+		assert.GreaterOrEqual(t, backoff, 15*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 45*time.Millisecond)
+	}
+}
@@ -0,0 +1,341 @@
+package triton_client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Request parameters of the sequence batcher.
+const (
+	sequenceIDParam    = "sequence_id"
+	sequenceStartParam = "sequence_start"
+	sequenceEndParam   = "sequence_end"
+)
+
+// ErrSequenceClosed is returned when sending on a sequence that has ended.
+var ErrSequenceClosed = errors.New("sequence is closed")
+
+// SequenceID identifies a sequence of a stateful model, either by number or by string
+// depending on the control input type of the model's sequence batcher.
+type SequenceID struct {
+	num uint64
+	str string
+}
+
+// NumericSequenceID returns a numeric sequence ID, 0 is not a valid ID. Triton takes numeric
+// IDs as int64 parameters, IDs above math.MaxInt64 are sent as their two's complement.
+func NumericSequenceID(id uint64) SequenceID {
+	return SequenceID{num: id}
+}
+
+// StringSequenceID returns a string sequence ID, the empty string is not a valid ID.
+func StringSequenceID(id string) SequenceID {
+	return SequenceID{str: id}
+}
+
+// nextSequenceID is the last allocated numeric ID. It starts at a random value so several
+// processes sharing a server are unlikely to allocate the same IDs, allocated IDs are kept
+// within the positive int64 range.
+var nextSequenceID atomic.Uint64
+
+func init() {
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err == nil {
+		nextSequenceID.Store(binary.LittleEndian.Uint64(seed[:]) >> 1)
+	}
+}
+
+// NewSequenceID allocates a numeric sequence ID unique within the process, between 1 and
+// math.MaxInt64.
+func NewSequenceID() SequenceID {
+	for {
+		if id := nextSequenceID.Add(1) & math.MaxInt64; id != 0 {
+			return NumericSequenceID(id)
+		}
+	}
+}
+
+// NewStringSequenceID allocates a random string sequence ID.
+func NewStringSequenceID() SequenceID {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return StringSequenceID(NewSequenceID().String())
+	}
+	return StringSequenceID(hex.EncodeToString(id[:]))
+}
+
+// IsZero reports whether the ID is unset.
+func (id SequenceID) IsZero() bool {
+	return id.num == 0 && id.str == ""
+}
+
+func (id SequenceID) String() string {
+	if id.str != "" {
+		return id.str
+	}
+	return strconv.FormatUint(id.num, 10)
+}
+
+func (id SequenceID) parameter() *grpc_client.InferParameter {
+	if id.str != "" {
+		return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_StringParam{StringParam: id.str}}
+	}
+	return &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Int64Param{Int64Param: int64(id.num)}}
+}
+
+// SequenceSession sends the requests of one sequence of a stateful model. The first request
+// is flagged as the sequence start, the last one as the sequence end with EndNext, End,
+// SendEnd or on Close, and requests are sent one at a time in the order they are made, as the
+// sequence batcher requires. A session runs
+// either over unary inference, see NewSequenceSession, or over an InferStream, see
+// NewStreamSequenceSession.
+type SequenceSession struct {
+	client       InferenceClient
+	stream       *InferStream
+	modelName    string
+	modelVersion string
+	id           SequenceID
+
+	mu      sync.Mutex
+	started bool
+	endNext bool
+	closed  bool
+	// last is the last request sent, Close repeats its inputs to end the sequence
+	last *grpc_client.ModelInferRequest
+}
+
+// NewSequenceSession starts a sequence over unary inference. A zero id allocates a numeric ID.
+func NewSequenceSession(client InferenceClient, modelName, modelVersion string, id SequenceID) *SequenceSession {
+	if id.IsZero() {
+		id = NewSequenceID()
+	}
+	return &SequenceSession{client: client, modelName: modelName, modelVersion: modelVersion, id: id}
+}
+
+// NewStreamSequenceSession starts a sequence over stream. Responses are delivered to the
+// stream callback. A zero id allocates a numeric ID.
+func NewStreamSequenceSession(stream *InferStream, modelName, modelVersion string, id SequenceID) *SequenceSession {
+	if id.IsZero() {
+		id = NewSequenceID()
+	}
+	return &SequenceSession{stream: stream, modelName: modelName, modelVersion: modelVersion, id: id}
+}
+
+// ID returns the sequence ID.
+func (s *SequenceSession) ID() SequenceID {
+	return s.id
+}
+
+// EndNext flags the next request as the end of the sequence, the session is closed once it
+// has been sent.
+func (s *SequenceSession) EndNext() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endNext = true
+}
+
+// Infer runs inference with typed inputs and outputs as the next request of the sequence,
+// opts set the request ID and parameters.
+func (s *SequenceSession) Infer(ctx context.Context, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error) {
+	return s.infer(ctx, false, inputs, outputs, opts)
+}
+
+// End runs inference with typed inputs and outputs as the last request of the sequence and
+// closes the session.
+func (s *SequenceSession) End(ctx context.Context, inputs []*InferInput, outputs []*InferRequestedOutput, opts ...InferOption) (*InferResult, error) {
+	return s.infer(ctx, true, inputs, outputs, opts)
+}
+
+func (s *SequenceSession) infer(ctx context.Context, end bool, inputs []*InferInput, outputs []*InferRequestedOutput, opts []InferOption) (*InferResult, error) {
+	modelInferRequest, err := NewModelInferRequest(s.modelName, s.modelVersion, inputs, outputs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := s.modelInfer(ctx, modelInferRequest, end)
+	if err != nil {
+		return nil, err
+	}
+	return newInferResultFor(modelInferRequest, modelInferResponse), nil
+}
+
+// ModelInfer sends a fully built request as the next request of the sequence over unary
+// inference. It is sent with the model name, version and sequence parameters of the session,
+// the request itself is not modified.
+func (s *SequenceSession) ModelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	return s.modelInfer(ctx, modelInferRequest, false)
+}
+
+func (s *SequenceSession) modelInfer(ctx context.Context, modelInferRequest *grpc_client.ModelInferRequest, end bool) (*grpc_client.ModelInferResponse, error) {
+	if s.client == nil {
+		return nil, errors.New("sequence session runs over a stream, use Send")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequenceRequest, err := s.prepare(modelInferRequest, end)
+	if err != nil {
+		return nil, err
+	}
+	modelInferResponse, err := s.client.ModelInfer(ctx, sequenceRequest)
+	if err != nil {
+		return nil, err
+	}
+	s.sent(sequenceRequest, end)
+	return modelInferResponse, nil
+}
+
+// Send sends a fully built request as the next request of the sequence over the stream and
+// returns its ID. It is sent with the model name, version and sequence parameters of the
+// session, the request itself is not modified.
+func (s *SequenceSession) Send(modelInferRequest *grpc_client.ModelInferRequest) (string, error) {
+	return s.send(modelInferRequest, false)
+}
+
+// SendEnd sends a fully built request as the last request of the sequence over the stream,
+// closes the session and returns the ID of the request.
+func (s *SequenceSession) SendEnd(modelInferRequest *grpc_client.ModelInferRequest) (string, error) {
+	return s.send(modelInferRequest, true)
+}
+
+func (s *SequenceSession) send(modelInferRequest *grpc_client.ModelInferRequest, end bool) (string, error) {
+	if s.stream == nil {
+		return "", errors.New("sequence session runs over unary inference, use ModelInfer")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequenceRequest, err := s.prepare(modelInferRequest, end)
+	if err != nil {
+		return "", err
+	}
+	requestID, err := s.stream.Send(sequenceRequest)
+	if err != nil {
+		return "", err
+	}
+	s.sent(sequenceRequest, end)
+	return requestID, nil
+}
+
+// Close closes the session. Triton only releases a sequence on a request flagged as its end,
+// so closing a sequence whose last request was not flagged with EndNext, End or SendEnd sends
+// one more request with sequence_end set, over unary inference or the stream the session
+// runs on. It repeats the inputs of the last request with zeroed data, or the same shared
+// memory regions, and its response is discarded, over a stream it is delivered to the
+// callback. Closing a sequence that never started or has already ended does nothing.
+func (s *SequenceSession) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || !s.started {
+		s.closed = true
+		return nil
+	}
+	endRequest, err := sequenceEndRequest(s.last)
+	if err != nil {
+		return fmt.Errorf("sequence %s: %w", s.id, err)
+	}
+	endRequest, err = s.prepare(endRequest, true)
+	if err != nil {
+		return err
+	}
+	if s.stream != nil {
+		_, err = s.stream.Send(endRequest)
+	} else {
+		_, err = s.client.ModelInfer(ctx, endRequest)
+	}
+	if err != nil {
+		return err
+	}
+	s.sent(endRequest, true)
+	return nil
+}
+
+// sequenceEndRequest returns a request with the inputs of the last request of a sequence and
+// zeroed data, to end the sequence.
+func sequenceEndRequest(last *grpc_client.ModelInferRequest) (*grpc_client.ModelInferRequest, error) {
+	endRequest := &grpc_client.ModelInferRequest{
+		Parameters: last.Parameters,
+		Outputs:    last.Outputs,
+	}
+	rawIdx := 0
+	for _, input := range last.Inputs {
+		endRequest.Inputs = append(endRequest.Inputs, &grpc_client.ModelInferRequest_InferInputTensor{
+			Name:       input.Name,
+			Datatype:   input.Datatype,
+			Shape:      input.Shape,
+			Parameters: input.Parameters,
+		})
+		if usesSharedMemory(input.Parameters) {
+			continue
+		}
+
+		var raw []byte
+		if rawIdx < len(last.RawInputContents) {
+			raw = last.RawInputContents[rawIdx]
+			rawIdx++
+		} else if input.Contents != nil {
+			var err error
+			if raw, err = contentsToRaw(input.Datatype, input.Contents); err != nil {
+				return nil, fmt.Errorf("input %s: %w", input.Name, err)
+			}
+		}
+		size := len(raw)
+		if input.Datatype == DataTypeBytes {
+			// zeroing the last data would not be a valid encoding, send empty elements instead
+			count, err := shapeElementCount(input.Shape)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", input.Name, err)
+			}
+			size = 4 * count
+		}
+		endRequest.RawInputContents = append(endRequest.RawInputContents, make([]byte, size))
+	}
+	return endRequest, nil
+}
+
+// prepare returns a shallow copy of the next request with the model and the sequence
+// parameters of the session, the request itself is left untouched so it can be reused as a
+// template. s.mu must be held.
+func (s *SequenceSession) prepare(modelInferRequest *grpc_client.ModelInferRequest, end bool) (*grpc_client.ModelInferRequest, error) {
+	if s.closed {
+		return nil, ErrSequenceClosed
+	}
+
+	parameters := make(map[string]*grpc_client.InferParameter, len(modelInferRequest.Parameters)+3)
+	for name, parameter := range modelInferRequest.Parameters {
+		parameters[name] = parameter
+	}
+	parameters[sequenceIDParam] = s.id.parameter()
+	parameters[sequenceStartParam] = boolParameter(!s.started)
+	parameters[sequenceEndParam] = boolParameter(end || s.endNext)
+	return &grpc_client.ModelInferRequest{
+		ModelName:        s.modelName,
+		ModelVersion:     s.modelVersion,
+		Id:               modelInferRequest.Id,
+		Parameters:       parameters,
+		Inputs:           modelInferRequest.Inputs,
+		Outputs:          modelInferRequest.Outputs,
+		RawInputContents: modelInferRequest.RawInputContents,
+	}, nil
+}
+
+// sent records a request that has been sent successfully, s.mu must be held.
+func (s *SequenceSession) sent(modelInferRequest *grpc_client.ModelInferRequest, end bool) {
+	s.started = true
+	s.last = modelInferRequest
+	if end || s.endNext {
+		s.closed = true
+		s.last = nil
+	}
+}
@@ -0,0 +1,201 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sequenceModel is a stateful model that records the highest number of its requests in flight.
+func sequenceModel(inFlight, maxInFlight *atomic.Int32) *tritontest.Model {
+	return &tritontest.Model{
+		Config: &grpc_client.ModelConfig{
+			Name:             "tracker",
+			MaxBatchSize:     1,
+			Input:            []*grpc_client.ModelInput{{Name: "frame", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1}}},
+			SchedulingChoice: &grpc_client.ModelConfig_SequenceBatching{SequenceBatching: &grpc_client.ModelSequenceBatching{}},
+		},
+		Infer: func(_ context.Context, _ *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				highest := maxInFlight.Load()
+				if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return &grpc_client.ModelInferResponse{}, nil
+		},
+	}
+}
+
+func frameInput(t *testing.T, value float32) []*InferInput {
+	input := NewInferInput("frame", []int64{1, 1}, DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{value}))
+	return []*InferInput{input}
+}
+
+// sequenceFlags returns the sequence ID, start and end parameters of the requests.
+func sequenceFlags(requests []*grpc_client.ModelInferRequest) (ids []string, starts, ends []bool) {
+	for _, req := range requests {
+		id := req.Parameters[sequenceIDParam]
+		if id.GetStringParam() != "" {
+			ids = append(ids, id.GetStringParam())
+		} else {
+			ids = append(ids, NumericSequenceID(uint64(id.GetInt64Param())).String())
+		}
+		starts = append(starts, req.Parameters[sequenceStartParam].GetBoolParam())
+		ends = append(ends, req.Parameters[sequenceEndParam].GetBoolParam())
+	}
+	return ids, starts, ends
+}
+
+func TestSequenceSession_Unary(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	var inFlight, maxInFlight atomic.Int32
+	server.AddModel(sequenceModel(&inFlight, &maxInFlight))
+	ctx := context.Background()
+
+	session := NewSequenceSession(client, "tracker", "", NumericSequenceID(42))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := session.Infer(ctx, frameInput(t, float32(i)), nil)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), maxInFlight.Load())
+
+	_, err := session.End(ctx, frameInput(t, 4), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, session.Close(ctx))
+	assert.NoError(t, session.Close(ctx))
+	_, err = session.Infer(ctx, frameInput(t, 5), nil)
+	assert.ErrorIs(t, err, ErrSequenceClosed)
+
+	ids, starts, ends := sequenceFlags(server.InferRequests())
+	assert.Equal(t, []string{"42", "42", "42", "42", "42"}, ids)
+	assert.Equal(t, []bool{true, false, false, false, false}, starts)
+	assert.Equal(t, []bool{false, false, false, false, true}, ends)
+}
+
+func TestSequenceSession_EndNext(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	var inFlight, maxInFlight atomic.Int32
+	server.AddModel(sequenceModel(&inFlight, &maxInFlight))
+	ctx := context.Background()
+
+	session := NewSequenceSession(client, "tracker", "", StringSequenceID("camera-1"))
+	session.EndNext()
+	_, err := session.Infer(ctx, frameInput(t, 1), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, session.Close(ctx))
+
+	ids, starts, ends := sequenceFlags(server.InferRequests())
+	assert.Equal(t, []string{"camera-1"}, ids)
+	assert.Equal(t, []bool{true}, starts)
+	assert.Equal(t, []bool{true}, ends)
+
+	// a sequence that never started sends nothing on Close
+	server.ResetRequests()
+	assert.NoError(t, NewSequenceSession(client, "tracker", "", SequenceID{}).Close(ctx))
+	assert.Empty(t, server.InferRequests())
+
+	// closing a sequence that was never ended sends its end with the last inputs zeroed
+	session = NewSequenceSession(client, "tracker", "", StringSequenceID("camera-2"))
+	_, err = session.Infer(ctx, frameInput(t, 1), nil)
+	assert.NoError(t, err)
+	server.ResetRequests()
+	assert.NoError(t, session.Close(ctx))
+	assert.NoError(t, session.Close(ctx))
+	requests := server.InferRequests()
+	ids, starts, ends = sequenceFlags(requests)
+	assert.Equal(t, []string{"camera-2"}, ids)
+	assert.Equal(t, []bool{false}, starts)
+	assert.Equal(t, []bool{true}, ends)
+	assert.Equal(t, "frame", requests[0].Inputs[0].Name)
+	assert.Equal(t, []int64{1, 1}, requests[0].Inputs[0].Shape)
+	assert.Equal(t, [][]byte{make([]byte, 4)}, requests[0].RawInputContents)
+	_, err = session.Infer(ctx, frameInput(t, 2), nil)
+	assert.ErrorIs(t, err, ErrSequenceClosed)
+}
+
+func TestSequenceSession_Stream(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	var inFlight, maxInFlight atomic.Int32
+	server.AddModel(sequenceModel(&inFlight, &maxInFlight))
+
+	var mu sync.Mutex
+	var results []*StreamResult
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *StreamResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	})
+	assert.NoError(t, err)
+
+	session := NewStreamSequenceSession(stream, "tracker", "", SequenceID{})
+	assert.False(t, session.ID().IsZero())
+	// the same request is reused as a template and left untouched
+	req, err := NewModelInferRequest("", "", frameInput(t, 1), nil, WithPriority(1))
+	assert.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err = session.Send(req)
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, req.ModelName)
+	assert.Len(t, req.Parameters, 1)
+	assert.NotContains(t, req.Parameters, sequenceIDParam)
+	_, err = session.ModelInfer(context.Background(), &grpc_client.ModelInferRequest{})
+	assert.Error(t, err)
+	// the sequence is ended over the stream on Close
+	assert.NoError(t, session.Close(context.Background()))
+
+	// a sequence ended with SendEnd sends nothing more on Close
+	ended := NewStreamSequenceSession(stream, "tracker", "", StringSequenceID("camera-1"))
+	_, err = ended.SendEnd(req)
+	assert.NoError(t, err)
+	assert.NoError(t, ended.Close(context.Background()))
+	assert.NoError(t, stream.Close())
+
+	assert.Len(t, results, 6)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	ids, starts, ends := sequenceFlags(server.InferRequests())
+	id := session.ID().String()
+	assert.Equal(t, []string{id, id, id, id, id, "camera-1"}, ids)
+	assert.Equal(t, []bool{true, false, false, false, false, true}, starts)
+	assert.Equal(t, []bool{false, false, false, false, true, true}, ends)
+}
+
+func TestNewSequenceID(t *testing.T) {
+	id := NewSequenceID()
+	assert.False(t, id.IsZero())
+	assert.NotEqual(t, id, NewSequenceID())
+	assert.Greater(t, id.parameter().GetInt64Param(), int64(0))
+
+	// allocated IDs stay positive int64 values
+	last := nextSequenceID.Load()
+	defer nextSequenceID.Store(last)
+	nextSequenceID.Store(math.MaxInt64 - 1)
+	assert.Equal(t, NumericSequenceID(math.MaxInt64), NewSequenceID())
+	assert.Equal(t, NumericSequenceID(1), NewSequenceID())
+	assert.Equal(t, &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_Int64Param{Int64Param: 42}}, NumericSequenceID(42).parameter())
+	assert.Equal(t, "camera-1", StringSequenceID("camera-1").parameter().GetStringParam())
+
+	str := NewStringSequenceID()
+	assert.Len(t, str.String(), 32)
+	assert.Equal(t, "camera-1", StringSequenceID("camera-1").String())
+	assert.True(t, SequenceID{}.IsZero())
+}
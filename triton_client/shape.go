@@ -0,0 +1,73 @@
+package triton_client
+
+import (
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+)
+
+// ConfigShape returns the full shape of a tensor with the given config dims. The dims of a
+// batching model (max_batch_size > 0) leave out the batch dimension, it is prepended here as
+// a wildcard.
+func ConfigShape(config *grpc_client.ModelConfig, dims []int64) []int64 {
+	if config.MaxBatchSize > 0 {
+		return append([]int64{model_config.WildcardDim}, dims...)
+	}
+	return append([]int64(nil), dims...)
+}
+
+// ResolveShape resolves the config dims of a tensor against the shape of the actual data and
+// returns the shape to send or decode with. For batching models the batch dimension is
+// prepended, with a batch size of 1 when dataShape leaves it out, and must be within
+// [1, max_batch_size]. Wildcard dimensions take their size from dataShape, every other
+// dimension must match.
+func ResolveShape(config *grpc_client.ModelConfig, dims, dataShape []int64) ([]int64, error) {
+	expected := ConfigShape(config, dims)
+	shape := append([]int64(nil), dataShape...)
+	if config.MaxBatchSize > 0 && len(shape) == len(dims) {
+		shape = append([]int64{1}, shape...)
+	}
+
+	for _, dim := range shape {
+		if dim < 0 {
+			return nil, fmt.Errorf("shape %s must not contain wildcard or negative dimensions", model_config.DimsListToString(dataShape))
+		}
+	}
+	if !model_config.CompareDimsWithWildcard(expected, shape) {
+		return nil, fmt.Errorf("shape %s does not match %s", model_config.DimsListToString(dataShape), model_config.DimsListToString(expected))
+	}
+	if config.MaxBatchSize > 0 && (shape[0] < 1 || shape[0] > int64(config.MaxBatchSize)) {
+		return nil, fmt.Errorf("batch size %d is outside [1, %d]", shape[0], config.MaxBatchSize)
+	}
+	return shape, nil
+}
+
+// ResolveInputShape resolves the shape of the named model input from the shape of its data,
+// see ResolveShape.
+func ResolveInputShape(config *grpc_client.ModelConfig, name string, dataShape []int64) ([]int64, error) {
+	for _, input := range config.Input {
+		if input.Name == name {
+			shape, err := ResolveShape(config, input.Dims, dataShape)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", name, err)
+			}
+			return shape, nil
+		}
+	}
+	return nil, fmt.Errorf("model %s has no input %s", config.Name, name)
+}
+
+// ResolveOutputShape checks the shape of the named model output, as returned by the server,
+// against the config and returns it with the batch dimension, see ResolveShape.
+func ResolveOutputShape(config *grpc_client.ModelConfig, name string, shape []int64) ([]int64, error) {
+	for _, output := range config.Output {
+		if output.Name == name {
+			resolved, err := ResolveShape(config, output.Dims, shape)
+			if err != nil {
+				return nil, fmt.Errorf("output %s: %w", name, err)
+			}
+			return resolved, nil
+		}
+	}
+	return nil, fmt.Errorf("model %s has no output %s", config.Name, name)
+}
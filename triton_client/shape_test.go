@@ -0,0 +1,66 @@
+package triton_client
+
+import (
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestResolveShape(t *testing.T) {
+	batching := &grpc_client.ModelConfig{Name: "face", MaxBatchSize: 4}
+	fixed := &grpc_client.ModelConfig{Name: "face"}
+
+	tests := []struct {
+		name      string
+		config    *grpc_client.ModelConfig
+		dims      []int64
+		dataShape []int64
+		shape     []int64
+		wantErr   bool
+	}{
+		{"batch dimension is prepended", batching, []int64{3, 2}, []int64{3, 2}, []int64{1, 3, 2}, false},
+		{"batch dimension is kept", batching, []int64{3, 2}, []int64{4, 3, 2}, []int64{4, 3, 2}, false},
+		{"batch size above max", batching, []int64{3, 2}, []int64{5, 3, 2}, nil, true},
+		{"batch size 0", batching, []int64{3, 2}, []int64{0, 3, 2}, nil, true},
+		{"wildcards are resolved", batching, []int64{3, -1, -1}, []int64{2, 3, 8, 6}, []int64{2, 3, 8, 6}, false},
+		{"no batching", fixed, []int64{1, 3, 2}, []int64{1, 3, 2}, []int64{1, 3, 2}, false},
+		{"no batching does not prepend", fixed, []int64{3, 2}, []int64{1, 3, 2}, nil, true},
+		{"dimension mismatch", fixed, []int64{3, 2}, []int64{3, 4}, nil, true},
+		{"rank mismatch", batching, []int64{3, 2}, []int64{1, 1, 3, 2}, nil, true},
+		{"wildcard data", fixed, []int64{3, -1}, []int64{3, -1}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, err := ResolveShape(tt.config, tt.dims, tt.dataShape)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.shape, shape)
+		})
+	}
+}
+
+func TestResolveInputOutputShape(t *testing.T) {
+	config := &grpc_client.ModelConfig{
+		Name:         "double",
+		MaxBatchSize: 4,
+		Input:        []*grpc_client.ModelInput{{Name: "x", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{-1}}},
+		Output:       []*grpc_client.ModelOutput{{Name: "y", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{-1}}},
+	}
+	assert.Equal(t, []int64{-1, -1}, ConfigShape(config, config.Input[0].Dims))
+
+	shape, err := ResolveInputShape(config, "x", []int64{5})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 5}, shape)
+	_, err = ResolveInputShape(config, "z", []int64{5})
+	assert.EqualError(t, err, "model double has no input z")
+
+	shape, err = ResolveOutputShape(config, "y", []int64{2, 5})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 5}, shape)
+	_, err = ResolveOutputShape(config, "y", []int64{2, 5, 1})
+	assert.EqualError(t, err, "output y: shape [2,5,1] does not match [-1,-1]")
+}
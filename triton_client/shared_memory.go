@@ -0,0 +1,95 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+)
+
+// Parameters placing an input or output in a registered shared memory region.
+const (
+	sharedMemoryRegionParam   = "shared_memory_region"
+	sharedMemoryByteSizeParam = "shared_memory_byte_size"
+	sharedMemoryOffsetParam   = "shared_memory_offset"
+)
+
+// SystemSharedMemoryClient registers system shared memory regions with Triton and lists the
+// registered ones. It is implemented by both TritonGRPCClient and TritonHTTPClientService.
+type SystemSharedMemoryClient interface {
+	SystemSharedMemoryStatusContext(ctx context.Context, regionName string) (*grpc_client.SystemSharedMemoryStatusResponse, error)
+	RegisterSystemSharedMemoryContext(ctx context.Context, regionName, key string, byteSize, offset uint64) error
+	UnregisterSystemSharedMemoryContext(ctx context.Context, regionName string) error
+}
+
+var (
+	_ SystemSharedMemoryClient = (*TritonGRPCClient)(nil)
+	_ SystemSharedMemoryClient = (*TritonHTTPClientService)(nil)
+)
+
+// SetSharedMemory reads the input from byteSize bytes at offset of the registered shared
+// memory region instead of sending its data with the request. Data set before is dropped.
+func (i *InferInput) SetSharedMemory(regionName string, byteSize, offset uint64) {
+	i.raw = nil
+	i.parameters = setSharedMemoryParameters(i.parameters, regionName, byteSize, offset)
+}
+
+// SetSharedMemory has Triton write the output to byteSize bytes at offset of the registered
+// shared memory region instead of returning it in the response.
+func (o *InferRequestedOutput) SetSharedMemory(regionName string, byteSize, offset uint64) {
+	o.parameters = setSharedMemoryParameters(o.parameters, regionName, byteSize, offset)
+}
+
+// SharedMemory returns the shared memory region the output was written to, with the byte size
+// and offset of the output in the region. ok is false when the output is part of the response.
+func (r *InferResult) SharedMemory(name string) (regionName string, byteSize, offset uint64, ok bool) {
+	output, err := r.Output(name)
+	if err != nil {
+		return "", 0, 0, false
+	}
+	return sharedMemoryParameters(output.Parameters)
+}
+
+func setSharedMemoryParameters(parameters map[string]*grpc_client.InferParameter, regionName string, byteSize, offset uint64) map[string]*grpc_client.InferParameter {
+	parameters = setInferParameter(parameters, sharedMemoryRegionParam, &grpc_client.InferParameter{ParameterChoice: &grpc_client.InferParameter_StringParam{StringParam: regionName}})
+	parameters = setInferParameter(parameters, sharedMemoryByteSizeParam, int64Parameter(int64(byteSize)))
+	return setInferParameter(parameters, sharedMemoryOffsetParam, int64Parameter(int64(offset)))
+}
+
+// sharedMemoryParameters returns the shared memory region parameters of a tensor.
+func sharedMemoryParameters(parameters map[string]*grpc_client.InferParameter) (regionName string, byteSize, offset uint64, ok bool) {
+	region, ok := parameters[sharedMemoryRegionParam]
+	if !ok {
+		return "", 0, 0, false
+	}
+	return region.GetStringParam(), uint64(parameters[sharedMemoryByteSizeParam].GetInt64Param()), uint64(parameters[sharedMemoryOffsetParam].GetInt64Param()), true
+}
+
+func usesSharedMemory(parameters map[string]*grpc_client.InferParameter) bool {
+	_, ok := parameters[sharedMemoryRegionParam]
+	return ok
+}
+
+// RegisterSystemSharedMemoryContext registers a system shared memory region using the given
+// context, see ShareSystemMemoryRegisterContext.
+func (tc *TritonGRPCClient) RegisterSystemSharedMemoryContext(ctx context.Context, regionName, key string, byteSize, offset uint64) error {
+	_, err := tc.ShareSystemMemoryRegisterContext(ctx, regionName, key, byteSize, offset)
+	return err
+}
+
+// UnregisterSystemSharedMemoryContext unregisters a system shared memory region, or all of
+// them when regionName is empty, using the given context.
+func (tc *TritonGRPCClient) UnregisterSystemSharedMemoryContext(ctx context.Context, regionName string) error {
+	_, err := tc.ShareSystemMemoryUnRegisterContext(ctx, regionName)
+	return err
+}
+
+// RegisterSystemSharedMemoryContext registers a system shared memory region using the given
+// context, see ShareSystemMemoryRegisterContext.
+func (hc *TritonHTTPClientService) RegisterSystemSharedMemoryContext(ctx context.Context, regionName, key string, byteSize, offset uint64) error {
+	return hc.ShareSystemMemoryRegisterContext(ctx, regionName, key, byteSize, offset)
+}
+
+// UnregisterSystemSharedMemoryContext unregisters a system shared memory region, or all of
+// them when regionName is empty, using the given context.
+func (hc *TritonHTTPClientService) UnregisterSystemSharedMemoryContext(ctx context.Context, regionName string) error {
+	return hc.ShareSystemMemoryUnRegisterContext(ctx, regionName)
+}
@@ -0,0 +1,226 @@
+package shm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Client is the client a Manager registers regions with, both TritonGRPCClient and
+// TritonHTTPClientService implement it.
+type Client interface {
+	triton_client.SystemSharedMemoryClient
+	Disconnect() error
+}
+
+// Manager tracks the system shared memory regions the process registers with Triton. It adopts
+// regions left registered under the same name by a previous run of the process, registers them
+// again when Triton lost them on a restart, see Reconcile and Watch, and unregisters all of them
+// on Disconnect. Regions of other processes registered with the same server are left alone.
+type Manager struct {
+	client Client
+
+	mu      sync.Mutex
+	regions map[string]*managedRegion
+}
+
+type managedRegion struct {
+	region *Region
+	// owned regions were created by the manager and are destroyed when they are unregistered.
+	owned bool
+}
+
+// NewManager returns a manager registering regions with client.
+func NewManager(client Client) *Manager {
+	return &Manager{client: client, regions: make(map[string]*managedRegion)}
+}
+
+// Create creates and maps the shared memory object key with byteSize bytes and registers it
+// under name, see Register. The region is destroyed when it is unregistered.
+func (m *Manager) Create(ctx context.Context, name, key string, byteSize uint64) (*Region, error) {
+	region, err := Create(name, key, byteSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.register(ctx, region, true); err != nil {
+		return nil, errors.Join(err, region.Destroy())
+	}
+	return region, nil
+}
+
+// Register registers the whole region with Triton under its name and tracks it. A region
+// already registered under the name with the same key and size, e.g. by the process before it
+// restarted, is adopted as is, one registered with another key or size is replaced. The region
+// stays owned by the caller and is not closed by the manager.
+func (m *Manager) Register(ctx context.Context, region *Region) error {
+	return m.register(ctx, region, false)
+}
+
+func (m *Manager) register(ctx context.Context, region *Region, owned bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.regions[region.name]; ok {
+		return fmt.Errorf("shared memory region %s is already registered", region.name)
+	}
+	registered, err := m.client.SystemSharedMemoryStatusContext(ctx, "")
+	if err != nil {
+		return err
+	}
+	if err = m.ensureRegistered(ctx, region, registered.GetRegions()[region.name]); err != nil {
+		return err
+	}
+	m.regions[region.name] = &managedRegion{region: region, owned: owned}
+	return nil
+}
+
+// ensureRegistered registers region unless Triton already has it registered, replacing a
+// different region registered under its name, m.mu must be held.
+func (m *Manager) ensureRegistered(ctx context.Context, region *Region, registered *grpc_client.SystemSharedMemoryStatusResponse_RegionStatus) error {
+	if registered != nil {
+		if matches(region, registered) {
+			return nil
+		}
+		if err := m.client.UnregisterSystemSharedMemoryContext(ctx, region.name); err != nil {
+			return fmt.Errorf("unable to replace shared memory region %s: %w", region.name, err)
+		}
+	}
+	return region.Register(ctx, m.client)
+}
+
+// matches reports whether Triton has the whole region registered.
+func matches(region *Region, registered *grpc_client.SystemSharedMemoryStatusResponse_RegionStatus) bool {
+	return registered.Key == region.key && registered.ByteSize == region.ByteSize() && registered.Offset == 0
+}
+
+// Unregister unregisters the named region from Triton and stops tracking it. A region created
+// by the manager is destroyed.
+func (m *Manager) Unregister(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	managed, ok := m.regions[name]
+	if !ok {
+		return fmt.Errorf("shared memory region %s is not registered", name)
+	}
+	return m.unregister(ctx, managed)
+}
+
+// unregister unregisters and untracks a region, m.mu must be held.
+func (m *Manager) unregister(ctx context.Context, managed *managedRegion) error {
+	if err := managed.region.Unregister(ctx, m.client); err != nil {
+		return err
+	}
+	delete(m.regions, managed.region.name)
+	if managed.owned {
+		return managed.region.Destroy()
+	}
+	return nil
+}
+
+// Region returns the tracked region registered under name.
+func (m *Manager) Region(name string) (*Region, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	managed, ok := m.regions[name]
+	if !ok {
+		return nil, false
+	}
+	return managed.region, true
+}
+
+// Regions returns the tracked regions sorted by name.
+func (m *Manager) Regions() []*Region {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	regions := make([]*Region, 0, len(m.regions))
+	for _, managed := range m.regions {
+		regions = append(regions, managed.region)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].name < regions[j].name })
+	return regions
+}
+
+// Reconcile registers the tracked regions Triton does not have registered as they are, as
+// after a restart of the server, and returns how many were registered.
+func (m *Manager) Reconcile(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	registered, err := m.client.SystemSharedMemoryStatusContext(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	var errs []error
+	for name, managed := range m.regions {
+		status := registered.GetRegions()[name]
+		if status != nil && matches(managed.region, status) {
+			continue
+		}
+		if err = m.ensureRegistered(ctx, managed.region, status); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		count++
+	}
+	return count, errors.Join(errs...)
+}
+
+// DefaultWatchInterval is the interval of Watch when none is given.
+const DefaultWatchInterval = 5 * time.Second
+
+// Watch calls Reconcile every interval, DefaultWatchInterval when not positive, until ctx ends,
+// so the regions are registered again soon after Triton restarts. Errors are passed to onError
+// when it is not nil.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Reconcile(ctx); err != nil && onError != nil && ctx.Err() == nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// DisconnectContext unregisters every tracked region using the given context, destroys the
+// regions created by the manager and disconnects the client.
+func (m *Manager) DisconnectContext(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, managed := range m.regions {
+		if err := m.unregister(ctx, managed); err != nil {
+			errs = append(errs, fmt.Errorf("unable to unregister shared memory region %s: %w", managed.region.name, err))
+		}
+	}
+	errs = append(errs, m.client.Disconnect())
+	return errors.Join(errs...)
+}
+
+// Disconnect unregisters every tracked region, destroys the regions created by the manager and
+// disconnects the client.
+func (m *Manager) Disconnect(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return m.DisconnectContext(ctx)
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package shm
+
+import (
+	"context"
+	"github.com/okieraised/gotritron/triton_client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	server := tritontest.NewServer()
+	defer server.Close()
+	newClient := func() *triton_client.TritonGRPCClient {
+		client, err := triton_client.NewTritonGRPCClient(tritontest.Target, server.DialOptions())
+		assert.NoError(t, err)
+		return client
+	}
+	client := newClient()
+	defer client.Disconnect()
+	ctx := context.Background()
+
+	// a region left registered by a previous run of the process is adopted
+	inputKey := testKey("manager_input")
+	assert.NoError(t, client.RegisterSystemSharedMemoryContext(ctx, "input_data", inputKey, 32, 0))
+	// one registered with another size is replaced
+	assert.NoError(t, client.RegisterSystemSharedMemoryContext(ctx, "output_data", testKey("stale"), 8, 0))
+	// and the regions of other processes are left alone
+	assert.NoError(t, client.RegisterSystemSharedMemoryContext(ctx, "other", testKey("other"), 8, 0))
+
+	manager := NewManager(newClient())
+	input, err := manager.Create(ctx, "input_data", inputKey, 32)
+	assert.NoError(t, err)
+	outputKey := testKey("manager_output")
+	output, err := Create("output_data", outputKey, 16)
+	assert.NoError(t, err)
+	defer output.Destroy()
+	assert.NoError(t, manager.Register(ctx, output))
+	assert.Error(t, manager.Register(ctx, output))
+	assert.Equal(t, []*Region{input, output}, manager.Regions())
+	region, ok := manager.Region("input_data")
+	assert.True(t, ok)
+	assert.Equal(t, input, region)
+
+	assert.Len(t, server.Requests("SystemSharedMemoryRegister"), 4)
+	status, err := client.SystemSharedMemoryStatusContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, status.Regions, 3)
+	assert.Equal(t, outputKey, status.Regions["output_data"].Key)
+	assert.Equal(t, uint64(16), status.Regions["output_data"].ByteSize)
+
+	// nothing to do while Triton has the regions
+	count, err := manager.Reconcile(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	// a restarted Triton has lost them
+	assert.NoError(t, client.UnregisterSystemSharedMemoryContext(ctx, ""))
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.Watch(watchCtx, 10*time.Millisecond, func(err error) { t.Error(err) })
+	}()
+	assert.Eventually(t, func() bool {
+		status, err := client.SystemSharedMemoryStatusContext(ctx, "")
+		return err == nil && len(status.Regions) == 2
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	// a zero interval takes the default
+	manager.Watch(watchCtx, 0, nil)
+
+	assert.NoError(t, manager.Unregister(ctx, "output_data"))
+	assert.Error(t, manager.Unregister(ctx, "output_data"))
+	assert.Equal(t, []*Region{input}, manager.Regions())
+	// regions registered by the caller stay mapped
+	assert.NoError(t, output.Write(0, []byte{1}))
+
+	assert.NoError(t, client.RegisterSystemSharedMemoryContext(ctx, "other", testKey("other"), 8, 0))
+	assert.NoError(t, manager.Disconnect(time.Second))
+	status, err = client.SystemSharedMemoryStatusContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, status.Regions, 1)
+	assert.Contains(t, status.Regions, "other")
+	// regions created by the manager are destroyed
+	_, err = os.Stat(filepath.Join(Dir, inputKey[1:]))
+	assert.True(t, os.IsNotExist(err))
+	assert.Empty(t, manager.Regions())
+}
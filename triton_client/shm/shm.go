@@ -0,0 +1,276 @@
+// Package shm creates POSIX system shared memory regions that a co-located Triton server reads
+// inputs from and writes outputs to, instead of receiving and sending the tensor data with the
+// inference requests.
+//
+// A region is created and mapped with Create, filled with SetData or WriteInput, registered
+// with Triton under its name with Register, and referenced by inputs and outputs with
+// SetInput, WriteInput and SetOutput. Outputs written by Triton are read back with ReadOutput.
+// A Manager keeps the regions of a process registered across restarts of the process and of
+// Triton and unregisters them on Disconnect.
+package shm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir is where Linux exposes POSIX shared memory objects, shm_open("/key") opens Dir + "/key".
+const Dir = "/dev/shm"
+
+// Region is a system shared memory region mapped into the process.
+type Region struct {
+	name string
+	key  string
+	data []byte
+}
+
+// Name returns the name the region is registered with in Triton.
+func (r *Region) Name() string {
+	return r.name
+}
+
+// Key returns the shared memory key of the region, e.g. "/input0".
+func (r *Region) Key() string {
+	return r.key
+}
+
+// ByteSize returns the size of the region.
+func (r *Region) ByteSize() uint64 {
+	return uint64(len(r.data))
+}
+
+// Bytes returns the mapped memory of the region. It must not be used after Close.
+func (r *Region) Bytes() []byte {
+	return r.data
+}
+
+// keyPath returns the file backing the shared memory key.
+func keyPath(key string) (string, error) {
+	if !strings.HasPrefix(key, "/") || len(key) == 1 || strings.Contains(key[1:], "/") {
+		return "", fmt.Errorf("invalid shared memory key %q, it must be a single name starting with /", key)
+	}
+	return filepath.Join(Dir, key[1:]), nil
+}
+
+// span returns the part of the region at offset with the given size.
+func (r *Region) span(offset, byteSize uint64) ([]byte, error) {
+	if r.data == nil {
+		return nil, fmt.Errorf("shared memory region %s is closed", r.name)
+	}
+	if offset > uint64(len(r.data)) || byteSize > uint64(len(r.data))-offset {
+		return nil, fmt.Errorf("%d bytes at offset %d exceed shared memory region %s of %d bytes", byteSize, offset, r.name, len(r.data))
+	}
+	return r.data[offset : offset+byteSize], nil
+}
+
+// Write copies data into the region at offset.
+func (r *Region) Write(offset uint64, data []byte) error {
+	dst, err := r.span(offset, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	copy(dst, data)
+	return nil
+}
+
+// Read returns a copy of byteSize bytes of the region at offset.
+func (r *Region) Read(offset, byteSize uint64) ([]byte, error) {
+	src, err := r.span(offset, byteSize)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), src...), nil
+}
+
+// SetData serializes data into the region at offset, little-endian like the raw tensor
+// contents of inference requests, and returns the number of bytes written. The element type
+// of data must be one of []bool, []uint8..[]uint64, []int8..[]int64, []float32, []float64, or
+// [][]byte and []string for BYTES tensors. FP16 and BF16 tensors are written with WriteInput.
+func (r *Region) SetData(offset uint64, data interface{}) (uint64, error) {
+	switch v := data.(type) {
+	case []bool:
+		return writeFixed(r, offset, v, 1, func(b []byte, val bool) {
+			b[0] = 0
+			if val {
+				b[0] = 1
+			}
+		})
+	case []uint8:
+		return uint64(len(v)), r.Write(offset, v)
+	case []uint16:
+		return writeFixed(r, offset, v, 2, binary.LittleEndian.PutUint16)
+	case []uint32:
+		return writeFixed(r, offset, v, 4, binary.LittleEndian.PutUint32)
+	case []uint64:
+		return writeFixed(r, offset, v, 8, binary.LittleEndian.PutUint64)
+	case []int8:
+		return writeFixed(r, offset, v, 1, func(b []byte, val int8) { b[0] = byte(val) })
+	case []int16:
+		return writeFixed(r, offset, v, 2, func(b []byte, val int16) { binary.LittleEndian.PutUint16(b, uint16(val)) })
+	case []int32:
+		return writeFixed(r, offset, v, 4, func(b []byte, val int32) { binary.LittleEndian.PutUint32(b, uint32(val)) })
+	case []int64:
+		return writeFixed(r, offset, v, 8, func(b []byte, val int64) { binary.LittleEndian.PutUint64(b, uint64(val)) })
+	case []float32:
+		return writeFixed(r, offset, v, 4, func(b []byte, val float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(val)) })
+	case []float64:
+		return writeFixed(r, offset, v, 8, func(b []byte, val float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(val)) })
+	case [][]byte:
+		return writeBytes(r, offset, len(v), func(idx int) []byte { return v[idx] })
+	case []string:
+		return writeBytes(r, offset, len(v), func(idx int) []byte { return []byte(v[idx]) })
+	}
+	return 0, fmt.Errorf("cannot write %T data to shared memory", data)
+}
+
+// writeFixed encodes data directly into the mapped memory, without an intermediate buffer.
+func writeFixed[T any](r *Region, offset uint64, data []T, size int, put func([]byte, T)) (uint64, error) {
+	byteSize := uint64(len(data) * size)
+	dst, err := r.span(offset, byteSize)
+	if err != nil {
+		return 0, err
+	}
+	for idx, val := range data {
+		put(dst[idx*size:], val)
+	}
+	return byteSize, nil
+}
+
+// writeBytes writes BYTES elements, each prefixed with its 4-byte little-endian length.
+func writeBytes(r *Region, offset uint64, count int, element func(idx int) []byte) (uint64, error) {
+	var byteSize uint64
+	for idx := 0; idx < count; idx++ {
+		byteSize += 4 + uint64(len(element(idx)))
+	}
+	dst, err := r.span(offset, byteSize)
+	if err != nil {
+		return 0, err
+	}
+	for idx := 0; idx < count; idx++ {
+		val := element(idx)
+		binary.LittleEndian.PutUint32(dst, uint32(len(val)))
+		dst = dst[4+copy(dst[4:], val):]
+	}
+	return byteSize, nil
+}
+
+// SetInput has Triton read input from byteSize bytes of the region at offset, where its data
+// was written with SetData. Data set on the input before is dropped.
+func (r *Region) SetInput(input *triton_client.InferInput, offset, byteSize uint64) error {
+	if _, err := r.span(offset, byteSize); err != nil {
+		return fmt.Errorf("input %s: %w", input.Name(), err)
+	}
+	input.SetSharedMemory(r.name, byteSize, offset)
+	return nil
+}
+
+// WriteInput copies the data set on input into the region at offset and has Triton read the
+// input from there. It returns the number of bytes written, the offset of the next input.
+func (r *Region) WriteInput(input *triton_client.InferInput, offset uint64) (uint64, error) {
+	raw := input.RawData()
+	if raw == nil {
+		return 0, fmt.Errorf("input %s: data is not set", input.Name())
+	}
+	if err := r.Write(offset, raw); err != nil {
+		return 0, fmt.Errorf("input %s: %w", input.Name(), err)
+	}
+	input.SetSharedMemory(r.name, uint64(len(raw)), offset)
+	return uint64(len(raw)), nil
+}
+
+// SetOutput has Triton write output to byteSize bytes of the region at offset.
+func (r *Region) SetOutput(output *triton_client.InferRequestedOutput, offset, byteSize uint64) error {
+	if _, err := r.span(offset, byteSize); err != nil {
+		return fmt.Errorf("output %s: %w", output.Name(), err)
+	}
+	output.SetSharedMemory(r.name, byteSize, offset)
+	return nil
+}
+
+// ReadOutput reads the named output that Triton wrote to the region at offset. The datatype
+// and shape come from result, the returned result holds a copy of the output data and decodes
+// it like any other, e.g. with AsFloat32.
+func (r *Region) ReadOutput(result *triton_client.InferResult, name string, offset uint64) (*triton_client.InferResult, error) {
+	output, err := result.Output(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var byteSize uint64
+	if output.Datatype == triton_client.DataTypeBytes {
+		byteSize, err = r.bytesSize(offset, model_config.GetElementCount(output.Shape))
+	} else {
+		size := model_config.GetByteSize(model_config.ProtocolStringToDataType(output.Datatype), output.Shape)
+		if size < 0 {
+			err = fmt.Errorf("cannot read datatype %s with shape %v", output.Datatype, output.Shape)
+		}
+		byteSize = uint64(size)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+
+	raw, err := r.Read(offset, byteSize)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", name, err)
+	}
+	return triton_client.NewInferResult(&grpc_client.ModelInferResponse{
+		ModelName:         result.ModelName(),
+		ModelVersion:      result.ModelVersion(),
+		Id:                result.ID(),
+		Outputs:           []*grpc_client.ModelInferResponse_InferOutputTensor{output},
+		RawOutputContents: [][]byte{raw},
+	}), nil
+}
+
+// bytesSize returns the size of count length-prefixed BYTES elements at offset.
+func (r *Region) bytesSize(offset uint64, count int64) (uint64, error) {
+	var byteSize uint64
+	for idx := int64(0); idx < count; idx++ {
+		prefix, err := r.span(offset+byteSize, 4)
+		if err != nil {
+			return 0, err
+		}
+		byteSize += 4 + uint64(binary.LittleEndian.Uint32(prefix))
+	}
+	if _, err := r.span(offset, byteSize); err != nil {
+		return 0, err
+	}
+	return byteSize, nil
+}
+
+// Register registers the whole region with Triton under its name.
+func (r *Region) Register(ctx context.Context, client triton_client.SystemSharedMemoryClient) error {
+	if r.data == nil {
+		return fmt.Errorf("shared memory region %s is closed", r.name)
+	}
+	return client.RegisterSystemSharedMemoryContext(ctx, r.name, r.key, uint64(len(r.data)), 0)
+}
+
+// Unregister unregisters the region from Triton.
+func (r *Region) Unregister(ctx context.Context, client triton_client.SystemSharedMemoryClient) error {
+	return client.UnregisterSystemSharedMemoryContext(ctx, r.name)
+}
+
+// Destroy unmaps the region and removes its shared memory object. Triton keeps its own
+// mapping until the region is unregistered.
+func (r *Region) Destroy() error {
+	err := r.Close()
+	path, pathErr := keyPath(r.key)
+	if pathErr != nil {
+		return errors.Join(err, pathErr)
+	}
+	if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+		return errors.Join(err, removeErr)
+	}
+	return err
+}
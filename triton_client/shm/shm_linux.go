@@ -0,0 +1,73 @@
+package shm
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Create creates the shared memory object key with byteSize bytes, like shm_open with O_CREAT
+// and ftruncate, and maps it. An existing object is resized. name is the name the region is
+// registered with in Triton.
+func Create(name, key string, byteSize uint64) (*Region, error) {
+	if byteSize == 0 {
+		return nil, fmt.Errorf("shared memory region %s must not be empty", name)
+	}
+	path, err := keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create shared memory region %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if err = file.Truncate(int64(byteSize)); err != nil {
+		return nil, fmt.Errorf("unable to size shared memory region %s: %w", name, err)
+	}
+	return mapRegion(name, key, file, byteSize)
+}
+
+// Open maps the whole existing shared memory object key, e.g. one created by another process.
+func Open(name, key string) (*Region, error) {
+	path, err := keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open shared memory region %s: %w", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("shared memory region %s is empty", name)
+	}
+	return mapRegion(name, key, file, uint64(info.Size()))
+}
+
+// mapRegion maps byteSize bytes of file, the mapping stays valid once file is closed.
+func mapRegion(name, key string, file *os.File, byteSize uint64) (*Region, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(byteSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("unable to map shared memory region %s: %w", name, err)
+	}
+	return &Region{name: name, key: key, data: data}, nil
+}
+
+// Close unmaps the region, the shared memory object is kept, see Destroy.
+func (r *Region) Close() error {
+	if r.data == nil {
+		return nil
+	}
+	data := r.data
+	r.data = nil
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package shm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Create is only supported on Linux.
+func Create(name, key string, byteSize uint64) (*Region, error) {
+	return nil, fmt.Errorf("shared memory region %s: %w", name, errors.ErrUnsupported)
+}
+
+// Open is only supported on Linux.
+func Open(name, key string) (*Region, error) {
+	return nil, fmt.Errorf("shared memory region %s: %w", name, errors.ErrUnsupported)
+}
+
+// Close releases the region.
+func (r *Region) Close() error {
+	r.data = nil
+	return nil
+}
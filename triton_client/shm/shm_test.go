@@ -0,0 +1,154 @@
+//go:build linux
+
+package shm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testKey returns a shared memory key unique to the test process.
+func testKey(name string) string {
+	return fmt.Sprintf("/gotritron_%s_%d", name, os.Getpid())
+}
+
+func TestRegion_SetData(t *testing.T) {
+	key := testKey("set_data")
+	region, err := Create("input0", key, 64)
+	assert.NoError(t, err)
+	defer region.Destroy()
+	assert.Equal(t, "input0", region.Name())
+	assert.Equal(t, key, region.Key())
+	assert.Equal(t, uint64(64), region.ByteSize())
+
+	n, err := region.SetData(8, []float32{1.5, -2})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), n)
+	n, err = region.SetData(16, []string{"ab", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), n)
+
+	// the data is visible in the shared memory object
+	content, err := os.ReadFile(filepath.Join(Dir, key[1:]))
+	assert.NoError(t, err)
+	assert.Len(t, content, 64)
+	assert.Equal(t, math.Float32bits(1.5), binary.LittleEndian.Uint32(content[8:]))
+	assert.Equal(t, math.Float32bits(-2), binary.LittleEndian.Uint32(content[12:]))
+	assert.Equal(t, []byte{2, 0, 0, 0, 'a', 'b', 1, 0, 0, 0, 'c'}, content[16:27])
+
+	// and to other mappings of it
+	other, err := Open("input0", key)
+	assert.NoError(t, err)
+	raw, err := other.Read(8, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, content[8:12], raw)
+	assert.NoError(t, other.Close())
+
+	_, err = region.SetData(60, []float64{1})
+	assert.Error(t, err)
+	_, err = region.SetData(0, []complex64{1})
+	assert.Error(t, err)
+
+	assert.NoError(t, region.Destroy())
+	_, err = os.Stat(filepath.Join(Dir, key[1:]))
+	assert.True(t, os.IsNotExist(err))
+	_, err = region.Read(0, 4)
+	assert.Error(t, err)
+
+	_, err = Create("bad", "no-slash", 8)
+	assert.Error(t, err)
+	_, err = Create("empty", testKey("empty"), 0)
+	assert.Error(t, err)
+}
+
+// doubleModel returns its FP32 input "x" multiplied by two as output "y".
+var doubleModel = &tritontest.Model{
+	Config: &grpc_client.ModelConfig{
+		Name:   "double",
+		Input:  []*grpc_client.ModelInput{{Name: "x", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{4}}},
+		Output: []*grpc_client.ModelOutput{{Name: "y", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{4}}},
+	},
+	Infer: func(_ context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+		raw := append([]byte(nil), req.RawInputContents[0]...)
+		for idx := 0; idx < len(raw); idx += 4 {
+			value := math.Float32frombits(binary.LittleEndian.Uint32(raw[idx:]))
+			binary.LittleEndian.PutUint32(raw[idx:], math.Float32bits(2*value))
+		}
+		return &grpc_client.ModelInferResponse{
+			Outputs:           []*grpc_client.ModelInferResponse_InferOutputTensor{{Name: "y", Datatype: "FP32", Shape: req.Inputs[0].Shape}},
+			RawOutputContents: [][]byte{raw},
+		}, nil
+	},
+}
+
+func TestRegion_Infer(t *testing.T) {
+	server := tritontest.NewServer()
+	defer server.Close()
+	server.AddModel(doubleModel)
+	client, err := triton_client.NewTritonGRPCClient(tritontest.Target, server.DialOptions())
+	assert.NoError(t, err)
+	defer client.Disconnect()
+	ctx := context.Background()
+
+	inputRegion, err := Create("input_data", testKey("input"), 32)
+	assert.NoError(t, err)
+	defer inputRegion.Destroy()
+	outputRegion, err := Create("output_data", testKey("output"), 32)
+	assert.NoError(t, err)
+	defer outputRegion.Destroy()
+	assert.NoError(t, inputRegion.Register(ctx, client))
+	assert.NoError(t, outputRegion.Register(ctx, client))
+
+	input := triton_client.NewInferInput("x", []int64{4}, triton_client.DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{1, 2, 3, 4}))
+	n, err := inputRegion.WriteInput(input, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16), n)
+	output := triton_client.NewInferRequestedOutput("y")
+	assert.NoError(t, outputRegion.SetOutput(output, 0, 16))
+	assert.Error(t, outputRegion.SetOutput(output, 24, 16))
+
+	result, err := client.Infer(ctx, "double", "", []*triton_client.InferInput{input}, []*triton_client.InferRequestedOutput{output})
+	assert.NoError(t, err)
+	_, err = result.AsFloat32("y")
+	assert.Error(t, err)
+
+	y, err := outputRegion.ReadOutput(result, "y", 0)
+	assert.NoError(t, err)
+	values, err := y.AsFloat32("y")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{2, 4, 6, 8}, values)
+
+	// the tensor data never went over gRPC
+	requests := server.InferRequests()
+	assert.Len(t, requests, 1)
+	assert.Empty(t, requests[0].RawInputContents)
+	assert.Equal(t, "input_data", requests[0].Inputs[0].Parameters["shared_memory_region"].GetStringParam())
+	assert.Equal(t, int64(16), requests[0].Inputs[0].Parameters["shared_memory_offset"].GetInt64Param())
+	assert.Equal(t, int64(16), requests[0].Inputs[0].Parameters["shared_memory_byte_size"].GetInt64Param())
+
+	// inputs written with SetData are referenced with SetInput
+	_, err = inputRegion.SetData(0, []float32{-1, 0, 1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, inputRegion.SetInput(input, 0, 16))
+	result, err = client.Infer(ctx, "double", "", []*triton_client.InferInput{input}, []*triton_client.InferRequestedOutput{output})
+	assert.NoError(t, err)
+	y, err = outputRegion.ReadOutput(result, "y", 0)
+	assert.NoError(t, err)
+	values, err = y.AsFloat32("y")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{-2, 0, 2, 4}, values)
+
+	assert.NoError(t, inputRegion.Unregister(ctx, client))
+	_, err = client.Infer(ctx, "double", "", []*triton_client.InferInput{input}, nil)
+	assert.Error(t, err)
+}
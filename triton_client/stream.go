@@ -0,0 +1,322 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStreamClosed is returned when sending on a stream that has been closed or cancelled.
+var ErrStreamClosed = errors.New("inference stream is closed")
+
+// StreamRequestIDPrefix prefixes the IDs InferStream.Send generates for requests without one,
+// caller IDs may not use it so generated IDs never collide with them.
+const StreamRequestIDPrefix = "gotritron-stream-"
+
+// finalResponseParam is set by Triton on the last response of a decoupled request
+// when the model enables empty final responses.
+const finalResponseParam = "triton_final_response"
+
+// StreamError is an error Triton reported in-band for a request on an InferStream. Triton only
+// sends the message, Code is derived from the messages of the Triton frontend so that
+// status.Code and the circuit breaker can tell errors caused by the request, e.g.
+// InvalidArgument, from failures of the model, which are Unknown.
+type StreamError struct {
+	Code    codes.Code
+	Message string
+}
+
+// streamErrorCodes maps the messages of the Triton frontend to their gRPC code. The patterns
+// are anchored and case-sensitive so that messages of the model, e.g. "file not found", are
+// left Unknown.
+var streamErrorCodes = []struct {
+	pattern *regexp.Regexp
+	code    codes.Code
+}{
+	{regexp.MustCompile(`^Request for unknown model: '[^']*'.* is not found$`), codes.NotFound},
+	{regexp.MustCompile(`^Request for model '[^']*'.* is not ready$`), codes.Unavailable},
+	{regexp.MustCompile(`^(\[request id: [^\]]*\] )?(` +
+		`expected \d+ inputs but got \d+ inputs for model '|` +
+		`input byte size mismatch for input '|` +
+		`unexpected shape for input '|` +
+		`unexpected inference input '|` +
+		`inference request batch-size must be <= \d+ for ')`), codes.InvalidArgument},
+}
+
+func newStreamError(message string) *StreamError {
+	for _, streamErrorCode := range streamErrorCodes {
+		if streamErrorCode.pattern.MatchString(message) {
+			return &StreamError{Code: streamErrorCode.code, Message: message}
+		}
+	}
+	return &StreamError{Code: codes.Unknown, Message: message}
+}
+
+func (e *StreamError) Error() string {
+	return e.Message
+}
+
+// GRPCStatus returns the error as a status, for status.Code and status.FromError.
+func (e *StreamError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// StreamResult is a single response received on an InferStream.
+type StreamResult struct {
+	// RequestID is the ID of the request this response belongs to
+	RequestID string
+	// Response is the inference response, it may be nil when Err is set
+	Response *grpc_client.ModelInferResponse
+	// Err is set when Triton reported an error for the request, as a *StreamError, or the stream
+	// failed
+	Err error
+}
+
+// IsFinal reports whether Triton flagged this as the last response for its request.
+func (r *StreamResult) IsFinal() bool {
+	if r.Response == nil {
+		return false
+	}
+	param, ok := r.Response.Parameters[finalResponseParam]
+	return ok && param.GetBoolParam()
+}
+
+// StreamCallback is called from the receiving goroutine for every response on the stream.
+type StreamCallback func(result *StreamResult)
+
+// InferStream is a bidirectional inference stream. Requests are sent with Send and every
+// response, including the several responses of decoupled models, is delivered to the callback.
+type InferStream struct {
+	stream    grpc_client.GRPCInferenceService_ModelStreamInferClient
+	cancel    context.CancelFunc
+	callback  StreamCallback
+	sendMu    sync.Mutex
+	closed    bool
+	cancelled atomic.Bool
+	nextID    atomic.Uint64
+	done      chan struct{}
+	err       error
+
+	// breakers are the circuit breakers of the client, pending holds the completion of the
+	// requests in flight by ID.
+	breakers  *circuitBreakers
+	pendingMu sync.Mutex
+	pending   map[string][]func(err error)
+}
+
+// ModelStreamInfer opens an inference stream. The stream lives until Close or Cancel is
+// called, or ctx ends. It fails fast when the health gate reports Triton is not serving, see
+// SetHealthGate.
+func (tc *TritonGRPCClient) ModelStreamInfer(ctx context.Context, callback StreamCallback) (*InferStream, error) {
+	if callback == nil {
+		return nil, errors.New("stream callback is required")
+	}
+	if err := tc.healthGate.Load().Ready(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := tc.grpcClient.ModelStreamInfer(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	inferStream := &InferStream{
+		stream:   stream,
+		cancel:   cancel,
+		callback: callback,
+		done:     make(chan struct{}),
+		breakers: tc.circuitBreakers.Load(),
+		pending:  make(map[string][]func(err error)),
+	}
+	go inferStream.receive()
+	return inferStream, nil
+}
+
+// Send sends a request on the stream and returns its ID. A request without an ID is sent with
+// a generated one, prefixed with StreamRequestIDPrefix, so its responses can be matched; the
+// request itself is left unchanged. It fails fast when the circuit breaker of the model is
+// open, see TritonGRPCClient.SetCircuitBreaker.
+func (s *InferStream) Send(modelInferRequest *grpc_client.ModelInferRequest) (string, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	if s.closed {
+		return "", ErrStreamClosed
+	}
+	if strings.HasPrefix(modelInferRequest.Id, StreamRequestIDPrefix) {
+		return "", fmt.Errorf("request id %q uses the prefix of generated ids %q", modelInferRequest.Id, StreamRequestIDPrefix)
+	}
+	if modelInferRequest.Id == "" {
+		modelInferRequest = withRequestID(modelInferRequest, StreamRequestIDPrefix+strconv.FormatUint(s.nextID.Add(1), 10))
+	}
+	if err := s.track(modelInferRequest); err != nil {
+		return "", err
+	}
+
+	err := s.stream.Send(modelInferRequest)
+	if errors.Is(err, io.EOF) {
+		// the stream was terminated, the actual error is returned by Recv
+		<-s.done
+		s.complete(modelInferRequest.Id, ErrStreamClosed)
+		if s.err != nil {
+			return "", s.err
+		}
+		return "", ErrStreamClosed
+	}
+	if err != nil {
+		s.complete(modelInferRequest.Id, err)
+		return "", err
+	}
+	return modelInferRequest.Id, nil
+}
+
+// withRequestID returns a shallow copy of the request with the ID, the tensors and parameters
+// are shared.
+func withRequestID(modelInferRequest *grpc_client.ModelInferRequest, requestID string) *grpc_client.ModelInferRequest {
+	return &grpc_client.ModelInferRequest{
+		ModelName:        modelInferRequest.ModelName,
+		ModelVersion:     modelInferRequest.ModelVersion,
+		Id:               requestID,
+		Parameters:       modelInferRequest.Parameters,
+		Inputs:           modelInferRequest.Inputs,
+		Outputs:          modelInferRequest.Outputs,
+		RawInputContents: modelInferRequest.RawInputContents,
+	}
+}
+
+// track passes the request through the circuit breaker of its model and keeps its completion
+// until its response is received.
+func (s *InferStream) track(modelInferRequest *grpc_client.ModelInferRequest) error {
+	if s.breakers == nil {
+		return nil
+	}
+	done, err := s.breakers.acquire(s.stream.Context(), modelInferRequest.ModelName)
+	if err != nil {
+		return err
+	}
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	s.pending[modelInferRequest.Id] = append(s.pending[modelInferRequest.Id], done)
+	return nil
+}
+
+// complete reports the result of the oldest request in flight with the ID.
+func (s *InferStream) complete(requestID string, err error) {
+	s.pendingMu.Lock()
+	pending := s.pending[requestID]
+	if len(pending) == 0 {
+		s.pendingMu.Unlock()
+		return
+	}
+	if len(pending) == 1 {
+		delete(s.pending, requestID)
+	} else {
+		s.pending[requestID] = pending[1:]
+	}
+	s.pendingMu.Unlock()
+
+	pending[0](err)
+}
+
+// completeAll reports err as the result of every request in flight.
+func (s *InferStream) completeAll(err error) {
+	s.pendingMu.Lock()
+	pending := s.pending
+	s.pending = make(map[string][]func(err error))
+	s.pendingMu.Unlock()
+
+	for _, dones := range pending {
+		for _, done := range dones {
+			done(err)
+		}
+	}
+}
+
+// completes reports whether the response is the last one of its request. Decoupled models may
+// send several responses per request, only those flagging their final response are followed
+// up to it.
+func completes(result *StreamResult) bool {
+	if result.Err != nil || result.Response == nil {
+		return true
+	}
+	if _, flagged := result.Response.Parameters[finalResponseParam]; flagged {
+		return result.IsFinal()
+	}
+	return true
+}
+
+// Done returns a channel that is closed once the stream has stopped receiving.
+func (s *InferStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops sending, waits for the responses still in flight and returns the error that
+// ended the stream, if any.
+func (s *InferStream) Close() error {
+	s.sendMu.Lock()
+	if !s.closed {
+		s.closed = true
+		_ = s.stream.CloseSend()
+	}
+	s.sendMu.Unlock()
+
+	<-s.done
+	s.cancel()
+	return s.err
+}
+
+// Cancel aborts the stream without waiting for the responses in flight.
+func (s *InferStream) Cancel() {
+	s.cancelled.Store(true)
+	s.cancel()
+
+	s.sendMu.Lock()
+	s.closed = true
+	s.sendMu.Unlock()
+	<-s.done
+}
+
+func (s *InferStream) receive() {
+	defer close(s.done)
+
+	for {
+		streamResponse, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.completeAll(ErrStreamClosed)
+			return
+		}
+		if err != nil {
+			if s.cancelled.Load() {
+				s.completeAll(context.Canceled)
+				return
+			}
+			s.completeAll(err)
+			s.err = err
+			s.callback(&StreamResult{Err: err})
+			return
+		}
+
+		result := &StreamResult{Response: streamResponse.InferResponse}
+		if streamResponse.InferResponse != nil {
+			result.RequestID = streamResponse.InferResponse.Id
+		}
+		if streamResponse.ErrorMessage != "" {
+			result.Err = newStreamError(streamResponse.ErrorMessage)
+		}
+		if completes(result) {
+			s.complete(result.RequestID, result.Err)
+		}
+		s.callback(result)
+	}
+}
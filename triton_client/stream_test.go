@@ -0,0 +1,126 @@
+package triton_client
+
+import (
+	"context"
+	"errors"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+)
+
+// newStreamTestClient starts a fake server and returns a client connected to it. The "echo"
+// model answers every streamed request with two responses, the second one flagged as final,
+// and the "failing" model fails every request.
+func newStreamTestClient(t *testing.T) (*tritontest.Server, *TritonGRPCClient) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "echo"},
+		StreamInfer: func(_ context.Context, _ *grpc_client.ModelInferRequest, send func(*grpc_client.ModelInferResponse) error) error {
+			for i := 0; i < 2; i++ {
+				err := send(&grpc_client.ModelInferResponse{
+					Parameters: map[string]*grpc_client.InferParameter{
+						finalResponseParam: {ParameterChoice: &grpc_client.InferParameter_BoolParam{BoolParam: i == 1}},
+					},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "failing"},
+		Infer: func(context.Context, *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+			return nil, errors.New("failed to allocate memory for the output")
+		},
+	})
+	return server, client
+}
+
+func TestTritonGRPCClient_ModelStreamInfer(t *testing.T) {
+	_, client := newStreamTestClient(t)
+
+	var mu sync.Mutex
+	results := make(map[string][]*StreamResult)
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *StreamResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result.RequestID] = append(results[result.RequestID], result)
+	})
+	assert.NoError(t, err)
+
+	req := &grpc_client.ModelInferRequest{ModelName: "echo"}
+	id1, err := stream.Send(req)
+	assert.NoError(t, err)
+	assert.Equal(t, StreamRequestIDPrefix+"1", id1)
+	// the request is not changed and can be sent again under a new ID
+	assert.Empty(t, req.Id)
+	id2, err := stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo", Id: "custom"})
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", id2)
+	id3, err := stream.Send(&grpc_client.ModelInferRequest{ModelName: "missing"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, id1, id3)
+	// caller IDs cannot collide with generated ones
+	_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo", Id: id1})
+	assert.ErrorContains(t, err, "prefix of generated ids")
+
+	assert.NoError(t, stream.Close())
+
+	assert.Len(t, results[id1], 2)
+	assert.False(t, results[id1][0].IsFinal())
+	assert.True(t, results[id1][1].IsFinal())
+	assert.Len(t, results[id2], 2)
+	assert.Len(t, results[id3], 1)
+	assert.EqualError(t, results[id3][0].Err, "Request for unknown model: 'missing' is not found")
+	assert.Equal(t, codes.NotFound, status.Code(results[id3][0].Err))
+
+	_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo"})
+	assert.ErrorIs(t, err, ErrStreamClosed)
+}
+
+func TestInferStream_Cancel(t *testing.T) {
+	_, client := newStreamTestClient(t)
+
+	var calls int
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *StreamResult) {
+		if result.Err != nil {
+			calls++
+		}
+	})
+	assert.NoError(t, err)
+
+	stream.Cancel()
+	<-stream.Done()
+	assert.Equal(t, 0, calls)
+
+	_, err = stream.Send(&grpc_client.ModelInferRequest{ModelName: "echo"})
+	assert.ErrorIs(t, err, ErrStreamClosed)
+}
+
+func TestNewStreamError(t *testing.T) {
+	for message, code := range map[string]codes.Code{
+		"Request for unknown model: 'face' is not found":                                              codes.NotFound,
+		"Request for unknown model: 'face' version 2 is not found":                                    codes.NotFound,
+		"Request for model 'face' which is not ready":                                                 codes.Unavailable,
+		"[request id: 1] expected 1 inputs but got 0 inputs for model 'face'":                         codes.InvalidArgument,
+		"expected 1 inputs but got 0 inputs for model 'face'":                                         codes.InvalidArgument,
+		"input byte size mismatch for input 'data' for model 'face'. Expected 4, got 8":               codes.InvalidArgument,
+		"[request id: 1] unexpected shape for input 'data' for model 'face'. Expected [1], got [2]":   codes.InvalidArgument,
+		"failed to allocate memory for the output":                                                    codes.Unknown,
+		"unexpected end of model output":                                                              codes.Unknown,
+		"model.onnx: file not found":                                                                  codes.Unknown,
+		"invalid memory address or nil pointer dereference":                                           codes.Unknown,
+		"the backend must be restarted":                                                               codes.Unknown,
+		"Failed to process the request(s) for model 'face', message: Request for model 'a' not ready": codes.Unknown,
+	} {
+		err := newStreamError(message)
+		assert.Equal(t, code, status.Code(err), message)
+		assert.EqualError(t, err, message)
+	}
+}
@@ -0,0 +1,840 @@
+// Package tritontest provides an in-process fake Triton server for hermetic tests.
+//
+// The server implements GRPCInferenceServiceServer and the health Check over an in-memory
+// bufconn listener. Tests register fake models with their config, metadata, readiness and infer
+// function, connect a client with DialOptions and inspect the requests the server received
+// afterwards. Errors, for every call or the next calls, and latency can be injected per RPC
+// method. Inputs and outputs placed in registered system shared memory regions are read from
+// and written to the files backing the regions.
+package tritontest
+
+import (
+	"context"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"math"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target is the address to dial together with DialOptions.
+const Target = "passthrough:///tritontest"
+
+const bufferSize = 1 << 20
+
+// SharedMemoryDir is where the server opens the system shared memory regions referenced by
+// inference requests, the key "/input0" is read from SharedMemoryDir + "/input0".
+var SharedMemoryDir = "/dev/shm"
+
+// Parameters placing an input or output in a registered shared memory region.
+const (
+	sharedMemoryRegionParam   = "shared_memory_region"
+	sharedMemoryByteSizeParam = "shared_memory_byte_size"
+	sharedMemoryOffsetParam   = "shared_memory_offset"
+)
+
+// InferFunc computes the response of a fake model. The server fills in the model name,
+// version and request ID of the response when the function leaves them empty.
+type InferFunc func(ctx context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error)
+
+// StreamInferFunc answers a streamed request with any number of responses, calling send for
+// each, as decoupled models do. The server fills in the responses like for InferFunc, an
+// error is reported in the stream after the responses already sent.
+type StreamInferFunc func(ctx context.Context, req *grpc_client.ModelInferRequest, send func(*grpc_client.ModelInferResponse) error) error
+
+// Model is a fake model served by Server.
+type Model struct {
+	// Name is the name of the model, it defaults to Config.Name
+	Name string
+	// Version is the version of the model, requests for other versions are rejected when set
+	Version string
+	// Config is returned by ModelConfig
+	Config *grpc_client.ModelConfig
+	// Metadata is returned by ModelMetadata, it is derived from Config when nil
+	Metadata *grpc_client.ModelMetadataResponse
+	// Infer answers inference requests, they fail with Unimplemented when nil
+	Infer InferFunc
+	// StreamInfer answers streamed requests in place of Infer when set
+	StreamInfer StreamInferFunc
+}
+
+// Request is an RPC received by the server.
+type Request struct {
+	// Method is the RPC method name, e.g. "ModelInfer"
+	Method string
+	// Message is a copy of the request message
+	Message proto.Message
+}
+
+type modelState struct {
+	model *Model
+	ready bool
+}
+
+// Server is an in-process fake Triton server. It is safe for concurrent use.
+type Server struct {
+	grpc_client.UnimplementedGRPCInferenceServiceServer
+	grpc_client.UnimplementedHealthServer
+
+	listener   *bufconn.Listener
+	grpcServer *grpc.Server
+
+	mu            sync.Mutex
+	live          bool
+	ready         bool
+	models        map[string]*modelState
+	requests      []Request
+	errors        map[string]error
+	nextErrors    map[string][]error
+	latency       map[string]time.Duration
+	systemRegions map[string]*grpc_client.SystemSharedMemoryStatusResponse_RegionStatus
+	cudaRegions   map[string]*grpc_client.CudaSharedMemoryStatusResponse_RegionStatus
+	logSettings   map[string]*grpc_client.LogSettingsResponse_SettingValue
+	traceSettings map[string]map[string]*grpc_client.TraceSettingResponse_SettingValue
+}
+
+// NewServer starts a fake server that is live and ready with no models.
+func NewServer() *Server {
+	s := &Server{
+		listener:      bufconn.Listen(bufferSize),
+		live:          true,
+		ready:         true,
+		models:        make(map[string]*modelState),
+		errors:        make(map[string]error),
+		nextErrors:    make(map[string][]error),
+		latency:       make(map[string]time.Duration),
+		systemRegions: make(map[string]*grpc_client.SystemSharedMemoryStatusResponse_RegionStatus),
+		cudaRegions:   make(map[string]*grpc_client.CudaSharedMemoryStatusResponse_RegionStatus),
+		logSettings:   make(map[string]*grpc_client.LogSettingsResponse_SettingValue),
+		traceSettings: make(map[string]map[string]*grpc_client.TraceSettingResponse_SettingValue),
+	}
+	// Triton accepts messages up to 2GB, large image tensors exceed the gRPC default of 4MB
+	s.grpcServer = grpc.NewServer(
+		grpc.MaxRecvMsgSize(math.MaxInt32),
+		grpc.MaxSendMsgSize(math.MaxInt32),
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	)
+	grpc_client.RegisterGRPCInferenceServiceServer(s.grpcServer, s)
+	grpc_client.RegisterHealthServer(s.grpcServer, s)
+	go func() {
+		_ = s.grpcServer.Serve(s.listener)
+	}()
+	return s
+}
+
+// DialOptions returns the options connecting a client to the server, use them with Target.
+func (s *Server) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() {
+	s.grpcServer.Stop()
+}
+
+// AddModel registers a model, replacing any model with the same name. The model is ready.
+func (s *Server) AddModel(model *Model) {
+	name := model.Name
+	if name == "" && model.Config != nil {
+		name = model.Config.Name
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models[name] = &modelState{model: model, ready: true}
+}
+
+// RemoveModel unregisters a model.
+func (s *Server) RemoveModel(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.models, name)
+}
+
+// SetModelReady changes the readiness of a registered model.
+func (s *Server) SetModelReady(name string, ready bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.models[name]; ok {
+		state.ready = ready
+	}
+}
+
+// SetServerLive changes the result of ServerLive.
+func (s *Server) SetServerLive(live bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live = live
+}
+
+// SetServerReady changes the result of ServerReady.
+func (s *Server) SetServerReady(ready bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ready = ready
+}
+
+// InjectError makes every call of the RPC method fail with err, a gRPC status error or a
+// plain error reported as Unknown. A nil err removes the injected error.
+func (s *Server) InjectError(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.errors, method)
+		return
+	}
+	s.errors[method] = err
+}
+
+// InjectErrors makes the next calls of the RPC method fail with errs, one call per error in
+// order, before any error injected with InjectError applies again.
+func (s *Server) InjectErrors(method string, errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextErrors[method] = append(s.nextErrors[method], errs...)
+}
+
+// InjectLatency delays every call of the RPC method by delay, or until the call is cancelled.
+func (s *Server) InjectLatency(method string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delay <= 0 {
+		delete(s.latency, method)
+		return
+	}
+	s.latency[method] = delay
+}
+
+// Requests returns the requests received so far for the RPC method, or all requests when
+// method is empty. Requests rejected by an injected error are recorded too.
+func (s *Server) Requests(method string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []Request
+	for _, request := range s.requests {
+		if method == "" || request.Method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// InferRequests returns the inference requests received so far, unary and streamed.
+func (s *Server) InferRequests() []*grpc_client.ModelInferRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []*grpc_client.ModelInferRequest
+	for _, request := range s.requests {
+		if req, ok := request.Message.(*grpc_client.ModelInferRequest); ok {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) record(method string, msg interface{}) {
+	message, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: method, Message: proto.Clone(message)})
+}
+
+// intercept applies the injected latency and error of method.
+func (s *Server) intercept(ctx context.Context, method string) error {
+	s.mu.Lock()
+	delay := s.latency[method]
+	err := s.errors[method]
+	if next := s.nextErrors[method]; len(next) > 0 {
+		err = next[0]
+		s.nextErrors[method] = next[1:]
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	s.record(method, req)
+	if err := s.intercept(ctx, method); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	method := path.Base(info.FullMethod)
+	if err := s.intercept(stream.Context(), method); err != nil {
+		return err
+	}
+	return handler(srv, &recordingStream{ServerStream: stream, server: s, method: method})
+}
+
+// recordingStream records the messages received on a stream.
+type recordingStream struct {
+	grpc.ServerStream
+	server *Server
+	method string
+}
+
+func (rs *recordingStream) RecvMsg(m interface{}) error {
+	err := rs.ServerStream.RecvMsg(m)
+	if err == nil {
+		rs.server.record(rs.method, m)
+	}
+	return err
+}
+
+// model returns a registered model, checking the requested version and, when requireReady is
+// set, its readiness.
+func (s *Server) model(name, version string, requireReady bool) (*Model, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.models[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Request for unknown model: '%s' is not found", name)
+	}
+	if version != "" && state.model.Version != "" && version != state.model.Version {
+		return nil, status.Errorf(codes.NotFound, "Request for unknown model: '%s' version %s is not found", name, version)
+	}
+	if requireReady && !state.ready {
+		return nil, status.Errorf(codes.Unavailable, "Request for model '%s' which is not ready", name)
+	}
+	return state.model, nil
+}
+
+func (s *Server) ServerLive(context.Context, *grpc_client.ServerLiveRequest) (*grpc_client.ServerLiveResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &grpc_client.ServerLiveResponse{Live: s.live}, nil
+}
+
+func (s *Server) ServerReady(context.Context, *grpc_client.ServerReadyRequest) (*grpc_client.ServerReadyResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &grpc_client.ServerReadyResponse{Ready: s.ready}, nil
+}
+
+// Check implements the health service like Triton, which serves once the server is live and
+// ready and does not implement Watch.
+func (s *Server) Check(_ context.Context, req *grpc_client.HealthCheckRequest) (*grpc_client.HealthCheckResponse, error) {
+	if req.Service != "" {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.live || !s.ready {
+		return &grpc_client.HealthCheckResponse{Status: grpc_client.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &grpc_client.HealthCheckResponse{Status: grpc_client.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *Server) ModelReady(_ context.Context, req *grpc_client.ModelReadyRequest) (*grpc_client.ModelReadyResponse, error) {
+	if _, err := s.model(req.Name, req.Version, true); err != nil {
+		return &grpc_client.ModelReadyResponse{Ready: false}, nil
+	}
+	return &grpc_client.ModelReadyResponse{Ready: true}, nil
+}
+
+func (s *Server) ServerMetadata(context.Context, *grpc_client.ServerMetadataRequest) (*grpc_client.ServerMetadataResponse, error) {
+	return &grpc_client.ServerMetadataResponse{
+		Name:       "tritontest",
+		Version:    "2.0.0",
+		Extensions: []string{"model_repository", "system_shared_memory", "cuda_shared_memory", "logging", "trace"},
+	}, nil
+}
+
+func (s *Server) ModelMetadata(_ context.Context, req *grpc_client.ModelMetadataRequest) (*grpc_client.ModelMetadataResponse, error) {
+	model, err := s.model(req.Name, req.Version, false)
+	if err != nil {
+		return nil, err
+	}
+	if model.Metadata != nil {
+		return model.Metadata, nil
+	}
+	return metadataFromConfig(req.Name, model), nil
+}
+
+// metadataFromConfig derives the metadata of a model from its config, adding the batch
+// dimension to the shapes of batching models.
+func metadataFromConfig(name string, model *Model) *grpc_client.ModelMetadataResponse {
+	metadata := &grpc_client.ModelMetadataResponse{Name: name}
+	if model.Version != "" {
+		metadata.Versions = []string{model.Version}
+	}
+	config := model.Config
+	if config == nil {
+		return metadata
+	}
+
+	metadata.Platform = config.Platform
+	shape := func(dims []int64) []int64 {
+		if config.MaxBatchSize > 0 {
+			return append([]int64{-1}, dims...)
+		}
+		return dims
+	}
+	for _, input := range config.Input {
+		metadata.Inputs = append(metadata.Inputs, &grpc_client.ModelMetadataResponse_TensorMetadata{
+			Name:     input.Name,
+			Datatype: model_config.DataTypeToProtocolString(input.DataType),
+			Shape:    shape(input.Dims),
+		})
+	}
+	for _, output := range config.Output {
+		metadata.Outputs = append(metadata.Outputs, &grpc_client.ModelMetadataResponse_TensorMetadata{
+			Name:     output.Name,
+			Datatype: model_config.DataTypeToProtocolString(output.DataType),
+			Shape:    shape(output.Dims),
+		})
+	}
+	return metadata
+}
+
+func (s *Server) ModelConfig(_ context.Context, req *grpc_client.ModelConfigRequest) (*grpc_client.ModelConfigResponse, error) {
+	model, err := s.model(req.Name, req.Version, false)
+	if err != nil {
+		return nil, err
+	}
+	config := model.Config
+	if config == nil {
+		config = &grpc_client.ModelConfig{Name: req.Name}
+	}
+	return &grpc_client.ModelConfigResponse{Config: config}, nil
+}
+
+func (s *Server) ModelStatistics(_ context.Context, req *grpc_client.ModelStatisticsRequest) (*grpc_client.ModelStatisticsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := &grpc_client.ModelStatisticsResponse{}
+	for name, state := range s.models {
+		if req.Name != "" && req.Name != name {
+			continue
+		}
+		var count uint64
+		for _, request := range s.requests {
+			if infer, ok := request.Message.(*grpc_client.ModelInferRequest); ok && infer.ModelName == name {
+				count++
+			}
+		}
+		response.ModelStats = append(response.ModelStats, &grpc_client.ModelStatistics{
+			Name:           name,
+			Version:        state.model.Version,
+			InferenceCount: count,
+			ExecutionCount: count,
+		})
+	}
+	if req.Name != "" && len(response.ModelStats) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Request for unknown model: '%s' is not found", req.Name)
+	}
+	return response, nil
+}
+
+func (s *Server) infer(ctx context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	model, err := s.model(req.ModelName, req.ModelVersion, true)
+	if err != nil {
+		return nil, err
+	}
+	if model.Infer == nil {
+		return nil, status.Errorf(codes.Unimplemented, "model %s has no infer function", req.ModelName)
+	}
+
+	modelReq, err := s.readSharedMemoryInputs(req)
+	if err != nil {
+		return nil, err
+	}
+	response, err := model.Infer(ctx, modelReq)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.writeSharedMemoryOutputs(req, response); err != nil {
+		return nil, err
+	}
+	fillResponse(model, req, response)
+	return response, nil
+}
+
+// fillResponse fills in the model name, version and request ID a model left empty.
+func fillResponse(model *Model, req *grpc_client.ModelInferRequest, response *grpc_client.ModelInferResponse) {
+	if response.ModelName == "" {
+		response.ModelName = req.ModelName
+	}
+	if response.ModelVersion == "" {
+		response.ModelVersion = model.Version
+	}
+	if response.Id == "" {
+		response.Id = req.Id
+	}
+}
+
+// readSharedMemoryInputs returns req with the data of the inputs placed in system shared
+// memory read from their regions, so every input has raw contents in input order.
+func (s *Server) readSharedMemoryInputs(req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferRequest, error) {
+	inShm := false
+	for _, input := range req.Inputs {
+		if _, ok := input.Parameters[sharedMemoryRegionParam]; ok {
+			inShm = true
+		}
+	}
+	if !inShm {
+		return req, nil
+	}
+
+	resolved := proto.Clone(req).(*grpc_client.ModelInferRequest)
+	resolved.RawInputContents = nil
+	rawIdx := 0
+	for _, input := range req.Inputs {
+		if _, ok := input.Parameters[sharedMemoryRegionParam]; !ok {
+			if rawIdx >= len(req.RawInputContents) {
+				return nil, status.Errorf(codes.InvalidArgument, "input '%s' has no data", input.Name)
+			}
+			resolved.RawInputContents = append(resolved.RawInputContents, req.RawInputContents[rawIdx])
+			rawIdx++
+			continue
+		}
+
+		file, offset, byteSize, err := s.sharedMemoryFile(input.Parameters, os.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+		raw := make([]byte, byteSize)
+		_, err = file.ReadAt(raw, offset)
+		_ = file.Close()
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unable to read input '%s' from shared memory: %v", input.Name, err)
+		}
+		resolved.RawInputContents = append(resolved.RawInputContents, raw)
+	}
+	return resolved, nil
+}
+
+// writeSharedMemoryOutputs writes the outputs requested in system shared memory to their
+// regions. Like Triton, their raw contents are left empty in the response.
+func (s *Server) writeSharedMemoryOutputs(req *grpc_client.ModelInferRequest, response *grpc_client.ModelInferResponse) error {
+	for _, output := range req.Outputs {
+		if _, ok := output.Parameters[sharedMemoryRegionParam]; !ok {
+			continue
+		}
+		for idx, responseOutput := range response.Outputs {
+			if responseOutput.Name != output.Name || idx >= len(response.RawOutputContents) {
+				continue
+			}
+
+			file, offset, byteSize, err := s.sharedMemoryFile(output.Parameters, os.O_WRONLY)
+			if err != nil {
+				return err
+			}
+			raw := response.RawOutputContents[idx]
+			if int64(len(raw)) > byteSize {
+				_ = file.Close()
+				return status.Errorf(codes.InvalidArgument, "shared memory size specified with the request for output '%s' should be at least %d bytes to hold the results", output.Name, len(raw))
+			}
+			_, err = file.WriteAt(raw, offset)
+			_ = file.Close()
+			if err != nil {
+				return status.Errorf(codes.Internal, "unable to write output '%s' to shared memory: %v", output.Name, err)
+			}
+			response.RawOutputContents[idx] = []byte{}
+		}
+	}
+	return nil
+}
+
+// sharedMemoryFile opens the file backing the system shared memory region referenced by the
+// parameters of a tensor and returns the offset and size of the tensor in the file.
+func (s *Server) sharedMemoryFile(parameters map[string]*grpc_client.InferParameter, flag int) (*os.File, int64, int64, error) {
+	regionName := parameters[sharedMemoryRegionParam].GetStringParam()
+	s.mu.Lock()
+	region, ok := s.systemRegions[regionName]
+	s.mu.Unlock()
+	if !ok {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument, "Unable to find shared memory region: '%s'", regionName)
+	}
+
+	offset := parameters[sharedMemoryOffsetParam].GetInt64Param()
+	byteSize := parameters[sharedMemoryByteSizeParam].GetInt64Param()
+	if offset < 0 || byteSize < 0 || uint64(offset+byteSize) > region.ByteSize {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument, "Invalid offset + byte size for shared memory region: '%s'", regionName)
+	}
+
+	file, err := os.OpenFile(filepath.Join(SharedMemoryDir, strings.TrimPrefix(region.Key, "/")), flag, 0)
+	if err != nil {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument, "Unable to open shared memory region: '%s': %v", regionName, err)
+	}
+	return file, int64(region.Offset) + offset, byteSize, nil
+}
+
+func (s *Server) ModelInfer(ctx context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+	return s.infer(ctx, req)
+}
+
+// ModelStreamInfer answers every streamed request with a single response, or the responses of
+// the StreamInfer function of the model, errors are reported in the stream response like
+// Triton does.
+func (s *Server) ModelStreamInfer(stream grpc_client.GRPCInferenceService_ModelStreamInferServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			// io.EOF once the client closed its side
+			return nil
+		}
+		if err = s.streamInfer(stream, req); err != nil {
+			return err
+		}
+	}
+}
+
+// streamInfer answers a streamed request, it only fails when the stream does.
+func (s *Server) streamInfer(stream grpc_client.GRPCInferenceService_ModelStreamInferServer, req *grpc_client.ModelInferRequest) error {
+	model, err := s.model(req.ModelName, req.ModelVersion, true)
+	if err == nil && model.StreamInfer != nil {
+		var sendErr error
+		err = model.StreamInfer(stream.Context(), req, func(response *grpc_client.ModelInferResponse) error {
+			fillResponse(model, req, response)
+			sendErr = stream.Send(&grpc_client.ModelStreamInferResponse{InferResponse: response})
+			return sendErr
+		})
+		if sendErr != nil {
+			return sendErr
+		}
+	} else if err == nil {
+		var response *grpc_client.ModelInferResponse
+		if response, err = s.infer(stream.Context(), req); err == nil {
+			return stream.Send(&grpc_client.ModelStreamInferResponse{InferResponse: response})
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return stream.Send(&grpc_client.ModelStreamInferResponse{
+		ErrorMessage:  status.Convert(err).Message(),
+		InferResponse: &grpc_client.ModelInferResponse{Id: req.Id, ModelName: req.ModelName},
+	})
+}
+
+func (s *Server) RepositoryIndex(_ context.Context, req *grpc_client.RepositoryIndexRequest) (*grpc_client.RepositoryIndexResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := &grpc_client.RepositoryIndexResponse{}
+	for name, state := range s.models {
+		if req.Ready && !state.ready {
+			continue
+		}
+		modelIndex := &grpc_client.RepositoryIndexResponse_ModelIndex{
+			Name:    name,
+			Version: state.model.Version,
+			State:   "READY",
+		}
+		if !state.ready {
+			modelIndex.State = "UNAVAILABLE"
+			modelIndex.Reason = "unloaded"
+		}
+		response.Models = append(response.Models, modelIndex)
+	}
+	return response, nil
+}
+
+// RepositoryModelLoad marks a registered model as ready.
+func (s *Server) RepositoryModelLoad(_ context.Context, req *grpc_client.RepositoryModelLoadRequest) (*grpc_client.RepositoryModelLoadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.models[req.ModelName]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to load '%s', failed to poll from model repository", req.ModelName)
+	}
+	state.ready = true
+	return &grpc_client.RepositoryModelLoadResponse{}, nil
+}
+
+// RepositoryModelUnload marks a registered model as not ready.
+func (s *Server) RepositoryModelUnload(_ context.Context, req *grpc_client.RepositoryModelUnloadRequest) (*grpc_client.RepositoryModelUnloadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.models[req.ModelName]; ok {
+		state.ready = false
+	}
+	return &grpc_client.RepositoryModelUnloadResponse{}, nil
+}
+
+func (s *Server) SystemSharedMemoryStatus(_ context.Context, req *grpc_client.SystemSharedMemoryStatusRequest) (*grpc_client.SystemSharedMemoryStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := &grpc_client.SystemSharedMemoryStatusResponse{Regions: make(map[string]*grpc_client.SystemSharedMemoryStatusResponse_RegionStatus)}
+	for name, region := range s.systemRegions {
+		if req.Name == "" || req.Name == name {
+			response.Regions[name] = region
+		}
+	}
+	if req.Name != "" && len(response.Regions) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to find system shared memory region: '%s'", req.Name)
+	}
+	return response, nil
+}
+
+func (s *Server) SystemSharedMemoryRegister(_ context.Context, req *grpc_client.SystemSharedMemoryRegisterRequest) (*grpc_client.SystemSharedMemoryRegisterResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.systemRegions[req.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "shared memory region '%s' already in manager", req.Name)
+	}
+	s.systemRegions[req.Name] = &grpc_client.SystemSharedMemoryStatusResponse_RegionStatus{
+		Name:     req.Name,
+		Key:      req.Key,
+		Offset:   req.Offset,
+		ByteSize: req.ByteSize,
+	}
+	return &grpc_client.SystemSharedMemoryRegisterResponse{}, nil
+}
+
+func (s *Server) SystemSharedMemoryUnregister(_ context.Context, req *grpc_client.SystemSharedMemoryUnregisterRequest) (*grpc_client.SystemSharedMemoryUnregisterResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Name == "" {
+		s.systemRegions = make(map[string]*grpc_client.SystemSharedMemoryStatusResponse_RegionStatus)
+	} else {
+		delete(s.systemRegions, req.Name)
+	}
+	return &grpc_client.SystemSharedMemoryUnregisterResponse{}, nil
+}
+
+func (s *Server) CudaSharedMemoryStatus(_ context.Context, req *grpc_client.CudaSharedMemoryStatusRequest) (*grpc_client.CudaSharedMemoryStatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := &grpc_client.CudaSharedMemoryStatusResponse{Regions: make(map[string]*grpc_client.CudaSharedMemoryStatusResponse_RegionStatus)}
+	for name, region := range s.cudaRegions {
+		if req.Name == "" || req.Name == name {
+			response.Regions[name] = region
+		}
+	}
+	if req.Name != "" && len(response.Regions) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Unable to find cuda shared memory region: '%s'", req.Name)
+	}
+	return response, nil
+}
+
+func (s *Server) CudaSharedMemoryRegister(_ context.Context, req *grpc_client.CudaSharedMemoryRegisterRequest) (*grpc_client.CudaSharedMemoryRegisterResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cudaRegions[req.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "shared memory region '%s' already in manager", req.Name)
+	}
+	s.cudaRegions[req.Name] = &grpc_client.CudaSharedMemoryStatusResponse_RegionStatus{
+		Name:     req.Name,
+		DeviceId: uint64(req.DeviceId),
+		ByteSize: req.ByteSize,
+	}
+	return &grpc_client.CudaSharedMemoryRegisterResponse{}, nil
+}
+
+func (s *Server) CudaSharedMemoryUnregister(_ context.Context, req *grpc_client.CudaSharedMemoryUnregisterRequest) (*grpc_client.CudaSharedMemoryUnregisterResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Name == "" {
+		s.cudaRegions = make(map[string]*grpc_client.CudaSharedMemoryStatusResponse_RegionStatus)
+	} else {
+		delete(s.cudaRegions, req.Name)
+	}
+	return &grpc_client.CudaSharedMemoryUnregisterResponse{}, nil
+}
+
+// TraceSetting stores the settings of a model, or the global ones when the model name is empty.
+func (s *Server) TraceSetting(_ context.Context, req *grpc_client.TraceSettingRequest) (*grpc_client.TraceSettingResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.traceSettings[req.ModelName]
+	if !ok {
+		settings = make(map[string]*grpc_client.TraceSettingResponse_SettingValue)
+		s.traceSettings[req.ModelName] = settings
+	}
+	for key, value := range req.Settings {
+		if len(value.GetValue()) == 0 {
+			delete(settings, key)
+			continue
+		}
+		settings[key] = &grpc_client.TraceSettingResponse_SettingValue{Value: value.Value}
+	}
+
+	response := &grpc_client.TraceSettingResponse{Settings: make(map[string]*grpc_client.TraceSettingResponse_SettingValue, len(settings))}
+	for key, value := range settings {
+		response.Settings[key] = value
+	}
+	return response, nil
+}
+
+// LogSettings stores the log settings.
+func (s *Server) LogSettings(_ context.Context, req *grpc_client.LogSettingsRequest) (*grpc_client.LogSettingsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range req.Settings {
+		setting := &grpc_client.LogSettingsResponse_SettingValue{}
+		switch choice := value.GetParameterChoice().(type) {
+		case *grpc_client.LogSettingsRequest_SettingValue_BoolParam:
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_BoolParam{BoolParam: choice.BoolParam}
+		case *grpc_client.LogSettingsRequest_SettingValue_Uint32Param:
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_Uint32Param{Uint32Param: choice.Uint32Param}
+		case *grpc_client.LogSettingsRequest_SettingValue_StringParam:
+			setting.ParameterChoice = &grpc_client.LogSettingsResponse_SettingValue_StringParam{StringParam: choice.StringParam}
+		default:
+			return nil, fmt.Errorf("log setting %s has no value", key)
+		}
+		s.logSettings[key] = setting
+	}
+
+	response := &grpc_client.LogSettingsResponse{Settings: make(map[string]*grpc_client.LogSettingsResponse_SettingValue, len(s.logSettings))}
+	for key, value := range s.logSettings {
+		response.Settings[key] = value
+	}
+	return response, nil
+}
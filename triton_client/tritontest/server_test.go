@@ -0,0 +1,268 @@
+package tritontest_test
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T) (*tritontest.Server, *triton_client.TritonGRPCClient) {
+	server := tritontest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := triton_client.NewTritonGRPCClient(tritontest.Target, server.DialOptions())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect()
+	})
+	return server, client
+}
+
+// doubleModel returns its FP32 input "x" multiplied by two as output "y".
+var doubleModel = &tritontest.Model{
+	Config: &grpc_client.ModelConfig{
+		Name:         "double",
+		MaxBatchSize: 4,
+		Input:        []*grpc_client.ModelInput{{Name: "x", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{2}}},
+		Output:       []*grpc_client.ModelOutput{{Name: "y", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{2}}},
+	},
+	Infer: func(_ context.Context, req *grpc_client.ModelInferRequest) (*grpc_client.ModelInferResponse, error) {
+		input := triton_client.NewInferInput("x", req.Inputs[0].Shape, triton_client.DataTypeFP32)
+		if err := input.SetRawData(req.RawInputContents[0]); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		values, err := triton_client.NewInferResult(&grpc_client.ModelInferResponse{
+			Outputs:           []*grpc_client.ModelInferResponse_InferOutputTensor{{Name: "x", Datatype: "FP32", Shape: req.Inputs[0].Shape}},
+			RawOutputContents: req.RawInputContents,
+		}).AsFloat32("x")
+		if err != nil {
+			return nil, err
+		}
+		for idx := range values {
+			values[idx] *= 2
+		}
+		output := triton_client.NewInferInput("y", req.Inputs[0].Shape, triton_client.DataTypeFP32)
+		if err = output.SetData(values); err != nil {
+			return nil, err
+		}
+		return &grpc_client.ModelInferResponse{
+			Outputs:           []*grpc_client.ModelInferResponse_InferOutputTensor{{Name: "y", Datatype: "FP32", Shape: req.Inputs[0].Shape}},
+			RawOutputContents: [][]byte{output.RawData()},
+		}, nil
+	},
+}
+
+func inferDouble(ctx context.Context, client *triton_client.TritonGRPCClient, values []float32) (*triton_client.InferResult, error) {
+	input := triton_client.NewInferInput("x", []int64{1, int64(len(values))}, triton_client.DataTypeFP32)
+	if err := input.SetData(values); err != nil {
+		return nil, err
+	}
+	return client.Infer(ctx, "double", "", []*triton_client.InferInput{input}, nil)
+}
+
+func TestServer_Models(t *testing.T) {
+	server, client := newClient(t)
+	server.AddModel(doubleModel)
+	ctx := context.Background()
+
+	ready, err := client.ModelReadyContext(ctx, "double", "")
+	assert.NoError(t, err)
+	assert.True(t, ready)
+
+	metadata, err := client.ModelMetadataContext(ctx, "double", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "FP32", metadata.Inputs[0].Datatype)
+	assert.Equal(t, []int64{-1, 2}, metadata.Inputs[0].Shape)
+
+	err = client.ModelUnloadContext(ctx, "", "double", nil)
+	assert.NoError(t, err)
+	ready, err = client.ModelReadyContext(ctx, "double", "")
+	assert.NoError(t, err)
+	assert.False(t, ready)
+	_, err = inferDouble(ctx, client, []float32{1, 2})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	index, err := client.ModelRepositoryIndexContext(ctx, "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "UNAVAILABLE", index.Models[0].State)
+
+	err = client.ModelLoadContext(ctx, "", "double", nil)
+	assert.NoError(t, err)
+	err = client.ModelLoadContext(ctx, "", "missing", nil)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	server.RemoveModel("double")
+	_, err = client.GetModelConfigurationContext(ctx, "double", "")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_HealthCheck(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+
+	servingStatus, err := client.HealthCheckContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_client.HealthCheckResponse_SERVING, servingStatus)
+
+	server.SetServerReady(false)
+	servingStatus, err = client.HealthCheckContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_client.HealthCheckResponse_NOT_SERVING, servingStatus)
+
+	_, err = client.HealthCheckContext(ctx, "other")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Len(t, server.Requests("Check"), 3)
+}
+
+func TestServer_Infer(t *testing.T) {
+	server, client := newClient(t)
+	server.AddModel(doubleModel)
+	ctx := context.Background()
+
+	result, err := inferDouble(ctx, client, []float32{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "double", result.ModelName())
+	y, err := result.AsFloat32("y")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{2, 4}, y)
+
+	requests := server.InferRequests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "double", requests[0].ModelName)
+	assert.Len(t, server.Requests("ModelInfer"), 1)
+
+	stats, err := client.ModelInferStatsContext(ctx, "double", "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.ModelStats[0].InferenceCount)
+
+	server.ResetRequests()
+	assert.Empty(t, server.Requests(""))
+}
+
+func TestServer_InjectErrorAndLatency(t *testing.T) {
+	server, client := newClient(t)
+	server.AddModel(doubleModel)
+
+	server.InjectError("ModelInfer", status.Error(codes.Unavailable, "server is restarting"))
+	_, err := inferDouble(context.Background(), client, []float32{1, 2})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, server.InferRequests(), 1)
+
+	server.InjectError("ModelInfer", nil)
+	_, err = inferDouble(context.Background(), client, []float32{1, 2})
+	assert.NoError(t, err)
+
+	// errors for the next calls only
+	server.InjectErrors("ModelInfer", status.Error(codes.Unavailable, "busy"), status.Error(codes.ResourceExhausted, "full"))
+	for _, code := range []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.OK} {
+		_, err = inferDouble(context.Background(), client, []float32{1, 2})
+		assert.Equal(t, code, status.Code(err))
+	}
+
+	server.InjectLatency("ModelInfer", 50*time.Millisecond)
+	start := time.Now()
+	_, err = inferDouble(context.Background(), client, []float32{1, 2})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err = inferDouble(ctx, client, []float32{1, 2})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestServer_ModelStreamInfer(t *testing.T) {
+	server, client := newClient(t)
+	server.AddModel(doubleModel)
+
+	var mu sync.Mutex
+	var results []*triton_client.StreamResult
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *triton_client.StreamResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	})
+	assert.NoError(t, err)
+
+	input := triton_client.NewInferInput("x", []int64{1, 2}, triton_client.DataTypeFP32)
+	assert.NoError(t, input.SetData([]float32{3, 4}))
+	var ids []string
+	for _, model := range []string{"double", "missing"} {
+		req, err := triton_client.NewModelInferRequest(model, "", []*triton_client.InferInput{input}, nil)
+		assert.NoError(t, err)
+		id, err := stream.Send(req)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.NoError(t, stream.Close())
+
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	y, err := triton_client.NewInferResult(results[0].Response).AsFloat32("y")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{6, 8}, y)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, ids[1], results[1].RequestID)
+	assert.Len(t, server.InferRequests(), 2)
+}
+
+func TestServer_ModelStreamInfer_Decoupled(t *testing.T) {
+	server, client := newClient(t)
+	server.AddModel(&tritontest.Model{
+		Config: &grpc_client.ModelConfig{Name: "decoupled"},
+		StreamInfer: func(_ context.Context, _ *grpc_client.ModelInferRequest, send func(*grpc_client.ModelInferResponse) error) error {
+			for i := 0; i < 2; i++ {
+				if err := send(&grpc_client.ModelInferResponse{}); err != nil {
+					return err
+				}
+			}
+			return status.Error(codes.Internal, "model crashed")
+		},
+	})
+
+	var mu sync.Mutex
+	var results []*triton_client.StreamResult
+	stream, err := client.ModelStreamInfer(context.Background(), func(result *triton_client.StreamResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	})
+	assert.NoError(t, err)
+	id, err := stream.Send(&grpc_client.ModelInferRequest{ModelName: "decoupled"})
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Close())
+
+	assert.Len(t, results, 3)
+	for _, result := range results[:2] {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, id, result.RequestID)
+		assert.Equal(t, "decoupled", result.Response.ModelName)
+	}
+	assert.EqualError(t, results[2].Err, "model crashed")
+}
+
+func TestServer_SharedMemory(t *testing.T) {
+	_, client := newClient(t)
+	ctx := context.Background()
+
+	_, err := client.ShareSystemMemoryRegisterContext(ctx, "input0", "/input0", 64, 0)
+	assert.NoError(t, err)
+	_, err = client.ShareSystemMemoryRegisterContext(ctx, "input0", "/input0", 64, 0)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	regions, err := client.SystemSharedMemoryStatusContext(ctx, "")
+	assert.NoError(t, err)
+	assert.Contains(t, regions.Regions, "input0")
+
+	_, err = client.ShareSystemMemoryUnRegisterContext(ctx, "")
+	assert.NoError(t, err)
+	_, err = client.SystemSharedMemoryStatusContext(ctx, "input0")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,221 @@
+package triton_client
+
+import (
+	"context"
+	"fmt"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/model_config"
+	"strings"
+)
+
+// ValidationProblem is a single problem found in an inference request.
+type ValidationProblem struct {
+	// Tensor is the input or output the problem is about, empty for request wide problems
+	Tensor string
+	// Message describes the problem
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	if p.Tensor == "" {
+		return p.Message
+	}
+	return p.Tensor + ": " + p.Message
+}
+
+// ValidationError is returned when an inference request does not match the model config or
+// metadata. It lists every problem found.
+type ValidationError struct {
+	ModelName string
+	Problems  []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for idx, problem := range e.Problems {
+		problems[idx] = problem.String()
+	}
+	return fmt.Sprintf("invalid inference request for model %s: %s", e.ModelName, strings.Join(problems, "; "))
+}
+
+func (e *ValidationError) addf(tensor, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, ValidationProblem{Tensor: tensor, Message: fmt.Sprintf(format, args...)})
+}
+
+// tensorSpec is the expected datatype and shape of a tensor. The shape includes the batch
+// dimension of batching models.
+type tensorSpec struct {
+	datatype string
+	shape    []int64
+	optional bool
+	ragged   bool
+}
+
+// modelSpec is what a request is validated against.
+type modelSpec struct {
+	name         string
+	maxBatchSize int64
+	inputs       map[string]tensorSpec
+	inputOrder   []string
+	outputs      map[string]bool
+}
+
+// ValidateInferRequest checks req against the model config: input names, datatypes, shapes
+// including the implicit batch dimension of batching models and wildcard dimensions, data
+// sizes and requested outputs. It returns a *ValidationError listing every problem found.
+func ValidateInferRequest(config *grpc_client.ModelConfig, req *grpc_client.ModelInferRequest) error {
+	spec := &modelSpec{
+		name:         config.Name,
+		maxBatchSize: int64(config.MaxBatchSize),
+		inputs:       make(map[string]tensorSpec, len(config.Input)),
+		outputs:      make(map[string]bool, len(config.Output)),
+	}
+	for _, input := range config.Input {
+		spec.inputs[input.Name] = tensorSpec{
+			datatype: model_config.DataTypeToProtocolString(input.DataType),
+			shape:    ConfigShape(config, input.Dims),
+			optional: input.Optional,
+			ragged:   input.AllowRaggedBatch,
+		}
+		spec.inputOrder = append(spec.inputOrder, input.Name)
+	}
+	for _, output := range config.Output {
+		spec.outputs[output.Name] = true
+	}
+	return spec.validate(req)
+}
+
+// ValidateInferRequestMetadata checks req against the model metadata like ValidateInferRequest.
+// Metadata does not tell the maximum batch size, so the batch dimension is only checked as a
+// wildcard.
+func ValidateInferRequestMetadata(metadata *grpc_client.ModelMetadataResponse, req *grpc_client.ModelInferRequest) error {
+	spec := &modelSpec{
+		name:    metadata.Name,
+		inputs:  make(map[string]tensorSpec, len(metadata.Inputs)),
+		outputs: make(map[string]bool, len(metadata.Outputs)),
+	}
+	for _, input := range metadata.Inputs {
+		spec.inputs[input.Name] = tensorSpec{datatype: input.Datatype, shape: input.Shape}
+		spec.inputOrder = append(spec.inputOrder, input.Name)
+	}
+	for _, output := range metadata.Outputs {
+		spec.outputs[output.Name] = true
+	}
+	return spec.validate(req)
+}
+
+// ValidateRequest checks req against the cached config of its model before it is sent.
+func ValidateRequest(ctx context.Context, client InferenceClient, req *grpc_client.ModelInferRequest) error {
+	config, err := client.CachedModelConfigurationContext(ctx, req.ModelName, req.ModelVersion)
+	if err != nil {
+		return err
+	}
+	return ValidateInferRequest(config.Config, req)
+}
+
+func (spec *modelSpec) validate(req *grpc_client.ModelInferRequest) error {
+	verr := &ValidationError{ModelName: req.ModelName}
+	if verr.ModelName == "" {
+		verr.ModelName = spec.name
+	}
+
+	batchSize := int64(-1)
+	rawIdx := 0
+	seen := make(map[string]bool, len(req.Inputs))
+	for _, input := range req.Inputs {
+		// raw contents are only given for the inputs that are not in shared memory
+		var raw []byte
+		hasRaw := false
+		if !usesSharedMemory(input.Parameters) && rawIdx < len(req.RawInputContents) {
+			raw, hasRaw = req.RawInputContents[rawIdx], true
+			rawIdx++
+		}
+
+		if seen[input.Name] {
+			verr.addf(input.Name, "input is given more than once")
+			continue
+		}
+		seen[input.Name] = true
+
+		expected, ok := spec.inputs[input.Name]
+		if !ok {
+			verr.addf(input.Name, "unexpected input, the model has inputs %s", strings.Join(spec.inputOrder, ", "))
+			continue
+		}
+		if input.Datatype != expected.datatype {
+			verr.addf(input.Name, "datatype %s does not match %s", input.Datatype, expected.datatype)
+		}
+
+		shapeValid := true
+		for _, dim := range input.Shape {
+			if dim < 0 {
+				verr.addf(input.Name, "shape %s must not contain negative dimensions", model_config.DimsListToString(input.Shape))
+				shapeValid = false
+				break
+			}
+		}
+		if shapeValid && !expected.ragged {
+			if !model_config.CompareDimsWithWildcard(expected.shape, input.Shape) {
+				verr.addf(input.Name, "shape %s does not match %s", model_config.DimsListToString(input.Shape), model_config.DimsListToString(expected.shape))
+				shapeValid = false
+			} else if spec.maxBatchSize > 0 {
+				switch {
+				case input.Shape[0] < 1 || input.Shape[0] > spec.maxBatchSize:
+					verr.addf(input.Name, "batch size %d is outside [1, %d]", input.Shape[0], spec.maxBatchSize)
+				case batchSize >= 0 && input.Shape[0] != batchSize:
+					verr.addf(input.Name, "batch size %d differs from the batch size %d of the other inputs", input.Shape[0], batchSize)
+				default:
+					batchSize = input.Shape[0]
+				}
+			}
+		}
+
+		if shapeValid && hasRaw {
+			validateDataSize(verr, input, raw)
+		}
+	}
+
+	for _, name := range spec.inputOrder {
+		if !seen[name] && !spec.inputs[name].optional {
+			verr.addf(name, "required input is missing")
+		}
+	}
+	for _, output := range req.Outputs {
+		if !spec.outputs[output.Name] {
+			verr.addf(output.Name, "requested output does not exist")
+		}
+	}
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// validateDataSize checks the raw contents of an input against its shape, like GetByteSize
+// for fixed size datatypes and by counting the elements of BYTES inputs.
+func validateDataSize(verr *ValidationError, input *grpc_client.ModelInferRequest_InferInputTensor, raw []byte) {
+	count := int64(1)
+	for _, dim := range input.Shape {
+		count *= dim
+	}
+
+	if input.Datatype == DataTypeBytes {
+		elements, err := decodeBytes(raw)
+		if err != nil {
+			verr.addf(input.Name, "invalid BYTES data: %v", err)
+		} else if int64(len(elements)) != count {
+			verr.addf(input.Name, "got %d elements, shape %s requires %d", len(elements), model_config.DimsListToString(input.Shape), count)
+		}
+		return
+	}
+
+	size := int64(dataTypeByteSize(input.Datatype))
+	if size == 0 {
+		verr.addf(input.Name, "unknown datatype %s", input.Datatype)
+		return
+	}
+	if int64(len(raw)) != count*size {
+		verr.addf(input.Name, "got %d bytes, shape %s of %s requires %d", len(raw), model_config.DimsListToString(input.Shape), input.Datatype, count*size)
+	}
+}
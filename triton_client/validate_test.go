@@ -0,0 +1,146 @@
+package triton_client
+
+import (
+	"context"
+	grpc_client "github.com/okieraised/gotritron/grpc-client"
+	"github.com/okieraised/gotritron/triton_client/tritontest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var validateTestConfig = &grpc_client.ModelConfig{
+	Name:         "face",
+	MaxBatchSize: 4,
+	Input: []*grpc_client.ModelInput{
+		{Name: "data", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{3, -1, -1}},
+		{Name: "labels", DataType: grpc_client.DataType_TYPE_STRING, Dims: []int64{1}, Optional: true},
+	},
+	Output: []*grpc_client.ModelOutput{
+		{Name: "score", DataType: grpc_client.DataType_TYPE_FP32, Dims: []int64{1}},
+	},
+}
+
+func newValidateTestRequest(t *testing.T, modify func(inputs []*InferInput) []*InferInput, outputs ...string) *grpc_client.ModelInferRequest {
+	data := NewInferInput("data", []int64{2, 3, 2, 2}, DataTypeFP32)
+	assert.NoError(t, data.SetData(make([]float32, 24)))
+	inputs := []*InferInput{data}
+	if modify != nil {
+		inputs = modify(inputs)
+	}
+
+	var requested []*InferRequestedOutput
+	for _, output := range outputs {
+		requested = append(requested, NewInferRequestedOutput(output))
+	}
+	req, err := NewModelInferRequest("face", "", inputs, requested)
+	assert.NoError(t, err)
+	return req
+}
+
+func TestValidateInferRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *grpc_client.ModelInferRequest
+		problems []ValidationProblem
+	}{
+		{
+			name: "valid",
+			req:  newValidateTestRequest(t, nil, "score"),
+		},
+		{
+			name: "valid with optional BYTES input",
+			req: newValidateTestRequest(t, func(inputs []*InferInput) []*InferInput {
+				labels := NewInferInput("labels", []int64{2, 1}, DataTypeBytes)
+				_ = labels.SetData([]string{"a", "b"})
+				return append(inputs, labels)
+			}),
+		},
+		{
+			name: "missing batch dimension",
+			req: newValidateTestRequest(t, func(inputs []*InferInput) []*InferInput {
+				inputs[0].SetShape([]int64{3, 2, 4})
+				return inputs
+			}),
+			problems: []ValidationProblem{{Tensor: "data", Message: "shape [3,2,4] does not match [-1,3,-1,-1]"}},
+		},
+		{
+			name: "batch too large",
+			req: newValidateTestRequest(t, func(inputs []*InferInput) []*InferInput {
+				inputs[0].SetShape([]int64{8, 3, 1, 1})
+				return inputs
+			}),
+			problems: []ValidationProblem{{Tensor: "data", Message: "batch size 8 is outside [1, 4]"}},
+		},
+		{
+			name: "every problem is reported",
+			req: newValidateTestRequest(t, func(inputs []*InferInput) []*InferInput {
+				data := NewInferInput("data", []int64{2, 3, 2, 2}, DataTypeFP16)
+				_ = data.SetData(make([]float32, 24))
+				labels := NewInferInput("labels", []int64{1, 1}, DataTypeBytes)
+				_ = labels.SetData([]string{"a"})
+				extra := NewInferInput("extra", []int64{1}, DataTypeInt32)
+				_ = extra.SetData([]int32{1})
+				return []*InferInput{data, labels, extra}
+			}, "score", "landmarks"),
+			problems: []ValidationProblem{
+				{Tensor: "data", Message: "datatype FP16 does not match FP32"},
+				{Tensor: "labels", Message: "batch size 1 differs from the batch size 2 of the other inputs"},
+				{Tensor: "extra", Message: "unexpected input, the model has inputs data, labels"},
+				{Tensor: "landmarks", Message: "requested output does not exist"},
+			},
+		},
+		{
+			name: "data size does not match the shape",
+			req: func() *grpc_client.ModelInferRequest {
+				req := newValidateTestRequest(t, nil)
+				req.RawInputContents[0] = req.RawInputContents[0][:90]
+				return req
+			}(),
+			problems: []ValidationProblem{{Tensor: "data", Message: "got 90 bytes, shape [2,3,2,2] of FP32 requires 96"}},
+		},
+		{
+			name:     "missing required input",
+			req:      &grpc_client.ModelInferRequest{ModelName: "face"},
+			problems: []ValidationProblem{{Tensor: "data", Message: "required input is missing"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInferRequest(validateTestConfig, tt.req)
+			if tt.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			assert.True(t, ok)
+			assert.Equal(t, "face", verr.ModelName)
+			assert.Equal(t, tt.problems, verr.Problems)
+		})
+	}
+}
+
+func TestValidateInferRequestMetadata(t *testing.T) {
+	metadata := &grpc_client.ModelMetadataResponse{
+		Name:    "face",
+		Inputs:  []*grpc_client.ModelMetadataResponse_TensorMetadata{{Name: "data", Datatype: "FP32", Shape: []int64{-1, 3, -1, -1}}},
+		Outputs: []*grpc_client.ModelMetadataResponse_TensorMetadata{{Name: "score", Datatype: "FP32", Shape: []int64{-1, 1}}},
+	}
+
+	assert.NoError(t, ValidateInferRequestMetadata(metadata, newValidateTestRequest(t, nil, "score")))
+
+	err := ValidateInferRequestMetadata(metadata, newValidateTestRequest(t, func(inputs []*InferInput) []*InferInput {
+		inputs[0].SetShape([]int64{2, 4, 2, 2})
+		return inputs
+	}))
+	assert.EqualError(t, err, "invalid inference request for model face: data: shape [2,4,2,2] does not match [-1,3,-1,-1]")
+}
+
+func TestValidateRequest(t *testing.T) {
+	server, client := newFakeServerClient(t)
+	server.AddModel(&tritontest.Model{Config: validateTestConfig})
+
+	assert.NoError(t, ValidateRequest(context.Background(), client, newValidateTestRequest(t, nil)))
+	assert.Error(t, ValidateRequest(context.Background(), client, newValidateTestRequest(t, nil, "landmarks")))
+	assert.Len(t, server.Requests("ModelConfig"), 1)
+}